@@ -0,0 +1,38 @@
+package evictrevoked
+
+import (
+	"github.com/off-sync/platform-proxy/app/interfaces"
+)
+
+// Cmd defines the Evict Revoked Certificates command.
+type Cmd struct {
+	lister interfaces.RevokedCertLister
+	cache  interfaces.CertCache
+}
+
+// New creates a new Evict Revoked Certificates command, which evicts the
+// certificates marked as revoked in the store from the provided cache.
+func New(lister interfaces.RevokedCertLister, cache interfaces.CertCache) *Cmd {
+	return &Cmd{
+		lister: lister,
+		cache:  cache,
+	}
+}
+
+// Execute executes the Evict Revoked Certificates command.
+// It evicts all certificates marked as revoked, e.g. by another process,
+// from the cache so they are no longer served. Revoked certificates are not
+// loaded from the store, which causes them to be re-issued on their next use.
+// It returns the domains of the evicted certificates.
+func (c *Cmd) Execute() ([][]string, error) {
+	revoked, err := c.lister.ListRevoked()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, domains := range revoked {
+		c.cache.Evict(domains)
+	}
+
+	return revoked, nil
+}
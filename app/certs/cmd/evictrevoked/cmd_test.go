@@ -0,0 +1,39 @@
+package evictrevoked
+
+import (
+	"reflect"
+	"testing"
+)
+
+type fakeLister [][]string
+
+func (l fakeLister) ListRevoked() ([][]string, error) { return l, nil }
+
+type fakeCache struct {
+	evicted [][]string
+}
+
+func (c *fakeCache) Evict(domains []string) { c.evicted = append(c.evicted, domains) }
+
+func TestExecuteEvictsRevokedCertificates(t *testing.T) {
+	lister := fakeLister{
+		{"example.com", "api.example.com"},
+	}
+
+	cache := &fakeCache{}
+
+	evicted, err := New(lister, cache).Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := [][]string{{"example.com", "api.example.com"}}
+
+	if !reflect.DeepEqual(cache.evicted, expected) {
+		t.Fatalf("expected evicted %v, got %v", expected, cache.evicted)
+	}
+
+	if !reflect.DeepEqual(evicted, expected) {
+		t.Fatalf("expected returned %v, got %v", expected, evicted)
+	}
+}
@@ -0,0 +1,180 @@
+package migratecerts
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/off-sync/platform-proxy/app/interfaces"
+	"github.com/off-sync/platform-proxy/domain/certs"
+)
+
+// Cmd defines the Migrate Certificates command.
+type Cmd struct {
+	src       interfaces.CertLister
+	dst       interfaces.CertImporter
+	dstLister interfaces.CertLister
+}
+
+// New creates a new Migrate Certificates command copying certificates from
+// the source lister to the destination importer. The destination lister is
+// used to detect certificates that have already been copied, including
+// revoked ones.
+func New(src interfaces.CertLister, dst interfaces.CertImporter, dstLister interfaces.CertLister) *Cmd {
+	return &Cmd{
+		src:       src,
+		dst:       dst,
+		dstLister: dstLister,
+	}
+}
+
+// Model defines the input for the Migrate Certificates command.
+type Model struct {
+	// DryRun reports which certificates would be copied without
+	// changing the destination store.
+	DryRun bool
+
+	// Resume skips certificates that are already present in the
+	// destination store with identical contents and revocation, and
+	// certificates for which the destination holds a newer one, e.g.
+	// renewed since the last run.
+	Resume bool
+}
+
+// Outcome defines what happened to a single certificate.
+type Outcome int
+
+const (
+	// Copied indicates the certificate has been copied (or would have
+	// been copied in a dry-run).
+	Copied Outcome = iota
+
+	// Skipped indicates the certificate, or a newer one, already exists in
+	// the destination.
+	Skipped
+
+	// Empty indicates the source only holds a save token for the domains.
+	Empty
+
+	// Failed indicates copying the certificate resulted in an error.
+	Failed
+)
+
+// String returns the name of the outcome.
+func (o Outcome) String() string {
+	switch o {
+	case Copied:
+		return "copied"
+	case Skipped:
+		return "skipped"
+	case Empty:
+		return "empty"
+	case Failed:
+		return "failed"
+	}
+
+	return "unknown"
+}
+
+// Result defines the outcome for one entry of the source store.
+type Result struct {
+	Entry   *certs.Entry
+	Outcome Outcome
+	Err     error
+}
+
+// Execute executes the Migrate Certificates command.
+// It lists all entries in the source store and copies them to the destination.
+// A failure to copy a single entry is recorded in its result; the returned
+// error is only set if the source store could not be listed.
+func (c *Cmd) Execute(model Model) ([]*Result, error) {
+	entries, err := c.src.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var existing map[string]*certs.Entry
+	if model.Resume {
+		if existing, err = c.listDestination(); err != nil {
+			return nil, err
+		}
+	}
+
+	results := make([]*Result, len(entries))
+	for i, entry := range entries {
+		outcome, err := c.migrate(model, entry, existing[domainsKey(entry.Domains)])
+
+		results[i] = &Result{
+			Entry:   entry,
+			Outcome: outcome,
+			Err:     err,
+		}
+	}
+
+	return results, nil
+}
+
+// listDestination returns the readable entries of the destination store
+// by their domains.
+func (c *Cmd) listDestination() (map[string]*certs.Entry, error) {
+	entries, err := c.dstLister.List()
+	if err != nil {
+		return nil, err
+	}
+
+	existing := make(map[string]*certs.Entry)
+	for _, entry := range entries {
+		if entry.Err == nil {
+			existing[domainsKey(entry.Domains)] = entry
+		}
+	}
+
+	return existing, nil
+}
+
+func domainsKey(domains []string) string {
+	return strings.Join(domains, ",")
+}
+
+// migrate copies the entry, unless the existing entry of the destination
+// makes this unnecessary when resuming.
+func (c *Cmd) migrate(model Model, entry *certs.Entry, existing *certs.Entry) (Outcome, error) {
+	if entry.Err != nil {
+		return Failed, entry.Err
+	}
+
+	if entry.Certificate == nil {
+		return Empty, nil
+	}
+
+	if model.Resume && existing != nil && existing.Certificate != nil {
+		if existing.NotAfter.After(entry.NotAfter) {
+			// renewed in the destination since the last run
+			return Skipped, nil
+		}
+
+		// a revocation in the source is copied, one in the destination kept
+		if sameCertificate(existing.Certificate, entry.Certificate) &&
+			(!existing.Revoked.IsZero() || entry.Revoked.IsZero()) {
+			return Skipped, nil
+		}
+	}
+
+	if model.DryRun {
+		return Copied, nil
+	}
+
+	token, err := c.dst.ClaimSaveToken(entry.Domains)
+	if err != nil {
+		return Failed, err
+	}
+
+	if err := c.dst.Import(token, entry); err != nil {
+		return Failed, err
+	}
+
+	return Copied, nil
+}
+
+func sameCertificate(a, b *certs.Certificate) bool {
+	return bytes.Equal(a.Certificate, b.Certificate) && bytes.Equal(a.PrivateKey, b.PrivateKey)
+}
@@ -0,0 +1,113 @@
+package migratecerts
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/off-sync/platform-proxy/app/interfaces"
+	"github.com/off-sync/platform-proxy/domain/certs"
+)
+
+var errTest = errors.New("domains do not match the hash")
+
+type fakeLister []*certs.Entry
+
+func (l fakeLister) List() ([]*certs.Entry, error) { return l, nil }
+
+type fakeImporter struct {
+	imported []*certs.Entry
+}
+
+func (i *fakeImporter) ClaimSaveToken(domains []string) (interfaces.CertSaveToken, error) {
+	return "token", nil
+}
+
+func (i *fakeImporter) Save(domains []string, token interfaces.CertSaveToken, crt *certs.Certificate) error {
+	return nil
+}
+
+func (i *fakeImporter) Import(token interfaces.CertSaveToken, entry *certs.Entry) error {
+	i.imported = append(i.imported, entry)
+	return nil
+}
+
+func newEntry(domain string, notAfter time.Time, crt string) *certs.Entry {
+	return &certs.Entry{
+		Domains:  []string{domain},
+		NotAfter: notAfter,
+		Certificate: &certs.Certificate{
+			PrivateKey:  []byte("key " + crt),
+			Certificate: []byte(crt),
+		},
+	}
+}
+
+func TestExecuteResume(t *testing.T) {
+	older := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.Add(60 * 24 * time.Hour)
+	revoked := older.Add(24 * time.Hour)
+
+	revokedInSource := newEntry("revoked-src.example.com", older, "a")
+	revokedInSource.Revoked = revoked
+
+	revokedInDestination := newEntry("revoked-dst.example.com", older, "b")
+	revokedInDestination.Revoked = revoked
+
+	src := fakeLister{
+		newEntry("new.example.com", older, "c"),
+		newEntry("copied.example.com", older, "d"),
+		newEntry("renewed.example.com", older, "e"),
+		newEntry("outdated.example.com", newer, "f"),
+		revokedInSource,
+		newEntry("revoked-dst.example.com", older, "b"),
+	}
+
+	dst := fakeLister{
+		newEntry("copied.example.com", older, "d"),
+		newEntry("renewed.example.com", newer, "renewed"),
+		newEntry("outdated.example.com", older, "outdated"),
+		newEntry("revoked-src.example.com", older, "a"),
+		revokedInDestination,
+	}
+
+	importer := &fakeImporter{}
+
+	results, err := New(src, importer, dst).Execute(Model{Resume: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]Outcome{
+		"new.example.com":         Copied,
+		"copied.example.com":      Skipped,
+		"renewed.example.com":     Skipped,
+		"outdated.example.com":    Copied,
+		"revoked-src.example.com": Copied,
+		"revoked-dst.example.com": Skipped,
+	}
+
+	for _, result := range results {
+		domain := result.Entry.Domains[0]
+		if result.Outcome != expected[domain] {
+			t.Errorf("%s: expected %s, got %s", domain, expected[domain], result.Outcome)
+		}
+	}
+
+	if len(importer.imported) != 3 {
+		t.Fatalf("expected 3 imports, got %d", len(importer.imported))
+	}
+}
+
+func TestExecuteFailsUnreadableEntries(t *testing.T) {
+	src := fakeLister{{Domains: []string{"www.example.com"}, Err: errTest}}
+
+	results, err := New(src, &fakeImporter{}, fakeLister{}).Execute(Model{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if results[0].Outcome != Failed || results[0].Err != errTest {
+		t.Fatalf("expected failed result, got %s: %v", results[0].Outcome, results[0].Err)
+	}
+}
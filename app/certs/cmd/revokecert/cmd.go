@@ -0,0 +1,66 @@
+package revokecert
+
+import (
+	"github.com/off-sync/platform-proxy/app/interfaces"
+	"github.com/off-sync/platform-proxy/domain/certs"
+)
+
+// Cmd defines the Revoke Certificate command.
+type Cmd struct {
+	ldr   interfaces.CertLoader
+	rvk   interfaces.CertRevoker
+	mrk   interfaces.CertRevocationMarker
+	cache interfaces.CertCache
+}
+
+// New creates a new Revoke Certificate command with the provided options.
+// The cache is optional and can be nil.
+func New(ldr interfaces.CertLoader, rvk interfaces.CertRevoker, mrk interfaces.CertRevocationMarker, cache interfaces.CertCache) *Cmd {
+	return &Cmd{
+		ldr:   ldr,
+		rvk:   rvk,
+		mrk:   mrk,
+		cache: cache,
+	}
+}
+
+// Model defines the input for the Revoke Certificate command.
+type Model struct {
+	Domains []string
+	Reason  certs.RevocationReason
+
+	// UseCertKey authorizes the revocation using the private key of the
+	// certificate instead of the account key.
+	UseCertKey bool
+}
+
+// Execute executes the Revoke Certificate command.
+// It revokes the certificate with the issuer, marks it as revoked in the
+// store and evicts it from the cache.
+// It returns ErrCertNotFound if no certificate is stored for the domains.
+func (c *Cmd) Execute(model Model) error {
+	crt, err := c.ldr.Load(model.Domains)
+	if err != nil {
+		return err
+	}
+
+	if crt == nil {
+		return interfaces.ErrCertNotFound
+	}
+
+	err = c.rvk.Revoke(crt, model.Reason, model.UseCertKey)
+	if err != nil {
+		return err
+	}
+
+	err = c.mrk.MarkRevoked(model.Domains)
+	if err != nil {
+		return err
+	}
+
+	if c.cache != nil {
+		c.cache.Evict(model.Domains)
+	}
+
+	return nil
+}
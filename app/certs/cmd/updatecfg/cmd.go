@@ -4,18 +4,38 @@ import "github.com/off-sync/platform-proxy/app/interfaces"
 
 // Cmd defines the Update Config command.
 type Cmd struct {
-	cfgUpdater interfaces.ConfigUpdater
+	cfgUpdaters []interfaces.ConfigUpdater
 }
 
 // New creates a new Update Config command using the provided
-// Config Updater.
-func New(cfgUpdater interfaces.ConfigUpdater) *Cmd {
+// Config Updaters. Each updater receives the complete configuration.
+func New(cfgUpdaters ...interfaces.ConfigUpdater) *Cmd {
 	return &Cmd{
-		cfgUpdater: cfgUpdater,
+		cfgUpdaters: cfgUpdaters,
 	}
 }
 
-// Execute executes the Update Config command.
+// Execute executes the Update Config command. The configuration is
+// prepared by all updaters before it is applied, so it is either applied
+// by all updaters or, if any of them returns an error, by none.
 func (c *Cmd) Execute(model *Model) error {
-	return c.cfgUpdater.Update(model.Backends, model.Frontends)
+	var updates []interfaces.ConfigUpdate
+	for _, cfgUpdater := range c.cfgUpdaters {
+		update, err := cfgUpdater.Prepare(model.Backends, model.Frontends)
+		if err != nil {
+			for _, u := range updates {
+				u.Discard()
+			}
+
+			return err
+		}
+
+		updates = append(updates, update)
+	}
+
+	for _, u := range updates {
+		u.Apply()
+	}
+
+	return nil
 }
@@ -0,0 +1,56 @@
+package updatecfg
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/off-sync/platform-proxy/app/interfaces"
+	"github.com/off-sync/platform-proxy/domain/sites"
+)
+
+type fakeUpdater struct {
+	err       error
+	applied   bool
+	discarded bool
+}
+
+func (u *fakeUpdater) Prepare(backends []*sites.Backend, frontends []*sites.Frontend) (interfaces.ConfigUpdate, error) {
+	if u.err != nil {
+		return nil, u.err
+	}
+
+	return u, nil
+}
+
+func (u *fakeUpdater) Apply()   { u.applied = true }
+func (u *fakeUpdater) Discard() { u.discarded = true }
+
+func TestExecuteAppliesNothingIfAnyUpdaterFails(t *testing.T) {
+	first := &fakeUpdater{}
+	second := &fakeUpdater{err: errors.New("port in use")}
+
+	if err := New(first, second).Execute(&Model{}); err == nil {
+		t.Fatal("expected error")
+	}
+
+	if first.applied {
+		t.Error("expected first updater not to be applied")
+	}
+
+	if !first.discarded {
+		t.Error("expected first updater to be discarded")
+	}
+}
+
+func TestExecuteAppliesAllUpdaters(t *testing.T) {
+	first := &fakeUpdater{}
+	second := &fakeUpdater{}
+
+	if err := New(first, second).Execute(&Model{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !first.applied || !second.applied {
+		t.Fatalf("expected both updaters to be applied, got %v and %v", first.applied, second.applied)
+	}
+}
@@ -0,0 +1,43 @@
+package listcerts
+
+import (
+	"time"
+)
+
+// Model defines the input for the List Certificates query.
+type Model struct {
+	// Domain restricts the result to certificates covering this domain.
+	// All certificates are returned if it is empty.
+	Domain string
+}
+
+// CertInfo describes a stored certificate.
+type CertInfo struct {
+	Domains  []string  `json:"domains"`
+	Created  time.Time `json:"created"`
+	Modified time.Time `json:"modified"`
+
+	// Empty is set if a save token has been claimed for the domains,
+	// but no certificate has been saved yet.
+	Empty bool `json:"empty"`
+
+	Issuer        string    `json:"issuer,omitempty"`
+	SerialNumber  string    `json:"serial_number,omitempty"`
+	DNSNames      []string  `json:"dns_names,omitempty"`
+	KeyType       string    `json:"key_type,omitempty"`
+	NotBefore     time.Time `json:"not_before"`
+	NotAfter      time.Time `json:"not_after"`
+	DaysRemaining int       `json:"days_remaining"`
+
+	Revoked time.Time `json:"revoked"`
+
+	SaveTokenClaimed   bool      `json:"save_token_claimed"`
+	SaveTokenExpiresAt time.Time `json:"save_token_expires_at"`
+
+	// Orphaned is set if none of the domains is used by a frontend.
+	Orphaned bool `json:"orphaned"`
+
+	// ParseError holds the error encountered while reading the entry from
+	// the store or parsing the certificate.
+	ParseError string `json:"parse_error,omitempty"`
+}
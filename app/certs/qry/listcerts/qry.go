@@ -0,0 +1,146 @@
+package listcerts
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"fmt"
+
+	"github.com/off-sync/platform-proxy/app/interfaces"
+	commonCerts "github.com/off-sync/platform-proxy/common/certs"
+	"github.com/off-sync/platform-proxy/domain/certs"
+)
+
+// Qry defines the List Certificates query.
+type Qry struct {
+	lister   interfaces.CertLister
+	provider interfaces.ConfigProvider
+	time     interfaces.Time
+}
+
+// New creates a new List Certificates query using the provided certificate lister.
+// The config provider is used to detect orphaned certificates; it can be nil,
+// in which case no certificate is reported as orphaned.
+func New(lister interfaces.CertLister, provider interfaces.ConfigProvider, time interfaces.Time) *Qry {
+	return &Qry{
+		lister:   lister,
+		provider: provider,
+		time:     time,
+	}
+}
+
+// Execute lists the certificates in the store.
+func (q *Qry) Execute(model Model) ([]*CertInfo, error) {
+	entries, err := q.lister.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var hostMatchers []func(host string) bool
+	if q.provider != nil {
+		frontends, err := q.provider.GetFrontends()
+		if err != nil {
+			return nil, err
+		}
+
+		// a certificate is in use if any frontend matches one of its domains,
+		// including frontends matching wildcard or regex hosts
+		hostMatchers = make([]func(host string) bool, 0, len(frontends))
+		for _, frontend := range frontends {
+			matches, err := frontend.HostMatcher()
+			if err != nil {
+				return nil, fmt.Errorf("matching hosts of frontend %s: %s", frontend.Domain, err)
+			}
+
+			hostMatchers = append(hostMatchers, matches)
+		}
+	}
+
+	var infos []*CertInfo
+	for _, entry := range entries {
+		if model.Domain != "" && !contains(entry.Domains, model.Domain) {
+			continue
+		}
+
+		info := q.describe(entry)
+
+		if hostMatchers != nil {
+			info.Orphaned = !matchesAny(hostMatchers, entry.Domains)
+		}
+
+		infos = append(infos, info)
+	}
+
+	return infos, nil
+}
+
+func (q *Qry) describe(entry *certs.Entry) *CertInfo {
+	now := q.time.Now()
+
+	if entry.Err != nil {
+		return &CertInfo{
+			Domains:    entry.Domains,
+			ParseError: entry.Err.Error(),
+		}
+	}
+
+	info := &CertInfo{
+		Domains:            entry.Domains,
+		Created:            entry.Created,
+		Modified:           entry.Modified,
+		Empty:              entry.Certificate == nil,
+		NotAfter:           entry.NotAfter,
+		Revoked:            entry.Revoked,
+		SaveTokenClaimed:   entry.SaveTokenExpiresAt.After(now),
+		SaveTokenExpiresAt: entry.SaveTokenExpiresAt,
+	}
+
+	if entry.Certificate == nil {
+		return info
+	}
+
+	leaf, err := commonCerts.ParseLeaf(entry.Certificate)
+	if err != nil {
+		info.ParseError = err.Error()
+		return info
+	}
+
+	info.Issuer = leaf.Issuer.CommonName
+	info.SerialNumber = leaf.SerialNumber.String()
+	info.DNSNames = leaf.DNSNames
+	info.NotBefore = leaf.NotBefore
+	info.NotAfter = leaf.NotAfter
+	info.DaysRemaining = int(leaf.NotAfter.Sub(now).Hours() / 24)
+
+	switch key := leaf.PublicKey.(type) {
+	case *rsa.PublicKey:
+		info.KeyType = fmt.Sprintf("RSA %d", key.N.BitLen())
+	case *ecdsa.PublicKey:
+		info.KeyType = fmt.Sprintf("ECDSA %s", key.Curve.Params().Name)
+	default:
+		info.KeyType = leaf.PublicKeyAlgorithm.String()
+	}
+
+	return info
+}
+
+func matchesAny(hostMatchers []func(host string) bool, domains []string) bool {
+	for _, domain := range domains {
+		for _, matches := range hostMatchers {
+			if matches(domain) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func contains(domains []string, domain string) bool {
+	for _, d := range domains {
+		if d == domain {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,56 @@
+package listcerts
+
+import (
+	"testing"
+	"time"
+
+	"github.com/off-sync/platform-proxy/domain/certs"
+	"github.com/off-sync/platform-proxy/domain/sites"
+)
+
+type fakeLister []*certs.Entry
+
+func (l fakeLister) List() ([]*certs.Entry, error) { return l, nil }
+
+type fakeProvider []*sites.Frontend
+
+func (p fakeProvider) GetNotificationChannel() <-chan bool      { return nil }
+func (p fakeProvider) GetBackends() ([]*sites.Backend, error)   { return nil, nil }
+func (p fakeProvider) GetFrontends() ([]*sites.Frontend, error) { return p, nil }
+
+type fakeTime struct{}
+
+func (fakeTime) Now() time.Time { return time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC) }
+
+func TestExecuteDetectsOrphanedCertificates(t *testing.T) {
+	lister := fakeLister{
+		{Domains: []string{"www.example.com"}},
+		{Domains: []string{"shop.customer.example.com"}},
+		{Domains: []string{"api.example.org"}},
+		{Domains: []string{"old.example.net"}},
+	}
+
+	provider := fakeProvider{
+		{Domain: "www.example.com"},
+		{Domain: "*.customer.example.com"},
+		{Domain: `~^(api|www)\.example\.org$`},
+	}
+
+	infos, err := New(lister, provider, fakeTime{}).Execute(Model{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]bool{
+		"www.example.com":           false,
+		"shop.customer.example.com": false,
+		"api.example.org":           false,
+		"old.example.net":           true,
+	}
+
+	for _, info := range infos {
+		if info.Orphaned != expected[info.Domains[0]] {
+			t.Errorf("%s: expected orphaned %v, got %v", info.Domains[0], expected[info.Domains[0]], info.Orphaned)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package reloadcfg
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/off-sync/platform-proxy/app/certs/cmd/updatecfg"
+	"github.com/off-sync/platform-proxy/app/config/qry/getconfig"
+)
+
+// Cmd defines the Reload Config command.
+type Cmd struct {
+	sync.Mutex
+	getConfigQry *getconfig.Qry
+	updateCfgCmd *updatecfg.Cmd
+	current      *updatecfg.Model
+}
+
+// New creates a new Reload Config command, which reads the configuration
+// using the Get Config query and applies it using the Update Config command.
+func New(getConfigQry *getconfig.Qry, updateCfgCmd *updatecfg.Cmd) *Cmd {
+	return &Cmd{
+		getConfigQry: getConfigQry,
+		updateCfgCmd: updateCfgCmd,
+	}
+}
+
+// Execute executes the Reload Config command. The configuration is only
+// applied if it differs from the last applied configuration, as providers may
+// notify without knowing whether anything has changed. It returns the applied
+// configuration, or nil if it is unchanged. A configuration which fails to
+// apply is read and applied again on the next execution.
+func (c *Cmd) Execute() (*updatecfg.Model, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	backends, frontends, err := c.getConfigQry.Execute()
+	if err != nil {
+		return nil, err
+	}
+
+	model := &updatecfg.Model{
+		Backends:  backends,
+		Frontends: frontends,
+	}
+
+	if c.current != nil && reflect.DeepEqual(c.current, model) {
+		return nil, nil
+	}
+
+	if err := c.updateCfgCmd.Execute(model); err != nil {
+		return nil, err
+	}
+
+	c.current = model
+
+	return model, nil
+}
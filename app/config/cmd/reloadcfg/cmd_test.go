@@ -0,0 +1,80 @@
+package reloadcfg
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/off-sync/platform-proxy/app/certs/cmd/updatecfg"
+	"github.com/off-sync/platform-proxy/app/config/qry/getconfig"
+	"github.com/off-sync/platform-proxy/app/interfaces"
+	"github.com/off-sync/platform-proxy/domain/sites"
+)
+
+type fakeProvider struct {
+	frontends []*sites.Frontend
+}
+
+func (p *fakeProvider) GetNotificationChannel() <-chan bool      { return nil }
+func (p *fakeProvider) GetBackends() ([]*sites.Backend, error)   { return nil, nil }
+func (p *fakeProvider) GetFrontends() ([]*sites.Frontend, error) { return p.frontends, nil }
+
+type fakeUpdater struct {
+	updates int
+	err     error
+}
+
+func (u *fakeUpdater) Prepare(backends []*sites.Backend, frontends []*sites.Frontend) (interfaces.ConfigUpdate, error) {
+	if u.err != nil {
+		return nil, u.err
+	}
+
+	return u, nil
+}
+
+func (u *fakeUpdater) Apply()   { u.updates++ }
+func (u *fakeUpdater) Discard() {}
+
+func TestExecuteOnlyAppliesChangedConfiguration(t *testing.T) {
+	provider := &fakeProvider{frontends: []*sites.Frontend{{Domain: "www.example.com"}}}
+	updater := &fakeUpdater{}
+
+	cmd := New(getconfig.New(provider), updatecfg.New(updater))
+
+	if model, err := cmd.Execute(); err != nil || model == nil {
+		t.Fatalf("expected initial configuration to be applied, got %v, %v", model, err)
+	}
+
+	// a fresh but equal configuration is not applied again
+	provider.frontends = []*sites.Frontend{{Domain: "www.example.com"}}
+
+	if model, err := cmd.Execute(); err != nil || model != nil {
+		t.Fatalf("expected unchanged configuration to be skipped, got %v, %v", model, err)
+	}
+
+	provider.frontends = []*sites.Frontend{{Domain: "api.example.com"}}
+
+	if model, err := cmd.Execute(); err != nil || model == nil {
+		t.Fatalf("expected changed configuration to be applied, got %v, %v", model, err)
+	}
+
+	if updater.updates != 2 {
+		t.Fatalf("expected 2 updates, got %d", updater.updates)
+	}
+}
+
+func TestExecuteRetriesFailedConfiguration(t *testing.T) {
+	provider := &fakeProvider{frontends: []*sites.Frontend{{Domain: "www.example.com"}}}
+	updater := &fakeUpdater{err: errors.New("invalid")}
+
+	cmd := New(getconfig.New(provider), updatecfg.New(updater))
+
+	if _, err := cmd.Execute(); err == nil {
+		t.Fatal("expected error")
+	}
+
+	updater.err = nil
+
+	if model, err := cmd.Execute(); err != nil || model == nil {
+		t.Fatalf("expected configuration to be applied after failure, got %v, %v", model, err)
+	}
+}
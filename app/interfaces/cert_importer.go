@@ -0,0 +1,16 @@
+package interfaces
+
+import (
+	"github.com/off-sync/platform-proxy/domain/certs"
+)
+
+// CertImporter allows storage of certificates that originate from
+// another store, without losing the metadata kept by that store.
+type CertImporter interface {
+	CertSaver
+
+	// Import stores a certificate entry, preserving its metadata.
+	// Requires an active save token claimed for the domains of the entry.
+	// It returns ErrInvalidSavetoken if an invalid save token is provided.
+	Import(token CertSaveToken, entry *certs.Entry) error
+}
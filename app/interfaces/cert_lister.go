@@ -0,0 +1,11 @@
+package interfaces
+
+import (
+	"github.com/off-sync/platform-proxy/domain/certs"
+)
+
+// CertLister allows the enumeration of all certificates in a store.
+type CertLister interface {
+	// List returns all entries in the store, including their metadata.
+	List() ([]*certs.Entry, error)
+}
@@ -0,0 +1,42 @@
+package interfaces
+
+import (
+	"errors"
+
+	"github.com/off-sync/platform-proxy/domain/certs"
+)
+
+// ErrCertNotFound is returned when an action is performed on a
+// certificate that does not exist.
+var ErrCertNotFound = errors.New("certificate not found")
+
+// CertRevoker allows the revocation of certificates with the issuer.
+type CertRevoker interface {
+	// Revoke revokes the certificate for the provided reason. The request
+	// is authorized using the account key, or using the private key of the
+	// certificate itself if useCertKey is set.
+	Revoke(crt *certs.Certificate, reason certs.RevocationReason, useCertKey bool) error
+}
+
+// CertRevocationMarker allows recording the revocation of certificates
+// in a store. Revoked certificates are no longer returned by Load, which
+// causes them to be re-issued.
+type CertRevocationMarker interface {
+	// MarkRevoked marks the certificate for a list of domains as revoked.
+	// It returns ErrCertNotFound if no certificate is stored for the domains.
+	MarkRevoked(domains []string) error
+}
+
+// RevokedCertLister lists the certificates marked as revoked in a store.
+type RevokedCertLister interface {
+	// ListRevoked returns the domains of all certificates marked as revoked.
+	// It does not read the certificates themselves.
+	ListRevoked() ([][]string, error)
+}
+
+// CertCache holds certificates in memory to prevent loading them
+// from the store on each use.
+type CertCache interface {
+	// Evict removes the certificate for a list of domains from the cache.
+	Evict(domains []string)
+}
@@ -10,7 +10,7 @@ type ConfigProvider interface {
 	// If a 'true' value is received from this channel, the configuration
 	// must be updated. 'False' values should be ignored, and can be
 	// used to implement a heartbeat mechanism.
-	GetNotificationChannel() chan<- bool
+	GetNotificationChannel() <-chan bool
 
 	// GetBackends returns all backends that should be configured.
 	GetBackends() ([]*sites.Backend, error)
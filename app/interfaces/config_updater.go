@@ -11,15 +11,44 @@ import (
 var ErrUnknownBackend = errors.New("unknown backend")
 
 // ErrDuplicateDomain is returned when an action would result in
-// a domain to be configured more than once.
+// a domain and path to be configured more than once.
 var ErrDuplicateDomain = errors.New("duplicate domain")
 
+// ErrDuplicatePort is returned when an action would result in multiple
+// raw TCP frontends listening on the same port.
+var ErrDuplicatePort = errors.New("duplicate port")
+
+// ErrInvalidFrontend is returned when an action would result in a
+// frontend with an unsupported combination of settings.
+var ErrInvalidFrontend = errors.New("invalid frontend")
+
+// ConfigUpdate defines a prepared configuration update. It must either be
+// applied or discarded.
+type ConfigUpdate interface {
+	// Apply replaces the current configuration with the prepared one.
+	Apply()
+
+	// Discard releases the prepared configuration, leaving the current
+	// configuration in place.
+	Discard()
+}
+
 // ConfigUpdater defines the interface through which the proxy
 // configuration can be updated.
 type ConfigUpdater interface {
-	// Update updates the configuration by replacing it with the provided
-	// backends and frontends.
+	// Prepare validates the provided backends and frontends and prepares
+	// replacing the configuration with them, without changing the current
+	// configuration. Updates must not be prepared concurrently.
 	// It returns ErrUnknownBackend if a frontend is included for which the backend is unknown.
-	// It returns ErrDuplicateDomain if multiple frontends use the same domain.
-	Update(backends []*sites.Backend, frontends []*sites.Frontend) error
+	// Frontends without a backend name are allowed if all their requests are handled by rules.
+	// Frontends splitting requests over several backends ignore their backend name.
+	// It returns ErrDuplicateDomain if multiple frontends use the same domain
+	// and path rules.
+	// Wildcard and regular expression domains of several frontends can match
+	// the same host: they are matched in order of priority, and in the order
+	// in which they are provided if their priority and path rules are equal.
+	// Implementations log a warning for such overlapping domains.
+	// Implementations handling a subset of the frontends, such as only
+	// HTTP or only layer 4 frontends, ignore the other frontends.
+	Prepare(backends []*sites.Backend, frontends []*sites.Frontend) (ConfigUpdate, error)
 }
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/off-sync/platform-proxy/app/certs/qry/listcerts"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// listCerts lists all certificates in the store.
+func listCerts(args []string) {
+	flags := flag.NewFlagSet("list", flag.ExitOnError)
+	output := flags.String("o", outputTable, "output format: table or json")
+	cluster := flags.String("cluster", os.Getenv("ECS_CLUSTER"), "AWS ECS cluster used to detect orphaned certificates")
+	flags.Parse(args)
+
+	infos, err := newListCertsQry(*cluster).Execute(listcerts.Model{})
+	if err != nil {
+		log.WithError(err).Fatal("listing certificates")
+	}
+
+	switch *output {
+	case outputTable:
+		printTable(infos)
+	case outputJSON:
+		printJSON(infos)
+	default:
+		log.WithField("output", *output).Fatal("unknown output format")
+	}
+}
+
+// inspectCert shows the details of all certificates covering a domain.
+func inspectCert(args []string) {
+	flags := flag.NewFlagSet("inspect", flag.ExitOnError)
+	output := flags.String("o", outputTable, "output format: table or json")
+	cluster := flags.String("cluster", os.Getenv("ECS_CLUSTER"), "AWS ECS cluster used to detect orphaned certificates")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		log.Fatal("missing domain: provide exactly 1")
+	}
+
+	infos, err := newListCertsQry(*cluster).Execute(listcerts.Model{Domain: flags.Arg(0)})
+	if err != nil {
+		log.WithError(err).Fatal("inspecting certificate")
+	}
+
+	if len(infos) < 1 {
+		log.WithField("domain", flags.Arg(0)).Fatal("no certificate found")
+	}
+
+	switch *output {
+	case outputTable:
+		for _, info := range infos {
+			printDetails(info)
+		}
+	case outputJSON:
+		printJSON(infos)
+	default:
+		log.WithField("output", *output).Fatal("unknown output format")
+	}
+}
+
+func printTable(infos []*listcerts.CertInfo) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(w, "DOMAINS\tISSUER\tKEY TYPE\tNOT BEFORE\tNOT AFTER\tDAYS\tSAVE TOKEN\tORPHANED")
+	for _, info := range infos {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%v\n",
+			strings.Join(info.Domains, ","),
+			valueOrDash(info.Issuer),
+			valueOrDash(info.KeyType),
+			formatTime(info.NotBefore),
+			formatTime(info.NotAfter),
+			formatDays(info),
+			formatSaveToken(info),
+			info.Orphaned)
+	}
+
+	w.Flush()
+}
+
+func printDetails(info *listcerts.CertInfo) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(w, "Domains:\t%s\n", strings.Join(info.Domains, ", "))
+	fmt.Fprintf(w, "DNS names:\t%s\n", valueOrDash(strings.Join(info.DNSNames, ", ")))
+	fmt.Fprintf(w, "Issuer:\t%s\n", valueOrDash(info.Issuer))
+	fmt.Fprintf(w, "Serial number:\t%s\n", valueOrDash(info.SerialNumber))
+	fmt.Fprintf(w, "Key type:\t%s\n", valueOrDash(info.KeyType))
+	fmt.Fprintf(w, "Not before:\t%s\n", formatTime(info.NotBefore))
+	fmt.Fprintf(w, "Not after:\t%s\n", formatTime(info.NotAfter))
+	fmt.Fprintf(w, "Days remaining:\t%s\n", formatDays(info))
+	fmt.Fprintf(w, "Created:\t%s\n", formatTime(info.Created))
+	fmt.Fprintf(w, "Modified:\t%s\n", formatTime(info.Modified))
+	fmt.Fprintf(w, "Revoked:\t%s\n", formatTime(info.Revoked))
+	fmt.Fprintf(w, "Save token:\t%s\n", formatSaveToken(info))
+	fmt.Fprintf(w, "Orphaned:\t%v\n", info.Orphaned)
+
+	if info.ParseError != "" {
+		fmt.Fprintf(w, "Parse error:\t%s\n", info.ParseError)
+	}
+
+	fmt.Fprintln(w)
+	w.Flush()
+}
+
+func printJSON(infos []*listcerts.CertInfo) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+
+	if err := enc.Encode(infos); err != nil {
+		log.WithError(err).Fatal("encoding certificates")
+	}
+}
+
+func valueOrDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+
+	return t.UTC().Format(time.RFC3339)
+}
+
+func formatDays(info *listcerts.CertInfo) string {
+	if info.Empty || info.NotBefore.IsZero() {
+		return "-"
+	}
+
+	return fmt.Sprintf("%d", info.DaysRemaining)
+}
+
+func formatSaveToken(info *listcerts.CertInfo) string {
+	if !info.SaveTokenClaimed {
+		return "-"
+	}
+
+	return "claimed until " + formatTime(info.SaveTokenExpiresAt)
+}
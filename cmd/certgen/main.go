@@ -8,12 +8,17 @@ import (
 	"github.com/Sirupsen/logrus"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ecs"
 	"github.com/off-sync/platform-proxy/app/certs/cmd/gencert"
+	"github.com/off-sync/platform-proxy/app/certs/cmd/revokecert"
 	"github.com/off-sync/platform-proxy/app/certs/qry/getcert"
+	"github.com/off-sync/platform-proxy/app/certs/qry/listcerts"
+	"github.com/off-sync/platform-proxy/app/interfaces"
 	certsCom "github.com/off-sync/platform-proxy/common/certs"
 	"github.com/off-sync/platform-proxy/common/logging"
 	"github.com/off-sync/platform-proxy/domain/certs"
 	"github.com/off-sync/platform-proxy/infra/acmestore"
+	"github.com/off-sync/platform-proxy/infra/awsecs"
 	"github.com/off-sync/platform-proxy/infra/certgen"
 	"github.com/off-sync/platform-proxy/infra/certstore"
 	"github.com/off-sync/platform-proxy/infra/time"
@@ -23,6 +28,10 @@ var log = logging.NewFromLogrus(logrus.New())
 
 var getCertQry *getcert.Qry
 var genCertCmd *gencert.Cmd
+var revokeCertCmd *revokecert.Cmd
+
+var awsSession *session.Session
+var certLister interfaces.CertLister
 
 func init() {
 	// create infra implementations
@@ -31,7 +40,7 @@ func init() {
 	// 	log.WithError(err).Fatal("creating certificates file system")
 	// }
 
-	//certStore := certstore.NewFileSystemCertStore(certFS)
+	//certStore := certstore.NewFileSystemCertStore(certFS, time.NewSystemTime())
 
 	sess, err := session.NewSession(&aws.Config{Region: aws.String("eu-west-1")})
 	if err != nil {
@@ -50,7 +59,13 @@ func init() {
 		log.WithError(err).Fatal("creating new DynamodDB ACME store")
 	}
 
-	acmeAccount, err := acmeStore.Load(certgen.LetsEncryptProductionEndpoint, "hosting@off-sync.com")
+	// the ACME endpoint can be overridden to test against e.g. Pebble
+	acmeEndpoint := os.Getenv("ACME_ENDPOINT")
+	if acmeEndpoint == "" {
+		acmeEndpoint = certgen.LetsEncryptProductionEndpoint
+	}
+
+	acmeAccount, err := acmeStore.Load(acmeEndpoint, "hosting@off-sync.com")
 	if err != nil {
 		log.WithError(err).Fatal("loading ACME account")
 	}
@@ -63,10 +78,63 @@ func init() {
 	// create certificate commands and queries
 	getCertQry = getcert.New(certStore)
 	genCertCmd = gencert.New(certGen, certStore)
+	// certificates are revoked using RFC 8555, also for ACME v1 accounts;
+	// proxies evict them from their cache on their next revocation check
+	certRevoker, err := certgen.NewACMERevoker(acmeAccount, certgen.RevocationDirectory(acmeEndpoint))
+	if err != nil {
+		log.WithError(err).Fatal("creating ACME revoker")
+	}
+
+	revokeCertCmd = revokecert.New(certStore, certRevoker, certStore, nil)
+
+	awsSession = sess
+	certLister = certStore
+}
+
+// newListCertsQry creates the List Certificates query. The services of the
+// provided AWS ECS cluster are used to detect orphaned certificates; none
+// are detected if the cluster is empty.
+func newListCertsQry(cluster string) *listcerts.Qry {
+	var provider interfaces.ConfigProvider
+	if cluster != "" {
+		ecsProvider, err := awsecs.New(ecs.New(awsSession), cluster)
+		if err != nil {
+			log.WithError(err).Warn("creating AWS ECS config provider: orphaned certificates will not be detected")
+		} else {
+			provider = ecsProvider
+		}
+	}
+
+	return listcerts.New(certLister, provider, time.NewSystemTime())
 }
 
 func main() {
-	domains := os.Args[1:]
+	args := os.Args[1:]
+
+	cmd := "get"
+	if len(args) > 0 {
+		switch args[0] {
+		case "get", "list", "inspect", "revoke":
+			cmd = args[0]
+			args = args[1:]
+		}
+	}
+
+	switch cmd {
+	case "get":
+		getCert(args)
+	case "list":
+		listCerts(args)
+	case "inspect":
+		inspectCert(args)
+	case "revoke":
+		revokeCert(args)
+	}
+}
+
+// getCert loads the certificate for the provided domains, or generates
+// it if it does not exist yet.
+func getCert(domains []string) {
 	if len(domains) < 1 {
 		log.Fatal("missing domains: provide at least 1")
 	}
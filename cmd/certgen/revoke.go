@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/off-sync/platform-proxy/app/certs/cmd/revokecert"
+	"github.com/off-sync/platform-proxy/domain/certs"
+)
+
+// revokeCert revokes the certificate for the provided domains. The proxy
+// evicts it from its certificate cache on its next revocation check
+// (PROXY_REVOCATION_CHECK_INTERVAL), after which a new certificate is
+// generated on its next use.
+func revokeCert(args []string) {
+	flags := flag.NewFlagSet("revoke", flag.ExitOnError)
+	reasonName := flags.String("reason", certs.Unspecified.String(), "RFC 5280 revocation reason, e.g. keyCompromise or superseded")
+	useCertKey := flags.Bool("cert-key", false, "sign the revocation request using the certificate key instead of the account key")
+	flags.Parse(args)
+
+	domains := flags.Args()
+	if len(domains) < 1 {
+		log.Fatal("missing domains: provide at least 1")
+	}
+
+	reason, err := certs.ParseRevocationReason(*reasonName)
+	if err != nil {
+		log.WithError(err).Fatal("parsing revocation reason")
+	}
+
+	err = revokeCertCmd.Execute(revokecert.Model{
+		Domains:    domains,
+		Reason:     reason,
+		UseCertKey: *useCertKey,
+	})
+	if err != nil {
+		log.
+			WithField("domains", domains).
+			WithError(err).
+			Fatal("revoking certificate")
+	}
+
+	log.
+		WithField("domains", domains).
+		WithField("reason", reason.String()).
+		Info("certificate revoked")
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/off-sync/platform-proxy/app/certs/cmd/migratecerts"
+	"github.com/off-sync/platform-proxy/common/logging"
+)
+
+var log = logging.NewFromLogrus(logrus.New())
+
+func main() {
+	src := flag.String("src", "", "source certificate store URL")
+	dst := flag.String("dst", "", "destination certificate store URL")
+	region := flag.String("region", "eu-west-1", "AWS region of DynamoDB stores")
+	dryRun := flag.Bool("dry-run", false, "only report the certificates that would be copied")
+	resume := flag.Bool("resume", false, "skip certificates already present in the destination store, or renewed there")
+	flag.Parse()
+
+	if *src == "" || *dst == "" {
+		log.Fatal("missing store: provide both -src and -dst")
+	}
+
+	srcStore, err := openCertStore(*src, *region)
+	if err != nil {
+		log.WithError(err).Fatal("opening source certificate store")
+	}
+
+	dstStore, err := openCertStore(*dst, *region)
+	if err != nil {
+		log.WithError(err).Fatal("opening destination certificate store")
+	}
+
+	migrateCertsCmd := migratecerts.New(srcStore, dstStore, dstStore)
+
+	results, err := migrateCertsCmd.Execute(migratecerts.Model{
+		DryRun: *dryRun,
+		Resume: *resume,
+	})
+	if err != nil {
+		log.WithError(err).Fatal("migrating certificates")
+	}
+
+	counts := make(map[migratecerts.Outcome]int)
+	for _, result := range results {
+		counts[result.Outcome]++
+
+		entryLog := log.
+			WithField("domains", result.Entry.Domains).
+			WithField("not_after", result.Entry.NotAfter).
+			WithField("outcome", result.Outcome.String())
+
+		if result.Err != nil {
+			entryLog.WithError(result.Err).Error("migrating certificate")
+			continue
+		}
+
+		entryLog.Info("migrating certificate")
+	}
+
+	log.
+		WithField("dry_run", *dryRun).
+		WithField("copied", counts[migratecerts.Copied]).
+		WithField("skipped", counts[migratecerts.Skipped]).
+		WithField("empty", counts[migratecerts.Empty]).
+		WithField("failed", counts[migratecerts.Failed]).
+		Info("migration completed")
+
+	if counts[migratecerts.Failed] > 0 {
+		log.Fatal("not all certificates have been migrated: rerun with -resume to retry")
+	}
+}
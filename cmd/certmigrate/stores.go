@@ -0,0 +1,96 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	_ "github.com/lib/pq"           // PostgreSQL driver
+	_ "github.com/mattn/go-sqlite3" // SQLite driver
+	"github.com/off-sync/platform-proxy/app/interfaces"
+	"github.com/off-sync/platform-proxy/common/sqlutil"
+	"github.com/off-sync/platform-proxy/infra/certstore"
+	"github.com/off-sync/platform-proxy/infra/filesystem"
+	"github.com/off-sync/platform-proxy/infra/time"
+)
+
+const defaultSQLTableName = "certificates"
+
+// certStore combines the interfaces required from both the source and
+// destination stores.
+type certStore interface {
+	interfaces.CertLister
+	interfaces.CertImporter
+	interfaces.CertLoader
+}
+
+// openCertStore opens the certificate store described by the provided URL:
+//
+//	dynamodb://<table>
+//	file:///<directory>
+//	sqlite:///<file>[?table=<table>]
+//	postgres://<user>:<password>@<host>/<database>[?table=<table>&sslmode=...]
+func openCertStore(storeURL string, region string) (certStore, error) {
+	u, err := url.Parse(storeURL)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "dynamodb":
+		sess, err := session.NewSession(&aws.Config{Region: aws.String(region)})
+		if err != nil {
+			return nil, err
+		}
+
+		store, err := certstore.NewDynamoDBCertStore(sess, u.Host, time.NewSystemTime())
+		if err != nil {
+			return nil, err
+		}
+
+		return store, nil
+
+	case "file":
+		fs, err := filesystem.NewLocalFileSystem(filesystem.Root(u.Path))
+		if err != nil {
+			return nil, err
+		}
+
+		return certstore.NewFileSystemCertStore(fs, time.NewSystemTime()), nil
+
+	case "sqlite", "postgres":
+		query := u.Query()
+
+		tableName := query.Get("table")
+		if tableName == "" {
+			tableName = defaultSQLTableName
+		}
+
+		query.Del("table")
+		u.RawQuery = query.Encode()
+
+		var db *sql.DB
+		var dialect sqlutil.Dialect
+		if u.Scheme == "sqlite" {
+			db, err = sql.Open("sqlite3", u.Path)
+			dialect = sqlutil.SQLite
+		} else {
+			db, err = sql.Open("postgres", u.String())
+			dialect = sqlutil.Postgres
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		store, err := certstore.NewSQLCertStore(db, dialect, tableName, time.NewSystemTime())
+		if err != nil {
+			return nil, err
+		}
+
+		return store, nil
+	}
+
+	return nil, fmt.Errorf("unsupported certificate store: %s", storeURL)
+}
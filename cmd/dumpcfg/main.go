@@ -1,14 +1,13 @@
 package main
 
 import (
-	"net"
-
 	"github.com/Sirupsen/logrus"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ecs"
 	"github.com/off-sync/platform-proxy/app/interfaces"
 	"github.com/off-sync/platform-proxy/infra/awsecs"
+	"github.com/off-sync/platform-proxy/infra/resolver"
 )
 
 var log = logrus.New()
@@ -39,7 +38,7 @@ func main() {
 			Info("backend configuration")
 
 		for _, server := range backend.Servers {
-			addrs, err := net.LookupHost(server.Hostname())
+			addrs, err := resolver.Lookup(server)
 			if err != nil {
 				log.
 					WithField("server", server).
@@ -47,9 +46,14 @@ func main() {
 					Fatal("looking up server host")
 			}
 
+			var hosts []string
+			for _, addr := range addrs {
+				hosts = append(hosts, addr.Host)
+			}
+
 			log.
 				WithField("server", server).
-				WithField("addrs", addrs).
+				WithField("addrs", hosts).
 				Info("server hostname lookup successful")
 		}
 	}
@@ -63,6 +67,11 @@ func main() {
 		log.
 			WithField("domain", frontend.Domain).
 			WithField("backend_name", frontend.BackendName).
+			WithField("cert_group", frontend.CertGroup).
+			WithField("path_prefix", frontend.PathPrefix).
+			WithField("path_regex", frontend.PathRegex).
+			WithField("strip_prefix", frontend.StripPrefix).
+			WithField("priority", frontend.Priority).
 			Info("frontend configuration")
 	}
 }
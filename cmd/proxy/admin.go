@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/off-sync/platform-proxy/infra/router"
+)
+
+// serveAdmin serves the admin endpoints. They must not be reachable from
+// the internet: bind the address to localhost or a private network.
+func serveAdmin(addr string, rtr *router.Router) {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/servers", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, rtr.ServerHealth())
+	})
+
+	mux.HandleFunc("/circuits", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, rtr.CircuitStates())
+	})
+
+	mux.HandleFunc("/mirrors", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, rtr.MirrorStats())
+	})
+
+	srv := &http.Server{
+		Addr:    addr,
+		Handler: mux,
+	}
+
+	log.WithField("addr", addr).Info("listening for admin requests")
+
+	if err := srv.ListenAndServe(); err != nil {
+		log.
+			WithError(err).
+			Fatal("listening and serving admin requests")
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	if err := enc.Encode(v); err != nil {
+		log.WithError(err).Warn("writing admin response")
+	}
+}
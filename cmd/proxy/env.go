@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// envString returns the value of an environment variable, or the default
+// value if it is not set.
+func envString(key, def string) string {
+	if value, found := os.LookupEnv(key); found {
+		return value
+	}
+
+	return def
+}
+
+// envBool returns the boolean value of an environment variable, or the default
+// value if it is not set. It exits if the value is not a valid boolean.
+func envBool(key string, def bool) bool {
+	value, found := os.LookupEnv(key)
+	if !found {
+		return def
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.WithField("key", key).WithError(err).Fatal("parsing environment variable")
+	}
+
+	return b
+}
+
+// envInt returns the integer value of an environment variable, or the default
+// value if it is not set. It exits if the value is not a valid integer.
+func envInt(key string, def int) int {
+	value, found := os.LookupEnv(key)
+	if !found {
+		return def
+	}
+
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		log.WithField("key", key).WithError(err).Fatal("parsing environment variable")
+	}
+
+	return i
+}
+
+// envDuration returns the duration value of an environment variable, or the
+// default value if it is not set. It exits if the value is not a valid duration.
+func envDuration(key string, def time.Duration) time.Duration {
+	value, found := os.LookupEnv(key)
+	if !found {
+		return def
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.WithField("key", key).WithError(err).Fatal("parsing environment variable")
+	}
+
+	return d
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/off-sync/platform-proxy/infra/router"
+)
+
+// serveHTTP listens for plain HTTP requests, redirecting them to HTTPS
+// and answering ACME HTTP-01 challenges.
+func serveHTTP(addr string, rtr *router.Router) {
+	status := envInt("PROXY_HTTP_REDIRECT_STATUS", http.StatusMovedPermanently)
+	if status != http.StatusMovedPermanently && status != http.StatusPermanentRedirect {
+		log.WithField("status", status).Fatal("unsupported redirect status: use 301 or 308")
+	}
+
+	var challenges http.Handler
+	if http01Provider != nil {
+		challenges = http01Provider
+	}
+
+	srv := newServer(addr, router.NewHTTPSRedirectHandler(rtr, status, envString("PROXY_HTTPS_REDIRECT_PORT", ""), challenges))
+
+	log.WithField("addr", addr).Info("listening for HTTP requests")
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.
+			WithError(err).
+			Fatal("listening")
+	}
+
+	if err := srv.Serve(wrapProxyProtocol("http", ln)); err != nil {
+		log.
+			WithError(err).
+			Fatal("listening and serving HTTP")
+	}
+}
@@ -4,9 +4,12 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 
+	"github.com/off-sync/platform-proxy/app/certs/cmd/evictrevoked"
 	"github.com/off-sync/platform-proxy/app/certs/cmd/gencert"
 	"github.com/off-sync/platform-proxy/app/certs/qry/getcert"
+	"github.com/off-sync/platform-proxy/domain/certs"
 	"github.com/off-sync/platform-proxy/infra/acmestore"
+	"github.com/off-sync/platform-proxy/infra/certcache"
 	"github.com/off-sync/platform-proxy/infra/certgen"
 	"github.com/off-sync/platform-proxy/infra/certstore"
 	"github.com/off-sync/platform-proxy/infra/time"
@@ -14,15 +17,29 @@ import (
 
 var getCertQry *getcert.Qry
 var genCertCmd *gencert.Cmd
+var evictRevokedCmd *evictrevoked.Cmd
+
+// certGrouping defines how the domains of frontends are combined into certificates.
+var certGrouping certs.GroupingPolicy
+
+// http01Provider holds the HTTP-01 challenge provider if HTTP-01 challenges
+// are used instead of DNS-01 challenges.
+var http01Provider *certgen.HTTP01Provider
 
 func init() {
+	var err error
+	certGrouping, err = certs.ParseGroupingPolicy(envString("PROXY_CERT_GROUPING", "none"))
+	if err != nil {
+		log.WithError(err).Fatal("parsing certificate grouping policy")
+	}
+
 	// create infra implementations
 	// certFS, err := filesystem.NewLocalFileSystem(filesystem.Root("C:\\Temp\\LocalCertStore"))
 	// if err != nil {
 	// 	log.WithError(err).Fatal("creating certificates file system")
 	// }
 
-	// certStore := certstore.NewFileSystemCertStore(certFS)
+	// certStore := certstore.NewFileSystemCertStore(certFS, time.NewSystemTime())
 
 	sess, err := session.NewSession(&aws.Config{Region: aws.String("eu-west-1")})
 	if err != nil {
@@ -46,12 +63,28 @@ func init() {
 		log.WithError(err).Fatal("loading ACME account")
 	}
 
-	certGen, err := certgen.NewLegoACMECertGen(acmeAccount, log)
+	var certGenOptions []certgen.LegoACMECertGenOption
+
+	switch challenge := envString("PROXY_ACME_CHALLENGE", "dns-01"); challenge {
+	case "dns-01":
+	case "http-01":
+		http01Provider = certgen.NewHTTP01Provider()
+		certGenOptions = append(certGenOptions, certgen.HTTP01(http01Provider))
+	default:
+		log.WithField("challenge", challenge).Fatal("unsupported ACME challenge")
+	}
+
+	certGen, err := certgen.NewLegoACMECertGen(acmeAccount, log, certGenOptions...)
 	if err != nil {
 		panic(err)
 	}
 
+	// cache certificates in memory: revoked certificates are evicted
+	// by evictRevokedCmd, after which they are re-issued
+	certCache := certcache.NewMemoryCertCache(certStore, certcache.DefaultTTL, time.NewSystemTime())
+
 	// create certificate commands and queries
-	getCertQry = getcert.New(certStore)
+	getCertQry = getcert.New(certCache)
 	genCertCmd = gencert.New(certGen, certStore)
+	evictRevokedCmd = evictrevoked.New(certStore, certCache)
 }
@@ -1,27 +1,50 @@
 package main
 
 import (
+	"time"
+
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ecs"
 	"github.com/off-sync/platform-proxy/app/config/qry/getconfig"
+	"github.com/off-sync/platform-proxy/app/interfaces"
 	"github.com/off-sync/platform-proxy/infra/awsecs"
+	"github.com/off-sync/platform-proxy/infra/fileconfig"
 )
 
-var getConfigQry *getconfig.Qry
+var (
+	getConfigQry        *getconfig.Qry
+	configNotifications <-chan bool
+)
 
 func init() {
-	sess, err := session.NewSession()
-	if err != nil {
-		log.WithError(err).Fatal("creating new session")
-	}
+	var provider interfaces.ConfigProvider
+
+	reloadInterval := envDuration("PROXY_CONFIG_RELOAD_INTERVAL", 30*time.Second)
+
+	if path := envString("PROXY_CONFIG_FILE", ""); path != "" {
+		fileProvider, err := fileconfig.New(path, fileconfig.PollInterval(reloadInterval))
+		if err != nil {
+			log.WithError(err).Fatal("creating file config provider")
+		}
+
+		provider = fileProvider
+	} else {
+		sess, err := session.NewSession()
+		if err != nil {
+			log.WithError(err).Fatal("creating new session")
+		}
+
+		ecsSvc := ecs.New(sess, &aws.Config{Region: aws.String("eu-west-1")})
 
-	ecsSvc := ecs.New(sess, &aws.Config{Region: aws.String("eu-west-1")})
+		ecsProvider, err := awsecs.New(ecsSvc, "off-sync-qa", awsecs.PollInterval(reloadInterval))
+		if err != nil {
+			log.WithError(err).Fatal("creating AWS ECS config provider")
+		}
 
-	provider, err := awsecs.New(ecsSvc, "off-sync-qa")
-	if err != nil {
-		log.WithError(err).Fatal("creating AWS ECS config provider")
+		provider = ecsProvider
 	}
 
 	getConfigQry = getconfig.New(provider)
+	configNotifications = provider.GetNotificationChannel()
 }
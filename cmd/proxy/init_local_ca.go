@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io/ioutil"
+
+	"github.com/off-sync/platform-proxy/infra/certgen"
+	"github.com/off-sync/platform-proxy/infra/router"
+)
+
+// localCA issues the client certificate of the proxy for backends which
+// require one issued by the local CA. It is nil if no local CA is set.
+var localCA *certgen.LocalCACertGen
+
+func init() {
+	certFile := envString("PROXY_LOCAL_CA_CERT_FILE", "")
+	keyFile := envString("PROXY_LOCAL_CA_KEY_FILE", "")
+	if certFile == "" && keyFile == "" {
+		return
+	}
+
+	certPEM, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		log.WithError(err).Fatal("reading PROXY_LOCAL_CA_CERT_FILE")
+	}
+
+	keyPEM, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		log.WithError(err).Fatal("reading PROXY_LOCAL_CA_KEY_FILE")
+	}
+
+	localCA, err = certgen.NewLocalCA(certPEM, keyPEM)
+	if err != nil {
+		log.WithError(err).Fatal("creating local CA")
+	}
+}
+
+// localCAOption returns the router option issuing client certificates using
+// the local CA, or nil if no local CA is set.
+func localCAOption() router.Option {
+	if localCA == nil {
+		return nil
+	}
+
+	return router.LocalCA(localCA, envString("PROXY_LOCAL_CA_CLIENT_NAME", "platform-proxy"))
+}
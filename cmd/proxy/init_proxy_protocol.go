@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net"
+	"strings"
+
+	"github.com/off-sync/platform-proxy/infra/proxyproto"
+)
+
+// proxyProtocolListeners holds the names of the listeners requiring PROXY
+// protocol headers from trusted sources: https, http and tcp (raw TCP
+// frontends).
+var proxyProtocolListeners = make(map[string]bool)
+
+var proxyProtocolTrusted []*net.IPNet
+
+func init() {
+	cidrs := envString("PROXY_PROXY_PROTOCOL_CIDRS", "")
+	if cidrs == "" {
+		return
+	}
+
+	var err error
+	proxyProtocolTrusted, err = proxyproto.ParseCIDRs(cidrs)
+	if err != nil {
+		log.WithError(err).Fatal("parsing PROXY_PROXY_PROTOCOL_CIDRS")
+	}
+
+	for _, name := range strings.Split(envString("PROXY_PROXY_PROTOCOL_LISTENERS", "https,http,tcp"), ",") {
+		name = strings.TrimSpace(name)
+
+		switch name {
+		case "https", "http", "tcp":
+			proxyProtocolListeners[name] = true
+		default:
+			log.WithField("listener", name).Fatal("unknown listener: use https, http or tcp")
+		}
+	}
+}
+
+// wrapProxyProtocol requires PROXY protocol headers from trusted sources
+// on the listener, if enabled for listeners with the provided name.
+func wrapProxyProtocol(name string, ln net.Listener) net.Listener {
+	if !proxyProtocolListeners[name] {
+		return ln
+	}
+
+	log.
+		WithField("listener", name).
+		WithField("trusted", proxyProtocolTrusted).
+		Info("requiring PROXY protocol headers from trusted sources")
+
+	return proxyproto.NewListener(ln, proxyProtocolTrusted)
+}
@@ -4,26 +4,56 @@ import (
 	"crypto/tls"
 	"net"
 	"net/http"
-	"net/url"
+	"time"
 
 	"fmt"
 
 	"github.com/Sirupsen/logrus"
-	"github.com/gorilla/mux"
 	"github.com/off-sync/platform-proxy/app/certs/cmd/gencert"
+	"github.com/off-sync/platform-proxy/app/certs/cmd/updatecfg"
 	"github.com/off-sync/platform-proxy/app/certs/qry/getcert"
+	"github.com/off-sync/platform-proxy/app/config/cmd/reloadcfg"
 	"github.com/off-sync/platform-proxy/common/certs"
 	"github.com/off-sync/platform-proxy/common/logging"
-	"github.com/vulcand/oxy/forward"
-	"github.com/vulcand/oxy/roundrobin"
+	"github.com/off-sync/platform-proxy/common/tlsconfig"
+	"github.com/off-sync/platform-proxy/infra/router"
+	"github.com/off-sync/platform-proxy/infra/tcpproxy"
 )
 
 var log = logging.NewFromLogrus(logrus.New())
 
 func main() {
+	certGroups := &certGroupIndex{}
+
+	resolveInterval := envDuration("PROXY_RESOLVE_INTERVAL", 30*time.Second)
+
+	routerOptions := []router.Option{router.ResolveInterval(resolveInterval)}
+	if secret := envString("PROXY_STICKY_SECRET", ""); secret != "" {
+		routerOptions = append(routerOptions, router.StickySecret([]byte(secret)))
+	}
+
+	if option := localCAOption(); option != nil {
+		routerOptions = append(routerOptions, option)
+	}
+
+	rtr := router.New(log, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "<h1>%s</h1>\n<pre>", r.Host)
+
+		for name, values := range r.Header {
+			fmt.Fprintf(w, "%s: %v\n", name, values)
+		}
+
+		fmt.Fprint(w, "</pre>\n")
+	}), routerOptions...)
+
 	getCertificateFunc := func(chi *tls.ClientHelloInfo) (*tls.Certificate, error) {
-		domains := make([]string, 1)
-		domains[0] = chi.ServerName
+		// certificates are only issued for hosts routed to a frontend
+		if chi.ServerName == "" || !rtr.KnownHost(chi.ServerName) {
+			return nil, fmt.Errorf("no frontend for server name '%s'", chi.ServerName)
+		}
+
+		// any name in a group resolves to the certificate of the group
+		domains := certGroups.Domains(chi.ServerName)
 
 		crt, err := getCertQry.Execute(getcert.Model{Domains: domains})
 		if err != nil {
@@ -53,89 +83,52 @@ func main() {
 		return tlsCrt, nil
 	}
 
-	backends, frontends, err := getConfigQry.Execute()
-	if err != nil {
-		log.WithError(err).Fatal("getting configuration")
-	}
-
-	r := mux.NewRouter()
-
-	backendHandlers := make(map[string]http.Handler)
-	for _, backend := range backends {
-		fwd, err := forward.New()
-		if err != nil {
-			log.WithError(err).Fatal("creating forwarder")
-		}
-
-		lb, err := roundrobin.New(fwd)
-		if err != nil {
-			log.WithError(err).Fatal("creating load balancer")
-		}
+	tcpProxy := tcpproxy.New(log,
+		tcpproxy.ResolveInterval(resolveInterval),
+		tcpproxy.WrapTCPListeners(func(ln net.Listener) net.Listener {
+			return wrapProxyProtocol("tcp", ln)
+		}))
 
-		for _, server := range backend.Servers {
-			addrs, err := net.LookupHost(server.Hostname())
-			if err != nil {
-				log.
-					WithField("server", server).
-					WithError(err).
-					Fatal("looking up server host")
-			}
+	reloadCfgCmd := reloadcfg.New(getConfigQry, updatecfg.New(tcpProxy, rtr))
 
-			for _, addr := range addrs {
-				u := &url.URL{}
-				*u = *server
-				u.Host = fmt.Sprintf("%s:%s", addr, server.Port())
+	model, err := reloadCfgCmd.Execute()
+	if err != nil {
+		log.WithError(err).Fatal("updating configuration")
+	}
 
-				log.
-					WithField("server", server).
-					WithField("addr", u).
-					Info("adding server")
+	certGroups.update(model.Frontends)
 
-				lb.UpsertServer(u)
-			}
-		}
+	go reloadConfig(reloadCfgCmd, certGroups)
 
-		backendHandlers[backend.Name] = lb
+	if interval := envDuration("PROXY_REVOCATION_CHECK_INTERVAL", time.Minute); interval > 0 {
+		go evictRevokedCerts(interval)
 	}
 
-	for _, frontend := range frontends {
-		handler, exists := backendHandlers[frontend.BackendName]
-		if !exists {
-			log.WithField("backend_name", frontend.BackendName).Fatal("unknown backend name")
-		}
+	if adminAddr := envString("PROXY_ADMIN_ADDR", ""); adminAddr != "" {
+		go serveAdmin(adminAddr, rtr)
+	}
 
-		r.Host(frontend.Domain).Handler(handler)
+	if httpAddr := envString("PROXY_HTTP_ADDR", ""); httpAddr != "" {
+		go serveHTTP(httpAddr, rtr)
 	}
 
-	r.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprintf(w, "<h1>%s</h1>\n<pre>", r.Host)
+	http2 := envBool("PROXY_HTTP2", true)
 
-		for name, values := range r.Header {
-			fmt.Fprintf(w, "%s: %v\n", name, values)
-		}
+	srv := newServer(":8443", rtr)
+	srv.TLSConfig = tlsconfig.WithClientAuth(tlsconfig.New(http2, getCertificateFunc), rtr.ClientCAs)
+	srv.TLSNextProto = tlsconfig.NextProto(http2)
 
-		fmt.Fprint(w, "</pre>\n")
-	})
-
-	srv := &http.Server{
-		Addr:    ":8443",
-		Handler: r,
-		TLSConfig: &tls.Config{
-			MinVersion:               tls.VersionTLS12,
-			CurvePreferences:         []tls.CurveID{tls.CurveP521, tls.CurveP384, tls.CurveP256},
-			PreferServerCipherSuites: true,
-			CipherSuites: []uint16{
-				tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
-				tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
-				tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
-				tls.TLS_RSA_WITH_AES_256_CBC_SHA,
-			},
-			GetCertificate: getCertificateFunc,
-		},
-		TLSNextProto: make(map[string]func(*http.Server, *tls.Conn, http.Handler), 0),
+	ln, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		log.
+			WithError(err).
+			Fatal("listening")
 	}
 
-	if err := srv.ListenAndServeTLS("", ""); err != nil {
+	ln = wrapProxyProtocol("https", ln)
+
+	// connections for passthrough frontends never reach the TLS listener
+	if err := srv.Serve(tls.NewListener(tcpProxy.Listen(ln), srv.TLSConfig)); err != nil {
 		log.
 			WithError(err).
 			Fatal("listening and serving TLS")
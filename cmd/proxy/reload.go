@@ -0,0 +1,60 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/off-sync/platform-proxy/app/config/cmd/reloadcfg"
+	certsDom "github.com/off-sync/platform-proxy/domain/certs"
+	"github.com/off-sync/platform-proxy/domain/sites"
+)
+
+// certGroupIndex guards the certificate group index, which is replaced when
+// the configuration is reloaded while handshakes are reading it.
+type certGroupIndex struct {
+	sync.RWMutex
+	index *certsDom.GroupIndex
+}
+
+func (i *certGroupIndex) update(frontends []*sites.Frontend) {
+	index := certsDom.NewGroupIndex(certGrouping, frontends)
+
+	i.Lock()
+	i.index = index
+	i.Unlock()
+}
+
+// Domains returns the domains of the certificate serving serverName.
+func (i *certGroupIndex) Domains(serverName string) []string {
+	i.RLock()
+	defer i.RUnlock()
+
+	return i.index.Domains(serverName)
+}
+
+// reloadConfig reloads the configuration on every notification of the
+// config provider. A configuration which fails to apply is logged and leaves
+// the current configuration in place.
+func reloadConfig(reloadCfgCmd *reloadcfg.Cmd, certGroups *certGroupIndex) {
+	for update := range configNotifications {
+		if !update {
+			continue
+		}
+
+		model, err := reloadCfgCmd.Execute()
+		if err != nil {
+			log.WithError(err).Error("reloading configuration")
+			continue
+		}
+
+		if model == nil {
+			continue
+		}
+
+		certGroups.update(model.Frontends)
+
+		log.
+			WithField("backends", len(model.Backends)).
+			WithField("frontends", len(model.Frontends)).
+			Info("reloaded configuration")
+	}
+}
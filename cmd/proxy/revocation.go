@@ -0,0 +1,23 @@
+package main
+
+import (
+	"time"
+)
+
+// evictRevokedCerts periodically evicts certificates revoked by other
+// processes, such as 'certgen revoke', from the certificate cache.
+func evictRevokedCerts(interval time.Duration) {
+	for range time.Tick(interval) {
+		evicted, err := evictRevokedCmd.Execute()
+		if err != nil {
+			log.WithError(err).Error("evicting revoked certificates")
+			continue
+		}
+
+		for _, domains := range evicted {
+			log.
+				WithField("domains", domains).
+				Debug("evicted revoked certificate")
+		}
+	}
+}
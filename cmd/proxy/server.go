@@ -0,0 +1,21 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+// newServer creates a server with the limits set using environment
+// variables, so clients cannot keep connections open by sending their
+// requests slowly.
+func newServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: envDuration("PROXY_READ_HEADER_TIMEOUT", 10*time.Second),
+		ReadTimeout:       envDuration("PROXY_READ_TIMEOUT", 0),
+		WriteTimeout:      envDuration("PROXY_WRITE_TIMEOUT", 0),
+		IdleTimeout:       envDuration("PROXY_IDLE_TIMEOUT", 120*time.Second),
+		MaxHeaderBytes:    envInt("PROXY_MAX_HEADER_BYTES", http.DefaultMaxHeaderBytes),
+	}
+}
@@ -2,7 +2,6 @@ package certs
 
 import (
 	"crypto/tls"
-	"crypto/x509"
 	"encoding/pem"
 	"fmt"
 
@@ -25,14 +24,9 @@ func ConvertToTLS(crt *certsDom.Certificate) (*tls.Certificate, error) {
 		return nil, fmt.Errorf("unable to decode certificate(s)")
 	}
 
-	p, _ = pem.Decode(crt.PrivateKey)
-	if p == nil {
-		return nil, fmt.Errorf("unable to decode private key")
-	}
-
-	key, err := x509.ParsePKCS1PrivateKey(p.Bytes)
+	key, err := DecodePrivateKey(crt.PrivateKey)
 	if err != nil {
-		return nil, fmt.Errorf("parsing private key: %s", err)
+		return nil, fmt.Errorf("decoding private key: %s", err)
 	}
 
 	return &tls.Certificate{
@@ -13,19 +13,25 @@ var emptyTime = time.Time{}
 // NotAfter tries to parse the certificate and returns the NotAfter property
 // if successful.
 func NotAfter(crt *certsDom.Certificate) (time.Time, error) {
-	tlsCrt, err := ConvertToTLS(crt)
+	asnCrt, err := ParseLeaf(crt)
 	if err != nil {
 		return emptyTime, err
 	}
 
-	if len(tlsCrt.Certificate) < 1 {
-		return emptyTime, fmt.Errorf("no certificates found: %s", string(crt.Certificate))
-	}
+	return asnCrt.NotAfter, nil
+}
 
-	asnCrt, err := x509.ParseCertificate(tlsCrt.Certificate[0])
+// ParseLeaf tries to parse the first certificate of the chain, which
+// is the certificate issued for the domains.
+func ParseLeaf(crt *certsDom.Certificate) (*x509.Certificate, error) {
+	tlsCrt, err := ConvertToTLS(crt)
 	if err != nil {
-		return emptyTime, err
+		return nil, err
 	}
 
-	return asnCrt.NotAfter, nil
+	if len(tlsCrt.Certificate) < 1 {
+		return nil, fmt.Errorf("no certificates found: %s", string(crt.Certificate))
+	}
+
+	return x509.ParseCertificate(tlsCrt.Certificate[0])
 }
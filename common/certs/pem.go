@@ -1,13 +1,19 @@
 package certs
 
 import (
+	"crypto"
+	"crypto/ecdsa"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
 )
 
-const rsaPrivateKeyType = "RSA PRIVATE KEY"
+const (
+	rsaPrivateKeyType   = "RSA PRIVATE KEY"
+	ecPrivateKeyType    = "EC PRIVATE KEY"
+	pkcs8PrivateKeyType = "PRIVATE KEY"
+)
 
 // EncodeRSAPrivateKey encodes a RSA private key to PEM bytes.
 func EncodeRSAPrivateKey(key *rsa.PrivateKey) []byte {
@@ -30,3 +36,35 @@ func DecodeRSAPrivateKey(data []byte) (*rsa.PrivateKey, error) {
 
 	return x509.ParsePKCS1PrivateKey(b.Bytes)
 }
+
+// DecodePrivateKey decodes a RSA or ECDSA private key from PEM bytes.
+// PKCS #1, SEC 1 and PKCS #8 encoded keys are supported.
+func DecodePrivateKey(data []byte) (crypto.Signer, error) {
+	b, _ := pem.Decode(data)
+	if b == nil {
+		return nil, fmt.Errorf("PEM block not found")
+	}
+
+	switch b.Type {
+	case rsaPrivateKeyType:
+		return x509.ParsePKCS1PrivateKey(b.Bytes)
+	case ecPrivateKeyType:
+		return x509.ParseECPrivateKey(b.Bytes)
+	case pkcs8PrivateKeyType:
+		key, err := x509.ParsePKCS8PrivateKey(b.Bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		switch key := key.(type) {
+		case *rsa.PrivateKey:
+			return key, nil
+		case *ecdsa.PrivateKey:
+			return key, nil
+		}
+
+		return nil, fmt.Errorf("unsupported private key type: %T", key)
+	}
+
+	return nil, fmt.Errorf("invalid PEM block type: %s", b.Type)
+}
@@ -0,0 +1,60 @@
+package certs
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+)
+
+func TestDecodePrivateKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ecDER, err := x509.MarshalECPrivateKey(ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkcs8DER, err := x509.MarshalPKCS8PrivateKey(ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		key  crypto.Signer
+	}{
+		{"PKCS #1", EncodeRSAPrivateKey(rsaKey), rsaKey},
+		{"SEC 1", pem.EncodeToMemory(&pem.Block{Type: ecPrivateKeyType, Bytes: ecDER}), ecKey},
+		{"PKCS #8", pem.EncodeToMemory(&pem.Block{Type: pkcs8PrivateKeyType, Bytes: pkcs8DER}), ecKey},
+	}
+
+	for _, test := range tests {
+		key, err := DecodePrivateKey(test.data)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+
+		if !key.Public().(interface{ Equal(crypto.PublicKey) bool }).Equal(test.key.Public()) {
+			t.Errorf("%s: decoded key does not match", test.name)
+		}
+	}
+
+	if _, err := DecodePrivateKey(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE"})); err == nil {
+		t.Error("expected error for a certificate PEM block")
+	}
+}
@@ -24,8 +24,36 @@ func StringValue(a *dynamodb.AttributeValue) string {
 	return aws.StringValue(a.S)
 }
 
+// StringListAttr stores the strings as a list, which preserves their order.
 func StringListAttr(s []string) *dynamodb.AttributeValue {
-	return &dynamodb.AttributeValue{SS: aws.StringSlice(s)}
+	l := make([]*dynamodb.AttributeValue, len(s))
+	for i := range s {
+		l[i] = &dynamodb.AttributeValue{S: aws.String(s[i])}
+	}
+
+	return &dynamodb.AttributeValue{L: l}
+}
+
+// StringListValue returns the strings of a list in their stored order,
+// and whether that order is known. String sets, as written by earlier
+// versions, have no order: their values are returned in the order in which
+// they are read, and the caller must restore the order, for example using
+// a hash of the ordered strings.
+func StringListValue(a *dynamodb.AttributeValue) ([]string, bool) {
+	if a == nil {
+		return nil, true
+	}
+
+	if a.SS != nil {
+		return aws.StringValueSlice(a.SS), false
+	}
+
+	s := make([]string, len(a.L))
+	for i := range a.L {
+		s[i] = aws.StringValue(a.L[i].S)
+	}
+
+	return s, true
 }
 
 func TimeAttr(t time.Time) *dynamodb.AttributeValue {
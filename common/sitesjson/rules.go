@@ -0,0 +1,53 @@
+package sitesjson
+
+import (
+	"encoding/json"
+
+	"github.com/off-sync/platform-proxy/domain/sites"
+)
+
+// Rule defines the JSON representation of a frontend rule.
+type Rule struct {
+	Action      string `json:"action"`
+	PathRegex   string `json:"path_regex"`
+	Target      string `json:"target"`
+	Status      int    `json:"status"`
+	Body        string `json:"body"`
+	ContentType string `json:"content_type"`
+}
+
+// ParseRules decodes a JSON array of rules.
+func ParseRules(data []byte) ([]*sites.Rule, error) {
+	var rules []*Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, err
+	}
+
+	return ConvertRules(rules)
+}
+
+// ConvertRules converts the JSON representation of rules to rules.
+func ConvertRules(rules []*Rule) ([]*sites.Rule, error) {
+	converted := make([]*sites.Rule, len(rules))
+	for i, r := range rules {
+		action, err := sites.ParseRuleAction(r.Action)
+		if err != nil {
+			return nil, err
+		}
+
+		converted[i] = &sites.Rule{
+			Action:      action,
+			PathRegex:   r.PathRegex,
+			Target:      r.Target,
+			Status:      r.Status,
+			Body:        r.Body,
+			ContentType: r.ContentType,
+		}
+
+		if err := converted[i].Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return converted, nil
+}
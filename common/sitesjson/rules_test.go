@@ -0,0 +1,30 @@
+package sitesjson
+
+import (
+	"testing"
+)
+
+func TestParseRulesValidatesRedirectStatus(t *testing.T) {
+	tests := []struct {
+		rules string
+		valid bool
+	}{
+		{`[{"action": "redirect", "target": "/new"}]`, true},
+		{`[{"action": "redirect", "target": "/new", "status": 302}]`, true},
+		{`[{"action": "redirect", "target": "/new", "status": 308}]`, true},
+		{`[{"action": "redirect", "target": "/new", "status": 200}]`, false},
+		{`[{"action": "redirect", "target": "/new", "status": 304}]`, false},
+		{`[{"action": "static", "body": "ok", "status": 404}]`, true},
+	}
+
+	for _, test := range tests {
+		_, err := ParseRules([]byte(test.rules))
+		if test.valid && err != nil {
+			t.Errorf("%s: unexpected error: %s", test.rules, err)
+		}
+
+		if !test.valid && err == nil {
+			t.Errorf("%s: expected an error", test.rules)
+		}
+	}
+}
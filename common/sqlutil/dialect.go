@@ -0,0 +1,63 @@
+package sqlutil
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Dialect defines the differences between the supported SQL databases.
+type Dialect interface {
+	// Name returns the name of the dialect.
+	Name() string
+
+	// Rebind converts a query using '?' placeholders to the placeholder
+	// syntax of the dialect.
+	Rebind(query string) string
+}
+
+// SQLite is the dialect for SQLite databases.
+var SQLite Dialect = &sqliteDialect{}
+
+// Postgres is the dialect for PostgreSQL databases.
+var Postgres Dialect = &postgresDialect{}
+
+// DialectByName returns the dialect with the provided name.
+func DialectByName(name string) (Dialect, error) {
+	switch strings.ToLower(name) {
+	case "sqlite", "sqlite3":
+		return SQLite, nil
+	case "postgres", "postgresql":
+		return Postgres, nil
+	}
+
+	return nil, fmt.Errorf("unsupported SQL dialect: %s", name)
+}
+
+type sqliteDialect struct{}
+
+func (d *sqliteDialect) Name() string { return "sqlite" }
+
+func (d *sqliteDialect) Rebind(query string) string { return query }
+
+type postgresDialect struct{}
+
+func (d *postgresDialect) Name() string { return "postgres" }
+
+func (d *postgresDialect) Rebind(query string) string {
+	var b bytes.Buffer
+
+	n := 0
+	for _, r := range query {
+		if r != '?' {
+			b.WriteRune(r)
+			continue
+		}
+
+		n++
+		b.WriteString("$" + strconv.Itoa(n))
+	}
+
+	return b.String()
+}
@@ -0,0 +1,47 @@
+package sqlutil
+
+import "testing"
+
+func TestPostgresRebind(t *testing.T) {
+	tests := []struct {
+		query, expected string
+	}{
+		{"SELECT 1", "SELECT 1"},
+		{"SELECT * FROM t WHERE a = ?", "SELECT * FROM t WHERE a = $1"},
+		{"UPDATE t SET a = ?, b = ? WHERE c = ? AND d > ?", "UPDATE t SET a = $1, b = $2 WHERE c = $3 AND d > $4"},
+	}
+
+	for _, test := range tests {
+		if actual := Postgres.Rebind(test.query); actual != test.expected {
+			t.Errorf("Rebind(%q): expected %q, got %q", test.query, test.expected, actual)
+		}
+
+		if actual := SQLite.Rebind(test.query); actual != test.query {
+			t.Errorf("SQLite Rebind(%q) should not change the query, got %q", test.query, actual)
+		}
+	}
+}
+
+func TestQueryExpandsTableAndRebinds(t *testing.T) {
+	actual := Query(Postgres, "certs", "SELECT hash FROM {table} WHERE hash = ? AND not_after > ?")
+	expected := "SELECT hash FROM certs WHERE hash = $1 AND not_after > $2"
+
+	if actual != expected {
+		t.Fatalf("expected %q, got %q", expected, actual)
+	}
+}
+
+func TestDialectByName(t *testing.T) {
+	for name, expected := range map[string]Dialect{
+		"sqlite": SQLite, "SQLite3": SQLite, "postgres": Postgres, "postgresql": Postgres,
+	} {
+		d, err := DialectByName(name)
+		if err != nil || d != expected {
+			t.Errorf("DialectByName(%q): expected %s, got %v, %v", name, expected.Name(), d, err)
+		}
+	}
+
+	if _, err := DialectByName("mysql"); err == nil {
+		t.Error("expected error for unsupported dialect")
+	}
+}
@@ -0,0 +1,44 @@
+package sqlutil
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+var validTableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidTableName returns whether the provided name can safely be used
+// as a table name in queries.
+func ValidTableName(name string) bool {
+	return validTableName.MatchString(name)
+}
+
+func expandTable(query, table string) string {
+	return strings.Replace(query, "{table}", table, -1)
+}
+
+// Query expands the '{table}' string in the query to the provided table name
+// and rebinds its placeholders for the dialect.
+func Query(d Dialect, table, query string) string {
+	return d.Rebind(expandTable(query, table))
+}
+
+// TimeValue converts a time to its stored representation: the number of
+// seconds since the Unix epoch in UTC, or 0 for the zero time.
+func TimeValue(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+
+	return t.UTC().Unix()
+}
+
+// ParseTime converts a stored time back into a time in UTC.
+func ParseTime(s int64) time.Time {
+	if s == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(s, 0).UTC()
+}
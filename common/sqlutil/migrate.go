@@ -0,0 +1,74 @@
+package sqlutil
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Migration defines a single schema change. Statements are executed in order
+// within one transaction. The string '{table}' in a statement is replaced by
+// the table name provided to Migrate.
+type Migration struct {
+	Version    int
+	Statements []string
+}
+
+// Migrate brings the schema of a table up to date by applying all migrations
+// with a version higher than the one recorded in the schema_migrations table.
+// Migrations must be provided in ascending order of version.
+func Migrate(db *sql.DB, d Dialect, table string, migrations []Migration) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+	table_name VARCHAR(255) NOT NULL,
+	version INTEGER NOT NULL,
+	PRIMARY KEY (table_name, version)
+)`)
+	if err != nil {
+		return fmt.Errorf("creating schema_migrations table: %s", err)
+	}
+
+	var current int
+	err = db.QueryRow(
+		d.Rebind("SELECT COALESCE(MAX(version), 0) FROM schema_migrations WHERE table_name = ?"),
+		table).Scan(&current)
+	if err != nil {
+		return fmt.Errorf("reading schema version of %s: %s", table, err)
+	}
+
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+
+		if err := apply(db, d, table, m); err != nil {
+			return fmt.Errorf("applying migration %d to %s: %s", m.Version, table, err)
+		}
+
+		current = m.Version
+	}
+
+	return nil
+}
+
+func apply(db *sql.DB, d Dialect, table string, m Migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	for _, stmt := range m.Statements {
+		if _, err := tx.Exec(expandTable(stmt, table)); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	_, err = tx.Exec(
+		d.Rebind("INSERT INTO schema_migrations (table_name, version) VALUES (?, ?)"),
+		table, m.Version)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,77 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+)
+
+// New creates the TLS configuration of a HTTPS listener. If HTTP/2 is
+// enabled it is offered using ALPN, and the cipher suites are restricted to
+// those allowed by HTTP/2 (RFC 7540, appendix A).
+func New(http2 bool, getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)) *tls.Config {
+	cfg := &tls.Config{
+		MinVersion:               tls.VersionTLS12,
+		CurvePreferences:         []tls.CurveID{tls.CurveP521, tls.CurveP384, tls.CurveP256},
+		PreferServerCipherSuites: true,
+		GetCertificate:           getCertificate,
+	}
+
+	if http2 {
+		cfg.NextProtos = []string{"h2", "http/1.1"}
+		cfg.CipherSuites = []uint16{
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		}
+	} else {
+		cfg.NextProtos = []string{"http/1.1"}
+		cfg.CipherSuites = []uint16{
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+			tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+		}
+	}
+
+	return cfg
+}
+
+// WithClientAuth requests client certificates during the handshake of
+// connections for server names with frontends authenticating clients,
+// accepting the CAs returned for the server name.
+func WithClientAuth(cfg *tls.Config, clientCAs func(serverName string) (*x509.CertPool, bool)) *tls.Config {
+	cfg.GetConfigForClient = func(chi *tls.ClientHelloInfo) (*tls.Config, error) {
+		pool, required := clientCAs(chi.ServerName)
+		if pool == nil {
+			// use the configuration as is
+			return nil, nil
+		}
+
+		c := cfg.Clone()
+		c.GetConfigForClient = nil
+		c.ClientCAs = pool
+		c.ClientAuth = tls.VerifyClientCertIfGiven
+		if required {
+			c.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+
+		// a resumed session must not skip the verification against the
+		// current CAs
+		c.SessionTicketsDisabled = true
+
+		return c, nil
+	}
+
+	return cfg
+}
+
+// NextProto returns the TLSNextProto setting of the server: nil
+// enables HTTP/2, an empty map disables it.
+func NextProto(http2 bool) map[string]func(*http.Server, *tls.Conn, http.Handler) {
+	if http2 {
+		return nil
+	}
+
+	return make(map[string]func(*http.Server, *tls.Conn, http.Handler), 0)
+}
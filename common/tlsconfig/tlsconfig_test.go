@@ -0,0 +1,99 @@
+package tlsconfig
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func newTestCertificate(t *testing.T) *tls.Certificate {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestTLSListenerProtocols(t *testing.T) {
+	cert := newTestCertificate(t)
+	getCertificate := func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		return cert, nil
+	}
+
+	tests := []struct {
+		http2    bool
+		maxTLS   uint16
+		expected string
+	}{
+		{true, 0, "HTTP/2.0"},
+		{false, 0, "HTTP/1.1"},
+		// the cipher suites only apply to TLS 1.2
+		{true, tls.VersionTLS12, "HTTP/2.0"},
+		{false, tls.VersionTLS12, "HTTP/1.1"},
+	}
+
+	for _, test := range tests {
+		srv := &http.Server{
+			Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(r.Proto))
+			}),
+			TLSConfig:    New(test.http2, getCertificate),
+			TLSNextProto: NextProto(test.http2),
+		}
+
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		go srv.Serve(tls.NewListener(ln, srv.TLSConfig))
+
+		client := &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{
+					InsecureSkipVerify: true,
+					MaxVersion:         test.maxTLS,
+				},
+				ForceAttemptHTTP2: true,
+			},
+		}
+
+		resp, err := client.Get("https://" + ln.Addr().String() + "/")
+		if err != nil {
+			t.Fatalf("http2 %v, TLS %x: %s", test.http2, test.maxTLS, err)
+		}
+
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if string(body) != test.expected || resp.Proto != test.expected {
+			t.Errorf("http2 %v, TLS %x: expected %s, got %s (client %s)", test.http2, test.maxTLS, test.expected, body, resp.Proto)
+		}
+
+		srv.Close()
+	}
+}
@@ -0,0 +1,35 @@
+package certs
+
+import "time"
+
+// Entry defines a certificate as it is kept by a certificate store,
+// together with the metadata maintained by that store.
+type Entry struct {
+	// Domains holds the domains for which this certificate is applicable.
+	Domains []string
+
+	// Created holds the time in UTC at which the certificate was added to the store.
+	Created time.Time
+
+	// Modified holds the time in UTC at which the certificate was last modified.
+	Modified time.Time
+
+	// NotAfter holds the expiry date in UTC for the certificate.
+	NotAfter time.Time
+
+	// Revoked holds the time in UTC at which the certificate was revoked.
+	// It is the zero time if the certificate has not been revoked.
+	Revoked time.Time
+
+	// SaveTokenExpiresAt holds the time in UTC until which the current save
+	// token is valid. It is the zero time if no save token has been claimed.
+	SaveTokenExpiresAt time.Time
+
+	// Certificate holds the certificate itself. It is nil if a save token
+	// has been claimed for the domains, but no certificate has been saved yet.
+	Certificate *Certificate
+
+	// Err holds the error encountered while reading the entry from the store.
+	// If it is set, only the domains are set, and they may be out of order.
+	Err error
+}
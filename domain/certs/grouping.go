@@ -0,0 +1,123 @@
+package certs
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/off-sync/platform-proxy/domain/sites"
+)
+
+// GroupingPolicy defines how the domains of frontends are combined into
+// certificates with multiple subject alternative names (SANs).
+type GroupingPolicy int
+
+const (
+	// GroupNone requests a single-domain certificate per frontend.
+	GroupNone GroupingPolicy = iota
+
+	// GroupByBackend requests one certificate covering all domains
+	// of the frontends of a backend.
+	GroupByBackend
+
+	// GroupByName requests one certificate covering all domains of the
+	// frontends sharing a CertGroup. Frontends without a CertGroup get
+	// a single-domain certificate.
+	GroupByName
+)
+
+// ParseGroupingPolicy returns the grouping policy with the provided name:
+// none, backend or name.
+func ParseGroupingPolicy(name string) (GroupingPolicy, error) {
+	switch strings.ToLower(name) {
+	case "", "none":
+		return GroupNone, nil
+	case "backend":
+		return GroupByBackend, nil
+	case "name":
+		return GroupByName, nil
+	}
+
+	return GroupNone, fmt.Errorf("unknown certificate grouping policy: %s", name)
+}
+
+// GroupIndex maps server names to the domains of the certificate
+// that should be served for them.
+type GroupIndex struct {
+	groups map[string][]string
+}
+
+// NewGroupIndex creates an index of the certificate groups for the provided
+// frontends. The domains of each group are sorted, so the same group always
+// results in the same list of domains.
+func NewGroupIndex(policy GroupingPolicy, frontends []*sites.Frontend) *GroupIndex {
+	byKey := make(map[string][]string)
+	for _, frontend := range frontends {
+		if frontend.HostKind() != sites.ExactHost {
+			// no certificate is requested for a regular expression or a
+			// wildcard, as wildcard certificates require the DNS-01
+			// challenge of ACME v2: matching hosts get a single-domain
+			// certificate
+			continue
+		}
+
+		if frontend.IsLayer4() {
+			// TLS is not terminated by the proxy
+			continue
+		}
+
+		var key string
+		switch policy {
+		case GroupByBackend:
+			key = "backend:" + frontend.BackendName
+		case GroupByName:
+			if frontend.CertGroup != "" {
+				key = "group:" + frontend.CertGroup
+			}
+		}
+
+		if key == "" {
+			key = "domain:" + frontend.Domain
+		}
+
+		byKey[key] = appendUnique(byKey[key], strings.ToLower(frontend.Domain))
+	}
+
+	index := &GroupIndex{
+		groups: make(map[string][]string),
+	}
+
+	for _, domains := range byKey {
+		sort.Strings(domains)
+
+		for _, domain := range domains {
+			index.groups[domain] = domains
+		}
+	}
+
+	return index
+}
+
+// Domains returns the domains of the certificate to serve for the provided
+// server name. A server name which is not part of any group, such as a host
+// matched by a wildcard or regular expression domain, results in a
+// single-domain certificate.
+func (i *GroupIndex) Domains(serverName string) []string {
+	serverName = strings.ToLower(serverName)
+
+	if domains, found := i.groups[serverName]; found {
+		return domains
+	}
+
+	return []string{serverName}
+}
+
+func appendUnique(domains []string, domain string) []string {
+	for _, d := range domains {
+		if d == domain {
+			return domains
+		}
+	}
+
+	return append(domains, domain)
+}
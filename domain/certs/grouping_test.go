@@ -0,0 +1,35 @@
+package certs
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/off-sync/platform-proxy/domain/sites"
+)
+
+func TestGroupIndexSkipsWildcardAndRegexDomains(t *testing.T) {
+	frontends := []*sites.Frontend{
+		sites.NewFrontend("web", "example.com"),
+		sites.NewFrontend("web", "www.example.com"),
+		sites.NewFrontend("web", "*.example.com"),
+		sites.NewFrontend("web", `~^api\.`),
+	}
+
+	index := NewGroupIndex(GroupByBackend, frontends)
+
+	tests := []struct {
+		serverName string
+		expected   []string
+	}{
+		{"www.example.com", []string{"example.com", "www.example.com"}},
+		{"EXAMPLE.com", []string{"example.com", "www.example.com"}},
+		{"shop.example.com", []string{"shop.example.com"}},
+		{"api.example.org", []string{"api.example.org"}},
+	}
+
+	for _, test := range tests {
+		if actual := index.Domains(test.serverName); !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.serverName, test.expected, actual)
+		}
+	}
+}
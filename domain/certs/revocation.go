@@ -0,0 +1,48 @@
+package certs
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RevocationReason defines the reason for revoking a certificate,
+// using the reason codes defined in RFC 5280, section 5.3.1.
+type RevocationReason int
+
+// Revocation reasons supported by ACME providers.
+const (
+	Unspecified          RevocationReason = 0
+	KeyCompromise        RevocationReason = 1
+	AffiliationChanged   RevocationReason = 3
+	Superseded           RevocationReason = 4
+	CessationOfOperation RevocationReason = 5
+)
+
+var revocationReasonNames = map[RevocationReason]string{
+	Unspecified:          "unspecified",
+	KeyCompromise:        "keyCompromise",
+	AffiliationChanged:   "affiliationChanged",
+	Superseded:           "superseded",
+	CessationOfOperation: "cessationOfOperation",
+}
+
+// String returns the RFC 5280 name of the reason.
+func (r RevocationReason) String() string {
+	if name, found := revocationReasonNames[r]; found {
+		return name
+	}
+
+	return fmt.Sprintf("reason(%d)", int(r))
+}
+
+// ParseRevocationReason returns the revocation reason with the provided
+// RFC 5280 name. The name is matched case-insensitively.
+func ParseRevocationReason(name string) (RevocationReason, error) {
+	for reason, n := range revocationReasonNames {
+		if strings.EqualFold(n, name) {
+			return reason, nil
+		}
+	}
+
+	return Unspecified, fmt.Errorf("unknown revocation reason: %s", name)
+}
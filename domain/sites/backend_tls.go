@@ -0,0 +1,29 @@
+package sites
+
+// BackendTLS defines how TLS connections to the https servers of a backend
+// are verified and authenticated.
+type BackendTLS struct {
+	// CAFile optionally holds the path of a PEM encoded bundle of CA
+	// certificates used to verify the servers. The system roots are used
+	// if it is not set.
+	CAFile string
+	// ServerName optionally overrides the host name sent using SNI and
+	// used to verify the server certificates. By default the host name
+	// of the server is used.
+	ServerName string
+	// CertFile and KeyFile optionally hold the paths of the PEM encoded
+	// client certificate and private key sent to the servers.
+	CertFile string
+	KeyFile  string
+	// LocalCAClientCert sends a client certificate issued by the local CA
+	// of the proxy, instead of the certificate in CertFile.
+	LocalCAClientCert bool
+	// InsecureSkipVerify disables verifying the server certificates. Only
+	// use it for development.
+	InsecureSkipVerify bool
+}
+
+// HasClientCert returns whether a client certificate is sent to the servers.
+func (t *BackendTLS) HasClientCert() bool {
+	return t.LocalCAClientCert || t.CertFile != ""
+}
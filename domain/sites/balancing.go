@@ -0,0 +1,99 @@
+package sites
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Algorithm defines how a backend distributes requests over its servers.
+type Algorithm int
+
+const (
+	// RoundRobin sends requests to the servers in turn, in proportion to
+	// their weights.
+	RoundRobin Algorithm = iota
+
+	// LeastConnections sends requests to the server with the fewest
+	// requests in progress relative to its weight.
+	LeastConnections
+
+	// PowerOfTwoChoices sends requests to the least loaded of two
+	// randomly selected servers.
+	PowerOfTwoChoices
+
+	// ConsistentHash sends requests with the same hash key to the same
+	// server, moving few keys when servers are added or removed.
+	ConsistentHash
+)
+
+// ParseAlgorithm returns the algorithm with the provided name: round-robin,
+// least-conn, p2c or consistent-hash. An empty name returns RoundRobin.
+func ParseAlgorithm(name string) (Algorithm, error) {
+	switch strings.ToLower(name) {
+	case "", "round-robin":
+		return RoundRobin, nil
+	case "least-conn":
+		return LeastConnections, nil
+	case "p2c":
+		return PowerOfTwoChoices, nil
+	case "consistent-hash":
+		return ConsistentHash, nil
+	}
+
+	return RoundRobin, fmt.Errorf("unknown load balancing algorithm: %s", name)
+}
+
+// String returns the name of the algorithm.
+func (a Algorithm) String() string {
+	switch a {
+	case LeastConnections:
+		return "least-conn"
+	case PowerOfTwoChoices:
+		return "p2c"
+	case ConsistentHash:
+		return "consistent-hash"
+	}
+
+	return "round-robin"
+}
+
+// HashSource defines which part of a request provides its hash key.
+type HashSource int
+
+const (
+	// HashClientIP uses the IP address of the client.
+	HashClientIP HashSource = iota
+
+	// HashHeader uses the value of a request header.
+	HashHeader
+
+	// HashCookie uses the value of a cookie.
+	HashCookie
+)
+
+// HashKey defines the hash key used by the ConsistentHash algorithm.
+type HashKey struct {
+	Source HashSource
+	// Name holds the name of the header or cookie.
+	Name string
+}
+
+// ParseHashKey parses a hash key: 'ip', 'header:<name>' or 'cookie:<name>'.
+// An empty value returns a key using the client IP.
+func ParseHashKey(value string) (*HashKey, error) {
+	if value == "" || strings.ToLower(value) == "ip" {
+		return &HashKey{Source: HashClientIP}, nil
+	}
+
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) == 2 && parts[1] != "" {
+		switch strings.ToLower(parts[0]) {
+		case "header":
+			return &HashKey{Source: HashHeader, Name: parts[1]}, nil
+		case "cookie":
+			return &HashKey{Source: HashCookie, Name: parts[1]}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("invalid hash key: %s", value)
+}
@@ -0,0 +1,66 @@
+package sites
+
+import (
+	"net/http"
+	"time"
+)
+
+// Defaults used for circuit breaker settings which have not been set.
+const (
+	DefaultCircuitBreakerErrors           = 5
+	DefaultCircuitBreakerEjectDuration    = 30 * time.Second
+	DefaultCircuitBreakerHalfOpenRequests = 1
+	DefaultCircuitBreakerFallbackStatus   = http.StatusServiceUnavailable
+)
+
+// CircuitBreaker defines when servers of a backend are ejected from the
+// load balancer based on the responses to forwarded requests. A server
+// returning consecutive 5xx responses or connection errors is ejected for
+// a while, after which a limited number of requests probe whether it has
+// recovered. If no servers are available the fallback response is returned.
+type CircuitBreaker struct {
+	// Errors holds the number of consecutive errors after which a server
+	// is ejected.
+	Errors int
+	// EjectDuration defines how long a server is ejected before it is
+	// probed again.
+	EjectDuration time.Duration
+	// HalfOpenRequests holds the number of successful probe requests
+	// after which an ejected server is fully restored. It also limits
+	// the number of probe requests in progress.
+	HalfOpenRequests int
+	// FallbackStatus defines the status code of the fallback response.
+	FallbackStatus int
+	// FallbackBody optionally defines the body of the fallback response.
+	FallbackBody string
+	// FallbackContentType defines the content type of the fallback body.
+	FallbackContentType string
+}
+
+// WithDefaults returns a copy of the circuit breaker in which all settings
+// which have not been set have their default value.
+func (c *CircuitBreaker) WithDefaults() *CircuitBreaker {
+	b := *c
+
+	if b.Errors <= 0 {
+		b.Errors = DefaultCircuitBreakerErrors
+	}
+
+	if b.EjectDuration <= 0 {
+		b.EjectDuration = DefaultCircuitBreakerEjectDuration
+	}
+
+	if b.HalfOpenRequests <= 0 {
+		b.HalfOpenRequests = DefaultCircuitBreakerHalfOpenRequests
+	}
+
+	if b.FallbackStatus <= 0 {
+		b.FallbackStatus = DefaultCircuitBreakerFallbackStatus
+	}
+
+	if b.FallbackContentType == "" {
+		b.FallbackContentType = "text/plain; charset=utf-8"
+	}
+
+	return &b
+}
@@ -0,0 +1,57 @@
+package sites
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ClientAuthMode defines whether clients must present a certificate.
+type ClientAuthMode int
+
+const (
+	// ClientAuthRequired rejects requests without a valid certificate.
+	ClientAuthRequired ClientAuthMode = iota
+
+	// ClientAuthOptional verifies certificates if presented, and passes
+	// requests without a certificate to the backend.
+	ClientAuthOptional
+)
+
+// ParseClientAuthMode returns the client authentication mode with the
+// provided name: required or optional. An empty name returns
+// ClientAuthRequired.
+func ParseClientAuthMode(name string) (ClientAuthMode, error) {
+	switch strings.ToLower(name) {
+	case "", "required":
+		return ClientAuthRequired, nil
+	case "optional":
+		return ClientAuthOptional, nil
+	}
+
+	return ClientAuthRequired, fmt.Errorf("unknown client authentication mode: %s", name)
+}
+
+// String returns the name of the client authentication mode.
+func (m ClientAuthMode) String() string {
+	if m == ClientAuthOptional {
+		return "optional"
+	}
+
+	return "required"
+}
+
+// ClientAuth defines how clients of a frontend are authenticated using
+// TLS client certificates. As certificates are requested during the TLS
+// handshake, the CAs of all frontends matching the server name are
+// accepted, and each frontend verifies the certificate against its own CAs.
+type ClientAuth struct {
+	// CAFile holds the path of a PEM encoded bundle of the CA
+	// certificates which issue the client certificates.
+	CAFile string
+	// Mode defines whether clients must present a certificate.
+	Mode ClientAuthMode
+	// AllowedPatterns optionally restricts the clients to those for which
+	// the subject common name, or a DNS or email subject alternative
+	// name, fully matches one of these regular expressions.
+	AllowedPatterns []string
+}
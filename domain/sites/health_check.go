@@ -0,0 +1,71 @@
+package sites
+
+import (
+	"time"
+)
+
+// Defaults used for health check settings which have not been set.
+const (
+	DefaultHealthCheckInterval = 10 * time.Second
+	DefaultHealthCheckTimeout  = 5 * time.Second
+	DefaultHealthCheckRise     = 2
+	DefaultHealthCheckFall     = 3
+)
+
+// HealthCheck defines how the servers of a backend are checked. Servers
+// failing the check are removed from the load balancer until they pass
+// again.
+type HealthCheck struct {
+	// Path holds the path requested from each server, e.g. '/health'.
+	Path string
+	// Status optionally defines the expected status code. Any 2xx or
+	// 3xx status code passes the check if it is not set.
+	Status int
+	// Interval defines the time between checks of a server.
+	Interval time.Duration
+	// Timeout limits the duration of a single check.
+	Timeout time.Duration
+	// Rise holds the number of consecutive passed checks after which
+	// an unhealthy server is considered healthy again.
+	Rise int
+	// Fall holds the number of consecutive failed checks after which
+	// a healthy server is considered unhealthy.
+	Fall int
+}
+
+// WithDefaults returns a copy of the health check in which all settings
+// which have not been set have their default value.
+func (h *HealthCheck) WithDefaults() *HealthCheck {
+	c := *h
+
+	if c.Path == "" {
+		c.Path = "/"
+	}
+
+	if c.Interval <= 0 {
+		c.Interval = DefaultHealthCheckInterval
+	}
+
+	if c.Timeout <= 0 {
+		c.Timeout = DefaultHealthCheckTimeout
+	}
+
+	if c.Rise <= 0 {
+		c.Rise = DefaultHealthCheckRise
+	}
+
+	if c.Fall <= 0 {
+		c.Fall = DefaultHealthCheckFall
+	}
+
+	return &c
+}
+
+// Passes returns whether a response with the status code passes the check.
+func (h *HealthCheck) Passes(status int) bool {
+	if h.Status > 0 {
+		return status == h.Status
+	}
+
+	return status >= 200 && status < 400
+}
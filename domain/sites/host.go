@@ -0,0 +1,83 @@
+package sites
+
+import (
+	"net"
+	"regexp"
+	"strings"
+)
+
+// HostKind defines how the domain of a frontend is matched against the
+// host of a request. Kinds are listed in order of precedence.
+type HostKind int
+
+const (
+	// ExactHost matches a single domain name, e.g. 'www.example.com'.
+	ExactHost HostKind = iota
+
+	// WildcardHost matches any single label in place of the leading
+	// asterisk, e.g. '*.customer.example.com'.
+	WildcardHost
+
+	// RegexHost matches the regular expression following the leading
+	// tilde, e.g. '~^(www|api)\.example\.com$'.
+	RegexHost
+)
+
+const (
+	wildcardHostPrefix = "*."
+	regexHostPrefix    = "~"
+)
+
+// HostKind returns the kind of the domain of this frontend.
+func (f *Frontend) HostKind() HostKind {
+	switch {
+	case strings.HasPrefix(f.Domain, wildcardHostPrefix):
+		return WildcardHost
+	case strings.HasPrefix(f.Domain, regexHostPrefix):
+		return RegexHost
+	}
+
+	return ExactHost
+}
+
+// HostMatcher returns a function reporting whether the host of a request,
+// with or without port, matches the domain of this frontend.
+// It returns an error if the domain contains an invalid regular expression.
+func (f *Frontend) HostMatcher() (func(host string) bool, error) {
+	domain := strings.ToLower(f.Domain)
+
+	switch f.HostKind() {
+	case WildcardHost:
+		suffix := strings.TrimPrefix(domain, "*")
+
+		return func(host string) bool {
+			host = normalizeHost(host)
+
+			return strings.HasSuffix(host, suffix) &&
+				len(host) > len(suffix) &&
+				!strings.Contains(strings.TrimSuffix(host, suffix), ".")
+		}, nil
+
+	case RegexHost:
+		re, err := regexp.Compile(strings.TrimPrefix(f.Domain, regexHostPrefix))
+		if err != nil {
+			return nil, err
+		}
+
+		return func(host string) bool {
+			return re.MatchString(normalizeHost(host))
+		}, nil
+	}
+
+	return func(host string) bool {
+		return normalizeHost(host) == domain
+	}, nil
+}
+
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	return strings.ToLower(host)
+}
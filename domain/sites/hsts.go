@@ -0,0 +1,31 @@
+package sites
+
+import (
+	"fmt"
+	"time"
+)
+
+// HSTS defines the HTTP Strict Transport Security policy of a frontend.
+type HSTS struct {
+	// MaxAge defines how long browsers should only use HTTPS for the domain.
+	MaxAge time.Duration
+	// IncludeSubDomains applies the policy to all subdomains as well.
+	IncludeSubDomains bool
+	// Preload signals consent to including the domain in browser preload lists.
+	Preload bool
+}
+
+// HeaderValue returns the value of the Strict-Transport-Security header.
+func (h *HSTS) HeaderValue() string {
+	value := fmt.Sprintf("max-age=%d", int64(h.MaxAge/time.Second))
+
+	if h.IncludeSubDomains {
+		value += "; includeSubDomains"
+	}
+
+	if h.Preload {
+		value += "; preload"
+	}
+
+	return value
+}
@@ -0,0 +1,38 @@
+package sites
+
+// Defaults used for mirror settings which have not been set.
+const (
+	DefaultMirrorPercentage   = 100
+	DefaultMirrorMaxBodyBytes = 64 * 1024
+)
+
+// Mirror defines a backend receiving a copy of the requests of a frontend,
+// for example to test a new service using live traffic. The responses of
+// the mirror backend are discarded.
+type Mirror struct {
+	// BackendName specifies the name of the mirror backend.
+	BackendName string
+	// Percentage holds the percentage of requests which is mirrored,
+	// e.g. 10 for one in ten requests.
+	Percentage float64
+	// MaxBodyBytes holds the maximum size of a request body which is
+	// buffered so it can be mirrored. Requests with larger bodies, or
+	// bodies of unknown size, are not mirrored.
+	MaxBodyBytes int64
+}
+
+// WithDefaults returns a copy of the mirror settings in which all settings
+// which have not been set have their default value.
+func (m *Mirror) WithDefaults() *Mirror {
+	c := *m
+
+	if c.Percentage <= 0 || c.Percentage > 100 {
+		c.Percentage = DefaultMirrorPercentage
+	}
+
+	if c.MaxBodyBytes <= 0 {
+		c.MaxBodyBytes = DefaultMirrorMaxBodyBytes
+	}
+
+	return &c
+}
@@ -0,0 +1,209 @@
+package sites
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"unicode"
+)
+
+// Overlaps returns whether the wildcard or regular expression domains of
+// both frontends can match the same host. Exact domains never overlap, as
+// they are matched before the other kinds.
+// It returns an error if a domain contains an invalid regular expression.
+func (f *Frontend) Overlaps(other *Frontend) (bool, error) {
+	if f.HostKind() == ExactHost || other.HostKind() == ExactHost {
+		return false, nil
+	}
+
+	a, err := f.hostProg()
+	if err != nil {
+		return false, err
+	}
+
+	b, err := other.hostProg()
+	if err != nil {
+		return false, err
+	}
+
+	return intersects(a, b), nil
+}
+
+// hostProg compiles the hosts matched by a wildcard or regular expression
+// domain. Regular expressions match anywhere in the host, unless they are
+// anchored.
+func (f *Frontend) hostProg() (*syntax.Prog, error) {
+	pattern := `(?s:.*)(?:` + strings.TrimPrefix(f.Domain, regexHostPrefix) + `)(?s:.*)`
+	if f.HostKind() == WildcardHost {
+		suffix := strings.TrimPrefix(strings.ToLower(f.Domain), "*")
+		pattern = `^[^.]+` + regexp.QuoteMeta(suffix) + `$`
+	}
+
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil, err
+	}
+
+	return syntax.Compile(re.Simplify())
+}
+
+// progState is a pair of instructions of two programs, from which both
+// programs continue on the same input.
+type progState struct {
+	a, b  uint32
+	begin bool
+}
+
+// intersects returns whether some input is matched by both programs. The
+// programs are run in lockstep on every rune accepted by both, until both
+// match or all pairs of instructions have been visited. Word boundaries
+// are assumed to hold, so it may report an overlap for them which does not
+// exist.
+func intersects(a, b *syntax.Prog) bool {
+	start := progState{a: uint32(a.Start), b: uint32(b.Start), begin: true}
+
+	visited := map[progState]bool{start: true}
+	queue := []progState{start}
+
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+
+		runesA, matchA := closure(a, s.a, s.begin)
+		runesB, matchB := closure(b, s.b, s.begin)
+
+		if matchA && matchB {
+			return true
+		}
+
+		for _, ia := range runesA {
+			for _, ib := range runesB {
+				if !runesIntersect(&a.Inst[ia], &b.Inst[ib]) {
+					continue
+				}
+
+				next := progState{a: a.Inst[ia].Out, b: b.Inst[ib].Out}
+				if !visited[next] {
+					visited[next] = true
+					queue = append(queue, next)
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// closure follows the instructions not consuming input from pc. It returns
+// the instructions consuming a rune, and whether the program matches if the
+// input ends here.
+func closure(p *syntax.Prog, pc uint32, begin bool) ([]uint32, bool) {
+	var runes []uint32
+	match := false
+
+	visited := make(map[uint32]bool)
+
+	// the end of the input only satisfies the instructions leading to a match
+	var follow func(pc uint32, end bool)
+	follow = func(pc uint32, end bool) {
+		if visited[pc<<1|boolBit(end)] {
+			return
+		}
+
+		visited[pc<<1|boolBit(end)] = true
+
+		inst := &p.Inst[pc]
+		switch inst.Op {
+		case syntax.InstAlt, syntax.InstAltMatch:
+			follow(inst.Out, end)
+			follow(inst.Arg, end)
+
+		case syntax.InstCapture, syntax.InstNop:
+			follow(inst.Out, end)
+
+		case syntax.InstEmptyWidth:
+			op := syntax.EmptyOp(inst.Arg)
+			if op&(syntax.EmptyBeginLine|syntax.EmptyBeginText) != 0 && !begin {
+				return
+			}
+
+			if op&(syntax.EmptyEndLine|syntax.EmptyEndText) != 0 {
+				// only satisfied at the end of the input
+				if end {
+					follow(inst.Out, end)
+				}
+
+				return
+			}
+
+			follow(inst.Out, end)
+
+		case syntax.InstMatch:
+			match = match || end
+
+		case syntax.InstRune, syntax.InstRune1, syntax.InstRuneAny, syntax.InstRuneAnyNotNL:
+			if !end {
+				runes = append(runes, pc)
+			}
+		}
+	}
+
+	follow(pc, false)
+	follow(pc, true)
+
+	return runes, match
+}
+
+func boolBit(b bool) uint32 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
+// runesIntersect returns whether a rune exists which is accepted by both
+// instructions.
+func runesIntersect(a, b *syntax.Inst) bool {
+	for _, ra := range runeRanges(a) {
+		for _, rb := range runeRanges(b) {
+			if ra[0] <= rb[1] && rb[0] <= ra[1] {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// runeRanges returns the ranges of runes accepted by a rune instruction,
+// including the other cases of a case folded rune.
+func runeRanges(inst *syntax.Inst) [][2]rune {
+	switch inst.Op {
+	case syntax.InstRuneAny:
+		return [][2]rune{{0, unicode.MaxRune}}
+
+	case syntax.InstRuneAnyNotNL:
+		return [][2]rune{{0, '\n' - 1}, {'\n' + 1, unicode.MaxRune}}
+	}
+
+	if len(inst.Rune) == 1 {
+		r := inst.Rune[0]
+		ranges := [][2]rune{{r, r}}
+
+		if syntax.Flags(inst.Arg)&syntax.FoldCase != 0 {
+			for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+				ranges = append(ranges, [2]rune{f, f})
+			}
+		}
+
+		return ranges
+	}
+
+	var ranges [][2]rune
+	for i := 0; i+1 < len(inst.Rune); i += 2 {
+		ranges = append(ranges, [2]rune{inst.Rune[i], inst.Rune[i+1]})
+	}
+
+	return ranges
+}
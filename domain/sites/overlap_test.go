@@ -0,0 +1,40 @@
+package sites
+
+import "testing"
+
+func TestOverlaps(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected bool
+	}{
+		{"*.customer.example.com", `~^shop\.customer\.example\.com$`, true},
+		{"*.customer.example.com", `~\.customer\.example\.com$`, true},
+		{"*.customer.example.com", `~^[a-z]+\.example\.com$`, false},
+		{"*.customer.example.com", `~^a\.b\.customer\.example\.com$`, false},
+		{"*.customer.example.com", "*.shop.customer.example.com", false},
+		{"*.customer.example.com", "www.customer.example.com", false},
+		{`~^a\.`, `~\.example\.com$`, true},
+		{`~^(www|api)\.example\.com$`, `~^api\.`, true},
+		{`~^(www|api)\.example\.com$`, `~^shop\.`, false},
+		{`~^www\.example\.com$`, `~(?i)^WWW\.`, true},
+		{`~example\.org`, `~\.com$`, true},
+		{`~^example\.org$`, `~\.com$`, false},
+	}
+
+	for _, test := range tests {
+		actual, err := NewFrontend("", test.a).Overlaps(NewFrontend("", test.b))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if actual != test.expected {
+			t.Errorf("%s and %s: expected overlap %v, got %v", test.a, test.b, test.expected, actual)
+		}
+	}
+}
+
+func TestOverlapsInvalidRegex(t *testing.T) {
+	if _, err := NewFrontend("", `~(`).Overlaps(NewFrontend("", "*.example.com")); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,49 @@
+package sites
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ProxyProtocol defines which version of the PROXY protocol header, if
+// any, is sent to the backend of a layer 4 frontend. The header passes
+// the address of the client to the backend.
+type ProxyProtocol int
+
+const (
+	// ProxyProtocolNone sends no header.
+	ProxyProtocolNone ProxyProtocol = iota
+
+	// ProxyProtocolV1 sends the human-readable version 1 header.
+	ProxyProtocolV1
+
+	// ProxyProtocolV2 sends the binary version 2 header.
+	ProxyProtocolV2
+)
+
+// ParseProxyProtocol returns the PROXY protocol version with the provided
+// name: v1 or v2. An empty name returns ProxyProtocolNone.
+func ParseProxyProtocol(name string) (ProxyProtocol, error) {
+	switch strings.ToLower(name) {
+	case "", "none":
+		return ProxyProtocolNone, nil
+	case "v1":
+		return ProxyProtocolV1, nil
+	case "v2":
+		return ProxyProtocolV2, nil
+	}
+
+	return ProxyProtocolNone, fmt.Errorf("unknown PROXY protocol version: %s", name)
+}
+
+// String returns the name of the PROXY protocol version.
+func (p ProxyProtocol) String() string {
+	switch p {
+	case ProxyProtocolV1:
+		return "v1"
+	case ProxyProtocolV2:
+		return "v2"
+	}
+
+	return "none"
+}
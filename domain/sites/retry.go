@@ -0,0 +1,74 @@
+package sites
+
+import (
+	"net/http"
+)
+
+// Defaults used for retry settings which have not been set.
+const (
+	DefaultRetryAttempts     = 3
+	DefaultRetryBudget       = 0.2
+	DefaultRetryMaxBodyBytes = 64 * 1024
+)
+
+// Retry defines when requests for a backend are retried against the next
+// server. Only requests with idempotent methods are retried, on connection
+// errors and on the configured status codes.
+type Retry struct {
+	// Attempts holds the maximum number of attempts, including the
+	// first one.
+	Attempts int
+	// Statuses optionally holds the status codes on which requests are
+	// retried, e.g. 503. Connection errors are always retried.
+	Statuses []int
+	// Budget limits the number of retries to this fraction of the
+	// number of requests to the backend, e.g. 0.2 for 20%.
+	Budget float64
+	// MaxBodyBytes holds the maximum size of a request body which is
+	// buffered so it can be sent again. Requests with larger bodies, or
+	// bodies of unknown size, are not retried.
+	MaxBodyBytes int64
+}
+
+// WithDefaults returns a copy of the retry settings in which all settings
+// which have not been set have their default value.
+func (r *Retry) WithDefaults() *Retry {
+	c := *r
+
+	if c.Attempts <= 0 {
+		c.Attempts = DefaultRetryAttempts
+	}
+
+	if c.Budget <= 0 {
+		c.Budget = DefaultRetryBudget
+	}
+
+	if c.MaxBodyBytes <= 0 {
+		c.MaxBodyBytes = DefaultRetryMaxBodyBytes
+	}
+
+	return &c
+}
+
+// RetriesStatus returns whether requests are retried on the status code.
+func (r *Retry) RetriesStatus(status int) bool {
+	for _, s := range r.Statuses {
+		if s == status {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsIdempotent returns whether requests with the method can safely be
+// sent more than once.
+func IsIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace,
+		http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	return false
+}
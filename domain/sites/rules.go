@@ -0,0 +1,85 @@
+package sites
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// RuleAction defines what a rule does with a matching request.
+type RuleAction int
+
+const (
+	// RedirectAction responds with a redirect to the target location.
+	RedirectAction RuleAction = iota
+
+	// RewriteAction replaces the request path by the target path, after
+	// which the request is routed to a backend using the new path.
+	RewriteAction
+
+	// StaticAction responds with a static body.
+	StaticAction
+)
+
+// ParseRuleAction returns the rule action with the provided name:
+// redirect, rewrite or static.
+func ParseRuleAction(name string) (RuleAction, error) {
+	switch strings.ToLower(name) {
+	case "redirect":
+		return RedirectAction, nil
+	case "rewrite":
+		return RewriteAction, nil
+	case "static":
+		return StaticAction, nil
+	}
+
+	return RedirectAction, fmt.Errorf("unknown rule action: %s", name)
+}
+
+// Rule defines a redirect, rewrite or static response for requests
+// of a frontend. Rules are applied in order, before the request is
+// forwarded to the backend.
+type Rule struct {
+	// Action defines what this rule does with a matching request.
+	Action RuleAction
+	// PathRegex selects the requests to which this rule applies.
+	// The rule applies to all requests if it is empty.
+	PathRegex string
+	// Target holds the location of a redirect or the path of a rewrite.
+	// It can refer to capture groups of PathRegex using $1, ${name}, etc.
+	// The query string of the request is kept if the target has none.
+	Target string
+	// Status holds the status code of a redirect (default 301) or
+	// static response (default 200). Redirects use 301, 302, 303, 307
+	// or 308.
+	Status int
+	// Body holds the body of a static response.
+	Body string
+	// ContentType holds the content type of a static response.
+	ContentType string
+}
+
+// Terminates returns whether the rule ends the processing of a request
+// without forwarding it to a backend.
+func (r *Rule) Terminates() bool {
+	return r.Action == RedirectAction || r.Action == StaticAction
+}
+
+// Validate returns an error if the rule cannot be applied, such as a
+// redirect with a status code which is not a redirect status.
+func (r *Rule) Validate() error {
+	if r.Action == RedirectAction {
+		switch r.Status {
+		case 0,
+			http.StatusMovedPermanently,
+			http.StatusFound,
+			http.StatusSeeOther,
+			http.StatusTemporaryRedirect,
+			http.StatusPermanentRedirect:
+		default:
+			return fmt.Errorf("unsupported redirect status: %d", r.Status)
+		}
+	}
+
+	return nil
+}
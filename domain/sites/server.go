@@ -0,0 +1,53 @@
+package sites
+
+import (
+	"net/url"
+	"strings"
+)
+
+// DefaultWeight is the weight of servers for which no weight is set.
+const DefaultWeight = 1
+
+// Server defines a backend server.
+type Server struct {
+	// URL holds the URL on which the server can be reached.
+	URL *url.URL
+	// Weight defines the share of requests the server receives relative
+	// to the other servers of the backend, if supported by the load
+	// balancing algorithm.
+	Weight int
+}
+
+// NewServer creates a new server. It tries to parse the URL.
+func NewServer(rawURL string, weight int) (*Server, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Server{
+		URL:    u,
+		Weight: weight,
+	}, nil
+}
+
+// EffectiveWeight returns the weight, or DefaultWeight if it is not set.
+func (s *Server) EffectiveWeight() int {
+	if s.Weight <= 0 {
+		return DefaultWeight
+	}
+
+	return s.Weight
+}
+
+// String returns the URL of the server.
+func (s *Server) String() string {
+	return s.URL.String()
+}
+
+// IsSRV returns whether the host name of the server names an SRV record,
+// such as '_http._tcp.app.local', in which case the addresses and ports of
+// the server are looked up using the record.
+func (s *Server) IsSRV() bool {
+	return strings.HasPrefix(s.URL.Hostname(), "_")
+}
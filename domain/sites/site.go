@@ -1,15 +1,61 @@
 package sites
 
-import (
-	"net/url"
-)
-
-// Frontend maps a list of domain names to a Backend.
+// Frontend maps a domain name, optionally restricted to a path, to a Backend.
 type Frontend struct {
 	// Domain contains the domain name for this frontend.
 	Domain string
 	// BackendName specifies the name of the backend for this frontend.
 	BackendName string
+	// Splits optionally distributes the requests over several backends,
+	// in which case BackendName is ignored.
+	Splits []*BackendSplit
+	// SplitOverride optionally allows clients to select the backend of
+	// the splits.
+	SplitOverride *SplitOverride
+	// Mirror optionally sends a copy of the requests to another backend.
+	Mirror *Mirror
+	// CertGroup optionally names the certificate group of this frontend.
+	// Frontends in the same group share a single certificate.
+	CertGroup string
+	// PathPrefix optionally restricts this frontend to requests for which
+	// the path starts with this prefix. It matches whole path segments:
+	// '/api' matches '/api' and '/api/users', but not '/apifoo'.
+	PathPrefix string
+	// PathRegex optionally restricts this frontend to requests for which
+	// the path matches this regular expression.
+	PathRegex string
+	// StripPrefix removes the PathPrefix from the request path before
+	// the request is forwarded to the backend.
+	StripPrefix bool
+	// Priority defines the order in which frontends for the same domain
+	// are matched, highest first. Frontends with the same priority are
+	// matched from most to least specific path.
+	Priority int
+	// HSTS optionally defines the Strict-Transport-Security header
+	// added to all responses of this frontend.
+	HSTS *HSTS
+	// ClientAuth optionally requires clients to authenticate using a TLS
+	// client certificate.
+	ClientAuth *ClientAuth
+	// Upgrade optionally allows clients to upgrade their connection to
+	// another protocol, such as WebSockets. Upgrade requests are rejected
+	// if it is nil.
+	Upgrade *Upgrade
+	// Rules holds the redirect, rewrite and static response rules
+	// which are applied before forwarding a request to the backend.
+	// BackendName can be empty if all requests are handled by rules.
+	Rules []*Rule
+	// Passthrough forwards TLS connections to the backend without
+	// terminating them. The frontend is selected using the server name
+	// (SNI) in the ClientHello, so path rules do not apply.
+	Passthrough bool
+	// TCPPort optionally makes this a raw TCP frontend: all connections
+	// to the port are forwarded to the backend. The domain only serves
+	// as a name.
+	TCPPort int
+	// ProxyProtocol optionally sends a PROXY protocol header to the
+	// backend of a layer 4 frontend, passing the address of the client.
+	ProxyProtocol ProxyProtocol
 }
 
 // NewFrontend creates a new frontend.
@@ -24,25 +70,57 @@ func NewFrontend(backendName string, domain string) *Frontend {
 type Backend struct {
 	// Name holds the name of this site
 	Name string
-	// Servers contains a list of backend servers defined by the URL on
-	// which they can be reached.
-	Servers []*url.URL
+	// Servers contains a list of backend servers.
+	Servers []*Server
+	// Algorithm defines how requests are distributed over the servers.
+	Algorithm Algorithm
+	// HashKey defines the hash key of the ConsistentHash algorithm.
+	// The client IP is used if it is nil.
+	HashKey *HashKey
+	// Transport optionally defines the timeouts and connection reuse of
+	// the connections to the servers. Defaults are used if it is nil.
+	Transport *Transport
+	// TLS optionally defines how https servers are verified, and the
+	// client certificate sent to them.
+	TLS *BackendTLS
+	// HealthCheck optionally defines how the servers are checked.
+	HealthCheck *HealthCheck
+	// CircuitBreaker optionally ejects servers returning errors.
+	CircuitBreaker *CircuitBreaker
+	// Retry optionally retries failed requests against the next server.
+	Retry *Retry
+	// StickySession optionally sends all requests of a client to the
+	// same server.
+	StickySession *StickySession
+}
+
+// RouteKey returns the combination of domain and path rules which
+// must be unique across all frontends.
+func (f *Frontend) RouteKey() string {
+	return f.Domain + "|" + f.PathPrefix + "|" + f.PathRegex
+}
+
+// IsLayer4 returns whether connections for this frontend are forwarded
+// without inspecting requests: TLS passthrough and raw TCP frontends.
+func (f *Frontend) IsLayer4() bool {
+	return f.Passthrough || f.TCPPort > 0
 }
 
 // NewBackend creates a new backend. It tries to parse all provided servers to URLs.
+// All servers have the default weight.
 func NewBackend(name string, servers ...string) (*Backend, error) {
 	backend := &Backend{
 		Name:    name,
-		Servers: make([]*url.URL, len(servers)),
+		Servers: make([]*Server, len(servers)),
 	}
 
 	for i, server := range servers {
-		u, err := url.Parse(server)
+		s, err := NewServer(server, DefaultWeight)
 		if err != nil {
 			return nil, err
 		}
 
-		backend.Servers[i] = u
+		backend.Servers[i] = s
 	}
 
 	return backend, nil
@@ -0,0 +1,60 @@
+package sites
+
+import (
+	"fmt"
+	"strings"
+)
+
+// BackendSplit assigns a share of the requests of a frontend to a backend.
+type BackendSplit struct {
+	// BackendName specifies the name of the backend.
+	BackendName string
+	// Weight defines the share of requests relative to the other splits,
+	// e.g. 90 and 10 for a canary receiving 10% of the requests. Backends
+	// with weight 0 only receive requests selecting them using the
+	// split override.
+	Weight int
+}
+
+// SplitOverride defines the header or cookie with which a client can
+// select the backend of a split frontend by its name.
+type SplitOverride struct {
+	// Header holds the name of the header.
+	Header string
+	// Cookie holds the name of the cookie.
+	Cookie string
+}
+
+// ParseSplitOverride parses a split override: 'header:<name>' or
+// 'cookie:<name>'.
+func ParseSplitOverride(value string) (*SplitOverride, error) {
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) == 2 && parts[1] != "" {
+		switch strings.ToLower(parts[0]) {
+		case "header":
+			return &SplitOverride{Header: parts[1]}, nil
+		case "cookie":
+			return &SplitOverride{Cookie: parts[1]}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("invalid split override: %s", value)
+}
+
+// BackendNames returns the names of all backends of the frontend.
+func (f *Frontend) BackendNames() []string {
+	if len(f.Splits) < 1 {
+		if f.BackendName == "" {
+			return nil
+		}
+
+		return []string{f.BackendName}
+	}
+
+	names := make([]string, len(f.Splits))
+	for i, split := range f.Splits {
+		names[i] = split.BackendName
+	}
+
+	return names
+}
@@ -0,0 +1,33 @@
+package sites
+
+import (
+	"time"
+)
+
+// DefaultStickyCookieName is used when no cookie name is set.
+const DefaultStickyCookieName = "proxy_affinity"
+
+// StickySession defines the cookie used to send all requests of a client
+// to the same server of a backend. If the server is no longer available
+// the client is assigned another server.
+type StickySession struct {
+	// CookieName holds the name of the cookie.
+	CookieName string
+	// TTL defines how long the cookie is valid. The cookie expires at the
+	// end of the browser session if it is not set.
+	TTL time.Duration
+	// Secure restricts the cookie to HTTPS connections.
+	Secure bool
+	// HTTPOnly hides the cookie from scripts.
+	HTTPOnly bool
+}
+
+// Name returns the cookie name, or DefaultStickyCookieName if it has not
+// been set.
+func (s *StickySession) Name() string {
+	if s.CookieName == "" {
+		return DefaultStickyCookieName
+	}
+
+	return s.CookieName
+}
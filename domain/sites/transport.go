@@ -0,0 +1,72 @@
+package sites
+
+import (
+	"time"
+)
+
+// Defaults used for transport settings which have not been set.
+const (
+	DefaultDialTimeout         = 10 * time.Second
+	DefaultTLSHandshakeTimeout = 10 * time.Second
+	DefaultKeepAlive           = 30 * time.Second
+	DefaultIdleConnTimeout     = 90 * time.Second
+	DefaultMaxIdleConnsPerHost = 32
+)
+
+// Transport defines how connections to the servers of a backend are made
+// and reused.
+type Transport struct {
+	// DialTimeout limits the time to connect to a server.
+	DialTimeout time.Duration
+	// TLSHandshakeTimeout limits the time of the TLS handshake with https
+	// servers.
+	TLSHandshakeTimeout time.Duration
+	// ResponseHeaderTimeout optionally limits the time to wait for the
+	// response headers after the request has been sent. There is no limit
+	// if it is not set, as streaming and long polling responses can take
+	// a long time to start.
+	ResponseHeaderTimeout time.Duration
+	// KeepAlive holds the interval of TCP keep-alive probes.
+	KeepAlive time.Duration
+	// IdleConnTimeout defines how long idle connections are kept open for
+	// reuse.
+	IdleConnTimeout time.Duration
+	// MaxIdleConnsPerHost limits the number of idle connections kept open
+	// for reuse per server address.
+	MaxIdleConnsPerHost int
+	// DisableHTTP2 only uses HTTP/1.1 for https servers, instead of
+	// negotiating HTTP/2.
+	DisableHTTP2 bool
+}
+
+// WithDefaults returns a copy of the transport settings in which all
+// settings which have not been set have their default value. It can be
+// called on nil, in which case all settings have their default value.
+func (t *Transport) WithDefaults() *Transport {
+	c := Transport{}
+	if t != nil {
+		c = *t
+	}
+
+	if c.DialTimeout <= 0 {
+		c.DialTimeout = DefaultDialTimeout
+	}
+
+	if c.TLSHandshakeTimeout <= 0 {
+		c.TLSHandshakeTimeout = DefaultTLSHandshakeTimeout
+	}
+
+	if c.KeepAlive <= 0 {
+		c.KeepAlive = DefaultKeepAlive
+	}
+
+	if c.IdleConnTimeout <= 0 {
+		c.IdleConnTimeout = DefaultIdleConnTimeout
+	}
+
+	if c.MaxIdleConnsPerHost <= 0 {
+		c.MaxIdleConnsPerHost = DefaultMaxIdleConnsPerHost
+	}
+
+	return &c
+}
@@ -0,0 +1,26 @@
+package sites
+
+import (
+	"time"
+)
+
+// DefaultUpgradeIdleTimeout is used when no idle timeout is set.
+const DefaultUpgradeIdleTimeout = 5 * time.Minute
+
+// Upgrade defines how a frontend handles requests upgrading the connection
+// to another protocol, such as WebSockets.
+type Upgrade struct {
+	// IdleTimeout defines after how long without traffic in either
+	// direction an upgraded connection is closed.
+	IdleTimeout time.Duration
+}
+
+// Timeout returns the idle timeout, or DefaultUpgradeIdleTimeout if it
+// has not been set.
+func (u *Upgrade) Timeout() time.Duration {
+	if u.IdleTimeout <= 0 {
+		return DefaultUpgradeIdleTimeout
+	}
+
+	return u.IdleTimeout
+}
@@ -0,0 +1,130 @@
+package acmestore
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/off-sync/platform-proxy/common/sqlutil"
+	"github.com/off-sync/platform-proxy/domain/acme"
+	lego "github.com/xenolf/lego/acme"
+)
+
+// SQLACMEStore implements the ACMESaver and ACMELoader interfaces
+// using a database/sql database as its backend.
+// SQLite and PostgreSQL are supported.
+type SQLACMEStore struct {
+	db        *sql.DB
+	dialect   sqlutil.Dialect
+	tableName string
+}
+
+var sqlACMEMigrations = []sqlutil.Migration{
+	{
+		Version: 1,
+		Statements: []string{
+			`CREATE TABLE {table} (
+	account_key VARCHAR(512) NOT NULL PRIMARY KEY,
+	endpoint VARCHAR(255) NOT NULL,
+	email VARCHAR(255) NOT NULL,
+	private_key TEXT NOT NULL,
+	registration TEXT NOT NULL
+)`,
+		},
+	},
+}
+
+// NewSQLACMEStore creates a new SQL ACME store using the provided
+// database and dialect. It creates or migrates the table before returning.
+func NewSQLACMEStore(db *sql.DB, dialect sqlutil.Dialect, tableName string) (*SQLACMEStore, error) {
+	if !sqlutil.ValidTableName(tableName) {
+		return nil, fmt.Errorf("invalid table name: %s", tableName)
+	}
+
+	if err := sqlutil.Migrate(db, dialect, tableName, sqlACMEMigrations); err != nil {
+		return nil, err
+	}
+
+	return &SQLACMEStore{
+		db:        db,
+		dialect:   dialect,
+		tableName: tableName,
+	}, nil
+}
+
+func (s *SQLACMEStore) query(query string) string {
+	return sqlutil.Query(s.dialect, s.tableName, query)
+}
+
+// Load tries to load an ACME account from the SQL store.
+// It returns nil if the account does not exist.
+func (s *SQLACMEStore) Load(endpoint, email string) (*acme.Account, error) {
+	var privateKey, registration string
+
+	err := s.db.QueryRow(
+		s.query("SELECT private_key, registration FROM {table} WHERE account_key = ?"),
+		accountKey(endpoint, email)).Scan(&privateKey, &registration)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	account := &acme.Account{
+		Endpoint:     endpoint,
+		Email:        email,
+		PrivateKey:   privateKey,
+		Registration: &lego.RegistrationResource{},
+	}
+
+	err = json.Unmarshal([]byte(registration), account.Registration)
+	if err != nil {
+		return nil, err
+	}
+
+	return account, nil
+}
+
+// Save stores an ACME account in the SQL store, replacing an existing
+// account for the same endpoint and email address.
+func (s *SQLACMEStore) Save(account *acme.Account) error {
+	registration, err := json.Marshal(account.Registration)
+	if err != nil {
+		return err
+	}
+
+	key := accountKey(account.Endpoint, account.Email)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	res, err := tx.Exec(
+		s.query("UPDATE {table} SET private_key = ?, registration = ? WHERE account_key = ?"),
+		account.PrivateKey, string(registration), key)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if n < 1 {
+		_, err = tx.Exec(
+			s.query("INSERT INTO {table} (account_key, endpoint, email, private_key, registration) VALUES (?, ?, ?, ?, ?)"),
+			key, account.Endpoint, account.Email, account.PrivateKey, string(registration))
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,90 @@
+package acmestore
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/off-sync/platform-proxy/common/sqlutil"
+	"github.com/off-sync/platform-proxy/domain/acme"
+	lego "github.com/xenolf/lego/acme"
+
+	_ "github.com/mattn/go-sqlite3" // SQLite driver
+)
+
+func newSQLACMEStore(t *testing.T) *SQLACMEStore {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every connection to :memory: opens a new, empty database
+	db.SetMaxOpenConns(1)
+
+	t.Cleanup(func() { db.Close() })
+
+	s, err := NewSQLACMEStore(db, sqlutil.SQLite, "acme_accounts")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+func TestSQLACMEStoreSaveLoad(t *testing.T) {
+	s := newSQLACMEStore(t)
+
+	const endpoint, email = "https://acme.example.com/directory", "admin@example.com"
+
+	account, err := s.Load(endpoint, email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if account != nil {
+		t.Fatal("expected no account before saving")
+	}
+
+	saved := &acme.Account{
+		Endpoint:     endpoint,
+		Email:        email,
+		PrivateKey:   "first-key",
+		Registration: &lego.RegistrationResource{URI: "https://acme.example.com/acct/1"},
+	}
+
+	if err := s.Save(saved); err != nil {
+		t.Fatal(err)
+	}
+
+	account, err = s.Load(endpoint, email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if account == nil {
+		t.Fatal("expected saved account to be loaded")
+	}
+
+	if account.Endpoint != endpoint || account.Email != email ||
+		account.PrivateKey != saved.PrivateKey || account.Registration.URI != saved.Registration.URI {
+		t.Fatalf("loaded account %+v does not match saved account %+v", account, saved)
+	}
+
+	// saving again replaces the existing account
+	saved.PrivateKey = "second-key"
+	if err := s.Save(saved); err != nil {
+		t.Fatal(err)
+	}
+
+	account, err = s.Load(endpoint, email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if account.PrivateKey != "second-key" {
+		t.Fatalf("expected replaced private key, got %s", account.PrivateKey)
+	}
+
+	if other, err := s.Load(endpoint, "other@example.com"); err != nil || other != nil {
+		t.Fatalf("expected no account for another email address, got %v, %v", other, err)
+	}
+}
@@ -0,0 +1,187 @@
+// Package acmev2 implements the parts of the RFC 8555 ACME protocol which
+// the lego ACME v1 client does not provide, like revocation with a reason
+// code or with the key of the certificate. It only depends on the standard
+// library, so it can be tested against Pebble on its own.
+package acmev2
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+const (
+	problemBadNonce  = "urn:ietf:params:acme:error:badNonce"
+	maxNonceAttempts = 3
+)
+
+// Directory holds the resource URLs of an RFC 8555 ACME server.
+type Directory struct {
+	NewNonce   string `json:"newNonce"`
+	NewAccount string `json:"newAccount"`
+	NewOrder   string `json:"newOrder"`
+	RevokeCert string `json:"revokeCert"`
+}
+
+// Problem holds an error returned by the ACME server (RFC 7807).
+type Problem struct {
+	Type   string `json:"type"`
+	Detail string `json:"detail"`
+	Status int    `json:"status"`
+}
+
+func (p *Problem) Error() string {
+	return fmt.Sprintf("%s: %s", p.Type, p.Detail)
+}
+
+// Client sends signed requests to an RFC 8555 ACME server.
+type Client struct {
+	sync.Mutex
+	http   *http.Client
+	dir    *Directory
+	nonces []string
+}
+
+// NewClient creates a client for the ACME server with the provided
+// directory URL.
+func NewClient(httpClient *http.Client, directory string) (*Client, error) {
+	resp, err := httpClient.Get(directory)
+	if err != nil {
+		return nil, fmt.Errorf("getting ACME directory: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("getting ACME directory: %s", resp.Status)
+	}
+
+	dir := &Directory{}
+	if err := json.NewDecoder(resp.Body).Decode(dir); err != nil {
+		return nil, fmt.Errorf("decoding ACME directory: %s", err)
+	}
+
+	if dir.NewNonce == "" {
+		return nil, fmt.Errorf("not an RFC 8555 ACME directory: %s", directory)
+	}
+
+	return &Client{
+		http: httpClient,
+		dir:  dir,
+	}, nil
+}
+
+// Directory returns the resource URLs of the ACME server.
+func (c *Client) Directory() *Directory {
+	return c.dir
+}
+
+// nonce returns an anti-replay nonce received with an earlier response,
+// or requests a new one.
+func (c *Client) nonce() (string, error) {
+	c.Lock()
+	if n := len(c.nonces); n > 0 {
+		nonce := c.nonces[n-1]
+		c.nonces = c.nonces[:n-1]
+		c.Unlock()
+
+		return nonce, nil
+	}
+	c.Unlock()
+
+	resp, err := c.http.Head(c.dir.NewNonce)
+	if err != nil {
+		return "", fmt.Errorf("getting nonce: %s", err)
+	}
+	resp.Body.Close()
+
+	nonce := resp.Header.Get("Replay-Nonce")
+	if nonce == "" {
+		return "", fmt.Errorf("getting nonce: Replay-Nonce header missing")
+	}
+
+	return nonce, nil
+}
+
+func (c *Client) saveNonce(resp *http.Response) {
+	if nonce := resp.Header.Get("Replay-Nonce"); nonce != "" {
+		c.Lock()
+		c.nonces = append(c.nonces, nonce)
+		c.Unlock()
+	}
+}
+
+// Post sends the payload to the URL, signed with the key. The key is
+// identified by the account URL kid, or embedded if kid is empty. A nil
+// payload sends a POST-as-GET request. Requests rejected because of an
+// invalid nonce are retried. Error responses are returned as *Problem.
+func (c *Client) Post(url string, key crypto.Signer, kid string, payload interface{}) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		nonce, err := c.nonce()
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := signJWS(key, kid, nonce, url, payload)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := c.http.Post(url, "application/jose+json", bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+
+		c.saveNonce(resp)
+
+		if resp.StatusCode < http.StatusBadRequest {
+			return resp, nil
+		}
+
+		problem := readProblem(resp)
+		if problem.Type == problemBadNonce && attempt < maxNonceAttempts {
+			continue
+		}
+
+		return nil, problem
+	}
+}
+
+func readProblem(resp *http.Response) *Problem {
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	problem := &Problem{}
+	if err := json.Unmarshal(body, problem); err != nil || problem.Type == "" {
+		problem.Detail = string(body)
+	}
+
+	if problem.Type == "" {
+		problem.Type = resp.Status
+	}
+
+	return problem
+}
+
+// AccountURL looks up the URL of the account registered with the key,
+// which identifies the key in requests signed by the account.
+func (c *Client) AccountURL(key crypto.Signer) (string, error) {
+	resp, err := c.Post(c.dir.NewAccount, key, "", map[string]bool{
+		"onlyReturnExisting": true,
+	})
+	if err != nil {
+		return "", fmt.Errorf("looking up account: %s", err)
+	}
+	resp.Body.Close()
+
+	location := resp.Header.Get("Location")
+	if location == "" {
+		return "", fmt.Errorf("looking up account: Location header missing")
+	}
+
+	return location, nil
+}
@@ -0,0 +1,415 @@
+package acmev2
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeACMEServer implements the directory, nonces, account lookup and
+// revocation of an RFC 8555 server, verifying the signature, nonce and
+// URL of every request.
+type fakeACMEServer struct {
+	*httptest.Server
+
+	sync.Mutex
+	accounts  map[string]crypto.PublicKey
+	nonces    map[string]bool
+	nextNonce int
+	badNonces int
+	revoked   map[string]int
+	revokedBy map[string]string
+}
+
+func newFakeACMEServer(t *testing.T) *fakeACMEServer {
+	s := &fakeACMEServer{
+		accounts:  make(map[string]crypto.PublicKey),
+		nonces:    make(map[string]bool),
+		revoked:   make(map[string]int),
+		revokedBy: make(map[string]string),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/dir", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(&Directory{
+			NewNonce:   s.URL + "/nonce",
+			NewAccount: s.URL + "/account",
+			RevokeCert: s.URL + "/revoke",
+		})
+	})
+	mux.HandleFunc("/nonce", func(w http.ResponseWriter, r *http.Request) {
+		s.addNonce(w)
+	})
+	mux.HandleFunc("/account", s.handleAccount)
+	mux.HandleFunc("/revoke", s.handleRevoke)
+
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// register registers an account for the key and returns its URL.
+func (s *fakeACMEServer) register(key crypto.Signer) string {
+	s.Lock()
+	defer s.Unlock()
+
+	url := fmt.Sprintf("%s/account/%d", s.URL, len(s.accounts)+1)
+	s.accounts[url] = key.Public()
+
+	return url
+}
+
+func (s *fakeACMEServer) addNonce(w http.ResponseWriter) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.nextNonce++
+	nonce := fmt.Sprintf("nonce-%d", s.nextNonce)
+	s.nonces[nonce] = true
+
+	w.Header().Set("Replay-Nonce", nonce)
+}
+
+func (s *fakeACMEServer) problem(w http.ResponseWriter, status int, problemType, detail string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+
+	json.NewEncoder(w).Encode(&Problem{
+		Type:   "urn:ietf:params:acme:error:" + problemType,
+		Detail: detail,
+		Status: status,
+	})
+}
+
+// verify checks the JWS of the request and returns its header and payload.
+// It returns false after writing a problem if the request is invalid.
+func (s *fakeACMEServer) verify(w http.ResponseWriter, r *http.Request) (*jwsProtectedHeader, []byte, bool) {
+	// every response carries a new nonce
+	s.addNonce(w)
+
+	msg := &jwsMessage{}
+	if err := json.NewDecoder(r.Body).Decode(msg); err != nil {
+		s.problem(w, http.StatusBadRequest, "malformed", err.Error())
+		return nil, nil, false
+	}
+
+	protected, err := base64.RawURLEncoding.DecodeString(msg.Protected)
+	if err != nil {
+		s.problem(w, http.StatusBadRequest, "malformed", err.Error())
+		return nil, nil, false
+	}
+
+	header := &jwsProtectedHeader{}
+	if err := json.Unmarshal(protected, header); err != nil {
+		s.problem(w, http.StatusBadRequest, "malformed", err.Error())
+		return nil, nil, false
+	}
+
+	s.Lock()
+	validNonce := s.nonces[header.Nonce]
+	delete(s.nonces, header.Nonce)
+	rejectNonce := s.badNonces > 0
+	if rejectNonce {
+		s.badNonces--
+	}
+	s.Unlock()
+
+	if !validNonce || rejectNonce {
+		s.problem(w, http.StatusBadRequest, "badNonce", "invalid nonce: "+header.Nonce)
+		return nil, nil, false
+	}
+
+	if header.URL != s.URL+r.URL.Path {
+		s.problem(w, http.StatusUnauthorized, "unauthorized", "URL mismatch: "+header.URL)
+		return nil, nil, false
+	}
+
+	var pub crypto.PublicKey
+	switch {
+	case header.KeyID != "" && header.JWK != nil:
+		s.problem(w, http.StatusBadRequest, "malformed", "both kid and jwk set")
+		return nil, nil, false
+	case header.KeyID != "":
+		s.Lock()
+		pub = s.accounts[header.KeyID]
+		s.Unlock()
+
+		if pub == nil {
+			s.problem(w, http.StatusBadRequest, "accountDoesNotExist", header.KeyID)
+			return nil, nil, false
+		}
+	case header.JWK != nil:
+		pub, err = header.JWK.publicKey()
+		if err != nil {
+			s.problem(w, http.StatusBadRequest, "badPublicKey", err.Error())
+			return nil, nil, false
+		}
+	}
+
+	signature, _ := base64.RawURLEncoding.DecodeString(msg.Signature)
+	if err := verifySignature(pub, header.Algorithm, []byte(msg.Protected+"."+msg.Payload), signature); err != nil {
+		s.problem(w, http.StatusBadRequest, "malformed", err.Error())
+		return nil, nil, false
+	}
+
+	payload, _ := base64.RawURLEncoding.DecodeString(msg.Payload)
+
+	return header, payload, true
+}
+
+func (s *fakeACMEServer) handleAccount(w http.ResponseWriter, r *http.Request) {
+	header, _, ok := s.verify(w, r)
+	if !ok {
+		return
+	}
+
+	pub, err := header.JWK.publicKey()
+	if err != nil {
+		s.problem(w, http.StatusBadRequest, "malformed", "jwk required")
+		return
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	for url, registered := range s.accounts {
+		if keysEqual(registered, pub) {
+			w.Header().Set("Location", url)
+			w.Write([]byte("{}"))
+			return
+		}
+	}
+
+	s.problem(w, http.StatusBadRequest, "accountDoesNotExist", "no account for key")
+}
+
+func (s *fakeACMEServer) handleRevoke(w http.ResponseWriter, r *http.Request) {
+	header, payload, ok := s.verify(w, r)
+	if !ok {
+		return
+	}
+
+	req := &revokeCertRequest{}
+	if err := json.Unmarshal(payload, req); err != nil {
+		s.problem(w, http.StatusBadRequest, "malformed", err.Error())
+		return
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if _, revoked := s.revoked[req.Certificate]; revoked {
+		s.problem(w, http.StatusBadRequest, "alreadyRevoked", "certificate already revoked")
+		return
+	}
+
+	s.revoked[req.Certificate] = req.Reason
+	s.revokedBy[req.Certificate] = header.KeyID
+}
+
+// publicKey returns the RSA or ECDSA public key of the JWK.
+func (k *jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	if k == nil {
+		return nil, fmt.Errorf("missing key")
+	}
+
+	decode := func(s string) *big.Int {
+		b, _ := base64.RawURLEncoding.DecodeString(s)
+		return new(big.Int).SetBytes(b)
+	}
+
+	switch k.KeyType {
+	case "RSA":
+		return &rsa.PublicKey{N: decode(k.Modulus), E: int(decode(k.Exponent).Int64())}, nil
+	case "EC":
+		for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P384()} {
+			if curve.Params().Name == k.Curve {
+				return &ecdsa.PublicKey{Curve: curve, X: decode(k.X), Y: decode(k.Y)}, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("unsupported key: %s %s", k.KeyType, k.Curve)
+}
+
+func keysEqual(a, b crypto.PublicKey) bool {
+	switch a := a.(type) {
+	case *rsa.PublicKey:
+		return a.Equal(b)
+	case *ecdsa.PublicKey:
+		return a.Equal(b)
+	}
+
+	return false
+}
+
+func verifySignature(pub crypto.PublicKey, alg string, signed, signature []byte) error {
+	hashes := map[string]crypto.Hash{
+		"RS256": crypto.SHA256,
+		"ES256": crypto.SHA256,
+		"ES384": crypto.SHA384,
+	}
+
+	hash, ok := hashes[alg]
+	if !ok {
+		return fmt.Errorf("unsupported algorithm: %s", alg)
+	}
+
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") {
+			return fmt.Errorf("algorithm %s does not match RSA key", alg)
+		}
+
+		return rsa.VerifyPKCS1v15(pub, hash, digest, signature)
+	case *ecdsa.PublicKey:
+		size := curveSize(pub.Curve)
+		if !strings.HasPrefix(alg, "ES") || len(signature) != 2*size {
+			return fmt.Errorf("invalid ECDSA signature")
+		}
+
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return fmt.Errorf("invalid ECDSA signature")
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("unsupported key type: %T", pub)
+}
+
+func TestNewClientRejectsNonRFC8555Directory(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// an ACME v1 directory
+		w.Write([]byte(`{"new-reg": "https://example.com/acme/new-reg"}`))
+	}))
+	defer srv.Close()
+
+	if _, err := NewClient(srv.Client(), srv.URL); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestRevokeCert(t *testing.T) {
+	tests := []struct {
+		key        string
+		useCertKey bool
+	}{
+		{"rsa", false},
+		{"p256", false},
+		{"p384", false},
+		{"rsa", true},
+		{"p256", true},
+		{"p384", true},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s-cert-key-%v", test.key, test.useCertKey), func(t *testing.T) {
+			srv := newFakeACMEServer(t)
+			key := newTestKey(t, test.key)
+
+			var kid string
+			if !test.useCertKey {
+				kid = srv.register(key)
+			}
+
+			client, err := NewClient(srv.Client(), srv.URL+"/dir")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !test.useCertKey {
+				accountURL, err := client.AccountURL(key)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if accountURL != kid {
+					t.Fatalf("expected account URL %s, got %s", kid, accountURL)
+				}
+			}
+
+			der := []byte("certificate")
+			if err := client.RevokeCert(der, keyCompromise, key, kid); err != nil {
+				t.Fatal(err)
+			}
+
+			if reason, ok := srv.revoked[b64(der)]; !ok || reason != keyCompromise {
+				t.Errorf("expected certificate to be revoked with reason %d, got %d (%v)", keyCompromise, reason, ok)
+			}
+
+			if by := srv.revokedBy[b64(der)]; by != kid {
+				t.Errorf("expected certificate to be revoked by %q, got %q", kid, by)
+			}
+
+			err = client.RevokeCert(der, keyCompromise, key, kid)
+			if p, ok := err.(*Problem); !ok || !strings.HasSuffix(p.Type, ":alreadyRevoked") {
+				t.Errorf("expected alreadyRevoked problem, got %v", err)
+			}
+		})
+	}
+}
+
+func TestAccountURLOfUnknownKey(t *testing.T) {
+	srv := newFakeACMEServer(t)
+
+	client, err := NewClient(srv.Client(), srv.URL+"/dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.AccountURL(newTestKey(t, "rsa")); err == nil || !strings.Contains(err.Error(), "accountDoesNotExist") {
+		t.Errorf("expected accountDoesNotExist error, got %v", err)
+	}
+}
+
+func TestPostRetriesBadNonces(t *testing.T) {
+	tests := []struct {
+		badNonces int
+		expectErr bool
+	}{
+		{0, false},
+		{maxNonceAttempts - 1, false},
+		{maxNonceAttempts, true},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("bad-nonces-%d", test.badNonces), func(t *testing.T) {
+			srv := newFakeACMEServer(t)
+			key := newTestKey(t, "p256")
+
+			client, err := NewClient(srv.Client(), srv.URL+"/dir")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			srv.badNonces = test.badNonces
+
+			err = client.RevokeCert([]byte("certificate"), keyCompromise, key, "")
+			if test.expectErr {
+				if p, ok := err.(*Problem); !ok || p.Type != problemBadNonce {
+					t.Errorf("expected badNonce problem, got %v", err)
+				}
+			} else if err != nil {
+				t.Errorf("expected no error, got %s", err)
+			}
+		})
+	}
+}
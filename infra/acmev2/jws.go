@@ -0,0 +1,180 @@
+package acmev2
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+)
+
+// jsonWebKey holds the public part of a RSA or ECDSA key as defined in
+// RFC 7517. Its fields are listed in lexicographic order.
+type jsonWebKey struct {
+	Curve    string `json:"crv,omitempty"`
+	Exponent string `json:"e,omitempty"`
+	KeyType  string `json:"kty"`
+	Modulus  string `json:"n,omitempty"`
+	X        string `json:"x,omitempty"`
+	Y        string `json:"y,omitempty"`
+}
+
+// jwsMessage holds a JWS in the flattened JSON serialization used by ACME.
+type jwsMessage struct {
+	Protected string `json:"protected"`
+	Payload   string `json:"payload"`
+	Signature string `json:"signature"`
+}
+
+// jwsProtectedHeader holds the protected header of an ACME request. The key
+// is either identified by its account URL (kid) or embedded (jwk).
+type jwsProtectedHeader struct {
+	Algorithm string      `json:"alg"`
+	KeyID     string      `json:"kid,omitempty"`
+	JWK       *jsonWebKey `json:"jwk,omitempty"`
+	Nonce     string      `json:"nonce"`
+	URL       string      `json:"url"`
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// newJSONWebKey returns the JWK of the public part of a RSA or ECDSA key.
+func newJSONWebKey(key crypto.Signer) (*jsonWebKey, error) {
+	switch pub := key.Public().(type) {
+	case *rsa.PublicKey:
+		return &jsonWebKey{
+			KeyType:  "RSA",
+			Modulus:  b64(pub.N.Bytes()),
+			Exponent: b64(big.NewInt(int64(pub.E)).Bytes()),
+		}, nil
+	case *ecdsa.PublicKey:
+		size := curveSize(pub.Curve)
+
+		return &jsonWebKey{
+			KeyType: "EC",
+			Curve:   pub.Curve.Params().Name,
+			X:       b64(padBytes(pub.X.Bytes(), size)),
+			Y:       b64(padBytes(pub.Y.Bytes(), size)),
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type: %T", key)
+}
+
+// jwsAlgorithm returns the JWS algorithm used to sign with the key:
+// RS256 for RSA keys, and ES256, ES384 or ES512 for ECDSA keys
+// depending on their curve.
+func jwsAlgorithm(key crypto.Signer) (string, crypto.Hash, error) {
+	switch pub := key.Public().(type) {
+	case *rsa.PublicKey:
+		return "RS256", crypto.SHA256, nil
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P256():
+			return "ES256", crypto.SHA256, nil
+		case elliptic.P384():
+			return "ES384", crypto.SHA384, nil
+		case elliptic.P521():
+			return "ES512", crypto.SHA512, nil
+		}
+
+		return "", 0, fmt.Errorf("unsupported curve: %s", pub.Curve.Params().Name)
+	}
+
+	return "", 0, fmt.Errorf("unsupported key type: %T", key)
+}
+
+// signJWS signs the payload for the URL. The key is identified by kid,
+// or embedded in the header if kid is empty. A nil payload results in
+// an empty payload, as used by POST-as-GET requests.
+func signJWS(key crypto.Signer, kid, nonce, url string, payload interface{}) ([]byte, error) {
+	alg, hash, err := jwsAlgorithm(key)
+	if err != nil {
+		return nil, err
+	}
+
+	header := &jwsProtectedHeader{
+		Algorithm: alg,
+		KeyID:     kid,
+		Nonce:     nonce,
+		URL:       url,
+	}
+
+	if kid == "" {
+		header.JWK, err = newJSONWebKey(key)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	protected, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+
+	var payloadBytes []byte
+	if payload != nil {
+		payloadBytes, err = json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	msg := &jwsMessage{
+		Protected: b64(protected),
+		Payload:   b64(payloadBytes),
+	}
+
+	h := hash.New()
+	h.Write([]byte(msg.Protected + "." + msg.Payload))
+
+	signature, err := sign(key, hash, h.Sum(nil))
+	if err != nil {
+		return nil, fmt.Errorf("signing request: %s", err)
+	}
+
+	msg.Signature = b64(signature)
+
+	return json.Marshal(msg)
+}
+
+// sign signs the digest. ECDSA signatures are encoded as the concatenation
+// of R and S, as required by RFC 7518, instead of ASN.1.
+func sign(key crypto.Signer, hash crypto.Hash, digest []byte) ([]byte, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return rsa.SignPKCS1v15(rand.Reader, k, hash, digest)
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest)
+		if err != nil {
+			return nil, err
+		}
+
+		size := curveSize(k.Curve)
+
+		return append(padBytes(r.Bytes(), size), padBytes(s.Bytes(), size)...), nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type: %T", key)
+}
+
+func curveSize(curve elliptic.Curve) int {
+	return (curve.Params().BitSize + 7) / 8
+}
+
+func padBytes(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b
+	}
+
+	padded := make([]byte, size)
+	copy(padded[size-len(b):], b)
+
+	return padded
+}
@@ -0,0 +1,255 @@
+package acmev2
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// keyCompromise holds the revocation reason code of a compromised key.
+const keyCompromise = 1
+
+// The Pebble tests run against a Pebble ACME test server. Start it with
+// PEBBLE_VA_ALWAYS_VALID=1, so challenges need not be solved, and set
+// PEBBLE_DIRECTORY to its directory URL, e.g. https://localhost:14000/dir.
+// PEBBLE_CA_FILE optionally holds the CA certificate of its HTTPS listener
+// (test/certs/pebble.minica.pem); it is not verified if not set.
+func newPebbleHTTPClient(t *testing.T) (*http.Client, string) {
+	directory := os.Getenv("PEBBLE_DIRECTORY")
+	if directory == "" {
+		t.Skip("PEBBLE_DIRECTORY not set")
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: true}
+
+	if caFile := os.Getenv("PEBBLE_CA_FILE"); caFile != "" {
+		caPEM, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			t.Fatalf("no certificates found in %s", caFile)
+		}
+
+		tlsConfig = &tls.Config{RootCAs: pool}
+	}
+
+	return &http.Client{
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		Timeout:   30 * time.Second,
+	}, directory
+}
+
+type pebbleOrder struct {
+	Status         string   `json:"status"`
+	Authorizations []string `json:"authorizations"`
+	Finalize       string   `json:"finalize"`
+	Certificate    string   `json:"certificate"`
+}
+
+type pebbleAuthorization struct {
+	Status     string `json:"status"`
+	Challenges []struct {
+		Type string `json:"type"`
+		URL  string `json:"url"`
+	} `json:"challenges"`
+}
+
+// pebbleAccount registers an account for the key and returns its URL.
+func pebbleAccount(t *testing.T, client *Client, key crypto.Signer) string {
+	resp, err := client.Post(client.Directory().NewAccount, key, "", map[string]bool{
+		"termsOfServiceAgreed": true,
+	})
+	if err != nil {
+		t.Fatalf("registering account: %s", err)
+	}
+	resp.Body.Close()
+
+	return resp.Header.Get("Location")
+}
+
+// pebblePost sends a request signed by the account and decodes the response.
+func pebblePost(t *testing.T, client *Client, key crypto.Signer, kid, url string, payload, result interface{}) *http.Response {
+	resp, err := client.Post(url, key, kid, payload)
+	if err != nil {
+		t.Fatalf("posting to %s: %s", url, err)
+	}
+	defer resp.Body.Close()
+
+	if result != nil {
+		if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+			t.Fatalf("decoding response of %s: %s", url, err)
+		}
+	}
+
+	return resp
+}
+
+// pebbleIssue orders a certificate for the domains and the certificate key.
+func pebbleIssue(t *testing.T, client *Client, accountKey crypto.Signer, kid string, domains []string, certKey crypto.Signer) []byte {
+	var identifiers []map[string]string
+	for _, domain := range domains {
+		identifiers = append(identifiers, map[string]string{"type": "dns", "value": domain})
+	}
+
+	order := &pebbleOrder{}
+	resp := pebblePost(t, client, accountKey, kid, client.Directory().NewOrder, map[string]interface{}{
+		"identifiers": identifiers,
+	}, order)
+	orderURL := resp.Header.Get("Location")
+
+	for _, authzURL := range order.Authorizations {
+		authz := &pebbleAuthorization{}
+		pebblePost(t, client, accountKey, kid, authzURL, nil, authz)
+
+		// Pebble validates any challenge with PEBBLE_VA_ALWAYS_VALID set
+		pebblePost(t, client, accountKey, kid, authz.Challenges[0].URL, struct{}{}, nil)
+
+		for authz.Status != "valid" {
+			if authz.Status == "invalid" {
+				t.Fatal("authorization failed: is PEBBLE_VA_ALWAYS_VALID set?")
+			}
+
+			time.Sleep(100 * time.Millisecond)
+			pebblePost(t, client, accountKey, kid, authzURL, nil, authz)
+		}
+	}
+
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: domains[0]},
+		DNSNames: domains,
+	}, certKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pebblePost(t, client, accountKey, kid, order.Finalize, map[string]string{"csr": b64(csr)}, order)
+
+	for order.Status != "valid" {
+		if order.Status == "invalid" {
+			t.Fatal("order failed")
+		}
+
+		time.Sleep(100 * time.Millisecond)
+		pebblePost(t, client, accountKey, kid, orderURL, nil, order)
+	}
+
+	resp, err = client.Post(order.Certificate, accountKey, kid, nil)
+	if err != nil {
+		t.Fatalf("downloading certificate: %s", err)
+	}
+	defer resp.Body.Close()
+
+	chain, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return chain
+}
+
+// newTestKey generates a RSA, ECDSA P-256 or ECDSA P-384 key.
+func newTestKey(t *testing.T, keyType string) crypto.Signer {
+	var key crypto.Signer
+	var err error
+
+	switch keyType {
+	case "rsa":
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "p256":
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "p384":
+		key, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	default:
+		t.Fatalf("unknown key type: %s", keyType)
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func TestRevokeCertWithPebble(t *testing.T) {
+	httpClient, directory := newPebbleHTTPClient(t)
+
+	client, err := NewClient(httpClient, directory)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		accountKey string
+		certKey    string
+		useCertKey bool
+	}{
+		{"rsa", "rsa", false},
+		{"rsa", "p256", false},
+		{"p256", "rsa", false},
+		{"p384", "p384", false},
+		{"rsa", "rsa", true},
+		{"rsa", "p256", true},
+		{"rsa", "p384", true},
+	}
+
+	for _, test := range tests {
+		name := fmt.Sprintf("account-%s-cert-%s-cert-key-%v", test.accountKey, test.certKey, test.useCertKey)
+
+		t.Run(name, func(t *testing.T) {
+			// certificate keys must differ from account keys
+			accountKey := newTestKey(t, test.accountKey)
+			certKey := newTestKey(t, test.certKey)
+
+			kid := pebbleAccount(t, client, accountKey)
+
+			chain := pebbleIssue(t, client, accountKey, kid, []string{name + ".revoke.example.com"}, certKey)
+
+			block, _ := pem.Decode(chain)
+			if block == nil {
+				t.Fatal("no certificate issued")
+			}
+
+			// the certificate key is embedded in the request
+			key, accountURL := crypto.Signer(certKey), ""
+
+			if !test.useCertKey {
+				key = accountKey
+
+				accountURL, err = client.AccountURL(accountKey)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if accountURL != kid {
+					t.Fatalf("expected account URL %s, got %s", kid, accountURL)
+				}
+			}
+
+			if err := client.RevokeCert(block.Bytes, keyCompromise, key, accountURL); err != nil {
+				t.Fatalf("revoking certificate: %s", err)
+			}
+
+			err = client.RevokeCert(block.Bytes, keyCompromise, key, accountURL)
+			if err == nil || !strings.Contains(err.Error(), "alreadyRevoked") {
+				t.Fatalf("expected certificate to be revoked already, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package acmev2
+
+import (
+	"crypto"
+	"fmt"
+)
+
+type revokeCertRequest struct {
+	Certificate string `json:"certificate"`
+	Reason      int    `json:"reason"`
+}
+
+// RevokeCert revokes the DER encoded certificate, passing the provided
+// reason code. The request is signed with the key of the account with URL
+// kid, or with the private key of the certificate if kid is empty.
+func (c *Client) RevokeCert(der []byte, reason int, key crypto.Signer, kid string) error {
+	if c.dir.RevokeCert == "" {
+		return fmt.Errorf("ACME directory does not support revocation")
+	}
+
+	resp, err := c.Post(c.dir.RevokeCert, key, kid, &revokeCertRequest{
+		Certificate: b64(der),
+		Reason:      reason,
+	})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+}
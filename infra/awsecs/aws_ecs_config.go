@@ -2,27 +2,109 @@ package awsecs
 
 import (
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ecs"
 )
 
 const (
-	serverContainerName = "server"
-	dockerLabelPort     = "com.off-sync.platform.proxy.port"
-	defaultPort         = 8080
+	serverContainerName          = "server"
+	dockerLabelPort              = "com.off-sync.platform.proxy.port"
+	dockerLabelScheme            = "com.off-sync.platform.proxy.scheme"
+	dockerLabelCertGroup         = "com.off-sync.platform.proxy.cert-group"
+	dockerLabelDomain            = "com.off-sync.platform.proxy.domain"
+	dockerLabelPathPrefix        = "com.off-sync.platform.proxy.path-prefix"
+	dockerLabelPathRegex         = "com.off-sync.platform.proxy.path-regex"
+	dockerLabelStripPrefix       = "com.off-sync.platform.proxy.strip-prefix"
+	dockerLabelPriority          = "com.off-sync.platform.proxy.priority"
+	dockerLabelHSTSMaxAge        = "com.off-sync.platform.proxy.hsts-max-age"
+	dockerLabelHSTSSubs          = "com.off-sync.platform.proxy.hsts-include-subdomains"
+	dockerLabelHSTSPreload       = "com.off-sync.platform.proxy.hsts-preload"
+	dockerLabelHCPath            = "com.off-sync.platform.proxy.health-check-path"
+	dockerLabelHCStatus          = "com.off-sync.platform.proxy.health-check-status"
+	dockerLabelHCInterval        = "com.off-sync.platform.proxy.health-check-interval"
+	dockerLabelHCTimeout         = "com.off-sync.platform.proxy.health-check-timeout"
+	dockerLabelHCRise            = "com.off-sync.platform.proxy.health-check-rise"
+	dockerLabelHCFall            = "com.off-sync.platform.proxy.health-check-fall"
+	dockerLabelCBErrors          = "com.off-sync.platform.proxy.circuit-breaker-errors"
+	dockerLabelCBEject           = "com.off-sync.platform.proxy.circuit-breaker-eject-duration"
+	dockerLabelCBHalfOpen        = "com.off-sync.platform.proxy.circuit-breaker-half-open-requests"
+	dockerLabelCBStatus          = "com.off-sync.platform.proxy.circuit-breaker-fallback-status"
+	dockerLabelCBBody            = "com.off-sync.platform.proxy.circuit-breaker-fallback-body"
+	dockerLabelRetryAttempts     = "com.off-sync.platform.proxy.retry-attempts"
+	dockerLabelRetryStatuses     = "com.off-sync.platform.proxy.retry-statuses"
+	dockerLabelRetryBudget       = "com.off-sync.platform.proxy.retry-budget"
+	dockerLabelRetryMaxBody      = "com.off-sync.platform.proxy.retry-max-body-bytes"
+	dockerLabelWeight            = "com.off-sync.platform.proxy.weight"
+	dockerLabelAlgorithm         = "com.off-sync.platform.proxy.lb-algorithm"
+	dockerLabelHashKey           = "com.off-sync.platform.proxy.lb-hash-key"
+	dockerLabelSticky            = "com.off-sync.platform.proxy.sticky"
+	dockerLabelStickyCookie      = "com.off-sync.platform.proxy.sticky-cookie-name"
+	dockerLabelStickyTTL         = "com.off-sync.platform.proxy.sticky-ttl"
+	dockerLabelStickySecure      = "com.off-sync.platform.proxy.sticky-secure"
+	dockerLabelStickyHTTPOnly    = "com.off-sync.platform.proxy.sticky-http-only"
+	dockerLabelDialTimeout       = "com.off-sync.platform.proxy.transport-dial-timeout"
+	dockerLabelTLSTimeout        = "com.off-sync.platform.proxy.transport-tls-handshake-timeout"
+	dockerLabelHeaderTimeout     = "com.off-sync.platform.proxy.transport-response-header-timeout"
+	dockerLabelKeepAlive         = "com.off-sync.platform.proxy.transport-keep-alive"
+	dockerLabelIdleTimeout       = "com.off-sync.platform.proxy.transport-idle-conn-timeout"
+	dockerLabelMaxIdleConns      = "com.off-sync.platform.proxy.transport-max-idle-conns-per-host"
+	dockerLabelDisableHTTP2      = "com.off-sync.platform.proxy.transport-disable-http2"
+	dockerLabelTLSCAFile         = "com.off-sync.platform.proxy.tls-ca-file"
+	dockerLabelTLSServerName     = "com.off-sync.platform.proxy.tls-server-name"
+	dockerLabelTLSCertFile       = "com.off-sync.platform.proxy.tls-cert-file"
+	dockerLabelTLSKeyFile        = "com.off-sync.platform.proxy.tls-key-file"
+	dockerLabelTLSLocalCA        = "com.off-sync.platform.proxy.tls-local-ca-client-cert"
+	dockerLabelTLSInsecure       = "com.off-sync.platform.proxy.tls-insecure-skip-verify"
+	dockerLabelSplitWeight       = "com.off-sync.platform.proxy.split-weight"
+	dockerLabelSplitOverride     = "com.off-sync.platform.proxy.split-override"
+	dockerLabelMirror            = "com.off-sync.platform.proxy.mirror"
+	dockerLabelMirrorPercent     = "com.off-sync.platform.proxy.mirror-percentage"
+	dockerLabelMirrorMaxBody     = "com.off-sync.platform.proxy.mirror-max-body-bytes"
+	dockerLabelClientAuthCA      = "com.off-sync.platform.proxy.client-auth-ca-file"
+	dockerLabelClientAuthMode    = "com.off-sync.platform.proxy.client-auth-mode"
+	dockerLabelClientAuthAllowed = "com.off-sync.platform.proxy.client-auth-allowed"
+	dockerLabelRules             = "com.off-sync.platform.proxy.rules"
+	dockerLabelPassthrough       = "com.off-sync.platform.proxy.passthrough"
+	dockerLabelTCPPort           = "com.off-sync.platform.proxy.tcp-port"
+	dockerLabelProxyProto        = "com.off-sync.platform.proxy.proxy-protocol"
+	dockerLabelUpgrade           = "com.off-sync.platform.proxy.upgrade"
+	dockerLabelUpgradeIdle       = "com.off-sync.platform.proxy.upgrade-idle-timeout"
+	defaultPort                  = 8080
+	defaultScheme                = "http"
 )
 
+// DefaultPollInterval is the interval on which updates are sent if no
+// interval has been set.
+const DefaultPollInterval = 30 * time.Second
+
 // ConfigProvider provides an AWS ECS based ConfigProvider implementation.
 type ConfigProvider struct {
-	notificationChans []chan<- bool
+	sync.Mutex
+	notificationChans []chan bool
 	ecsSvc            *ecs.ECS
 	cluster           *ecs.Cluster
+	pollInterval      time.Duration
+	polling           sync.Once
+}
+
+// Option configures the AWS ECS Configuration Provider.
+type Option func(*ConfigProvider)
+
+// PollInterval sets the interval on which an update is sent to the
+// notification channels, as ECS does not announce changes of the services.
+// No updates are sent if the interval is 0.
+func PollInterval(interval time.Duration) Option {
+	return func(p *ConfigProvider) {
+		p.pollInterval = interval
+	}
 }
 
 // New returns a new AWS ECS Configuration Provider. It checks the cluster
 // before returning.
-func New(ecsSvc *ecs.ECS, clusterName string) (*ConfigProvider, error) {
+func New(ecsSvc *ecs.ECS, clusterName string, options ...Option) (*ConfigProvider, error) {
 	clusters, err := ecsSvc.DescribeClusters(&ecs.DescribeClustersInput{
 		Clusters: []*string{aws.String(clusterName)},
 	})
@@ -38,16 +120,49 @@ func New(ecsSvc *ecs.ECS, clusterName string) (*ConfigProvider, error) {
 		return nil, fmt.Errorf("cluster not found")
 	}
 
-	return &ConfigProvider{
-		ecsSvc:  ecsSvc,
-		cluster: clusters.Clusters[0],
-	}, nil
+	p := &ConfigProvider{
+		ecsSvc:       ecsSvc,
+		cluster:      clusters.Clusters[0],
+		pollInterval: DefaultPollInterval,
+	}
+
+	for _, o := range options {
+		o(p)
+	}
+
+	return p, nil
 }
 
 // GetNotificationChannel creates a new channel to which configuration updates are sent.
-func (p *ConfigProvider) GetNotificationChannel() chan<- bool {
-	c := make(chan<- bool, 1)
+// Updates are sent on the poll interval, after which the services must be read
+// again to find out whether they have changed.
+func (p *ConfigProvider) GetNotificationChannel() <-chan bool {
+	c := make(chan bool, 1)
+
+	p.Lock()
 	p.notificationChans = append(p.notificationChans, c)
+	p.Unlock()
+
+	if p.pollInterval > 0 {
+		p.polling.Do(func() {
+			go p.poll()
+		})
+	}
 
 	return c
 }
+
+// poll sends an update to the notification channels on the poll interval.
+// Channels already holding an update are skipped.
+func (p *ConfigProvider) poll() {
+	for range time.Tick(p.pollInterval) {
+		p.Lock()
+		for _, c := range p.notificationChans {
+			select {
+			case c <- true:
+			default:
+			}
+		}
+		p.Unlock()
+	}
+}
@@ -3,114 +3,265 @@ package awsecs
 import (
 	"fmt"
 	"strconv"
+	"strings"
 
-	"github.com/aws/aws-sdk-go/service/ecs"
 	"github.com/off-sync/platform-proxy/domain/sites"
 )
 
-// GetBackends returns the backends of a ECS cluster. It processes all service (list-services),
-// describes them (describe-services), and describes the service's task definition (describe-task-definition).
+// GetBackends returns the backends of a ECS cluster.
 // A backend is returned for each service that has a container with the name 'server' in its task definition.
 func (p *ConfigProvider) GetBackends() ([]*sites.Backend, error) {
-	var servicesErr error
+	services, err := p.getServices()
+	if err != nil {
+		return nil, err
+	}
 
-	serviceArns := make(chan *string)
-	go func() {
-		servicesErr = p.getServiceArns(serviceArns)
+	var backends []*sites.Backend
 
-		close(serviceArns)
-	}()
+	for _, service := range services {
+		backend, err := sites.NewBackend(service.name, service.server)
+		if err != nil {
+			return nil, err
+		}
 
-	var backends []*sites.Backend
+		if backend.Servers[0].Weight, err = service.intLabel(dockerLabelWeight); err != nil {
+			return nil, err
+		}
 
-	for serviceArn := range serviceArns {
-		services, err := p.ecsSvc.DescribeServices(&ecs.DescribeServicesInput{
-			Cluster:  p.cluster.ClusterArn,
-			Services: []*string{serviceArn},
-		})
+		backend.Algorithm, err = sites.ParseAlgorithm(service.label(dockerLabelAlgorithm))
 		if err != nil {
+			return nil, fmt.Errorf("invalid %s label on service %s: %s", dockerLabelAlgorithm, service.name, err)
+		}
+
+		if hashKey := service.label(dockerLabelHashKey); hashKey != "" {
+			if backend.HashKey, err = sites.ParseHashKey(hashKey); err != nil {
+				return nil, fmt.Errorf("invalid %s label on service %s: %s", dockerLabelHashKey, service.name, err)
+			}
+		}
+
+		if backend.Transport, err = getTransport(service); err != nil {
 			return nil, err
 		}
 
-		service := services.Services[0]
+		if backend.TLS, err = getBackendTLS(service); err != nil {
+			return nil, err
+		}
 
-		taskDefArn := service.TaskDefinition
+		if backend.HealthCheck, err = getHealthCheck(service); err != nil {
+			return nil, err
+		}
 
-		taskDefServer, err := p.getTaskDefinitionServer(taskDefArn)
-		if err != nil {
+		if backend.CircuitBreaker, err = getCircuitBreaker(service); err != nil {
 			return nil, err
 		}
 
-		if taskDefServer == "" {
-			// no server container present in this task definition
-			continue
+		if backend.Retry, err = getRetry(service); err != nil {
+			return nil, err
 		}
 
-		backend, err := sites.NewBackend(*service.ServiceName, taskDefServer)
-		if err != nil {
+		if backend.StickySession, err = getStickySession(service); err != nil {
 			return nil, err
 		}
 
 		backends = append(backends, backend)
 	}
 
-	if servicesErr != nil {
-		return nil, servicesErr
+	return backends, nil
+}
+
+// getTransport returns the transport settings of the service. Settings
+// which have not been set keep their default value.
+func getTransport(service *ecsService) (*sites.Transport, error) {
+	t := &sites.Transport{}
+
+	var err error
+
+	if t.DialTimeout, err = service.durationLabel(dockerLabelDialTimeout); err != nil {
+		return nil, err
 	}
 
-	return backends, nil
+	if t.TLSHandshakeTimeout, err = service.durationLabel(dockerLabelTLSTimeout); err != nil {
+		return nil, err
+	}
+
+	if t.ResponseHeaderTimeout, err = service.durationLabel(dockerLabelHeaderTimeout); err != nil {
+		return nil, err
+	}
+
+	if t.KeepAlive, err = service.durationLabel(dockerLabelKeepAlive); err != nil {
+		return nil, err
+	}
+
+	if t.IdleConnTimeout, err = service.durationLabel(dockerLabelIdleTimeout); err != nil {
+		return nil, err
+	}
+
+	if t.MaxIdleConnsPerHost, err = service.intLabel(dockerLabelMaxIdleConns); err != nil {
+		return nil, err
+	}
+
+	if t.DisableHTTP2, err = service.boolLabel(dockerLabelDisableHTTP2); err != nil {
+		return nil, err
+	}
+
+	return t, nil
 }
 
-func (p *ConfigProvider) getServiceArns(out chan<- *string) error {
-	var nextToken *string
-	for {
-		services, err := p.ecsSvc.ListServices(&ecs.ListServicesInput{
-			Cluster:   p.cluster.ClusterArn,
-			NextToken: nextToken,
-		})
-		if err != nil {
-			return err
-		}
+// getBackendTLS returns the TLS settings of the service. It returns nil if
+// none have been set, in which case https servers are verified using the
+// system roots.
+func getBackendTLS(service *ecsService) (*sites.BackendTLS, error) {
+	t := &sites.BackendTLS{
+		CAFile:     service.label(dockerLabelTLSCAFile),
+		ServerName: service.label(dockerLabelTLSServerName),
+		CertFile:   service.label(dockerLabelTLSCertFile),
+		KeyFile:    service.label(dockerLabelTLSKeyFile),
+	}
 
-		for _, serviceArn := range services.ServiceArns {
-			out <- serviceArn
-		}
+	var err error
 
-		nextToken = services.NextToken
-		if nextToken == nil {
-			break
-		}
+	if t.LocalCAClientCert, err = service.boolLabel(dockerLabelTLSLocalCA); err != nil {
+		return nil, err
+	}
+
+	if t.InsecureSkipVerify, err = service.boolLabel(dockerLabelTLSInsecure); err != nil {
+		return nil, err
 	}
 
-	return nil
+	if *t == (sites.BackendTLS{}) {
+		return nil, nil
+	}
+
+	return t, nil
 }
 
-func (p *ConfigProvider) getTaskDefinitionServer(taskDefArn *string) (string, error) {
-	tdef, err := p.ecsSvc.DescribeTaskDefinition(&ecs.DescribeTaskDefinitionInput{
-		TaskDefinition: taskDefArn,
-	})
-	if err != nil {
-		return "", err
+// getHealthCheck returns the health check of the service. It returns nil
+// if no path has been set.
+func getHealthCheck(service *ecsService) (*sites.HealthCheck, error) {
+	path := service.label(dockerLabelHCPath)
+	if path == "" {
+		return nil, nil
 	}
 
-	for _, cdef := range tdef.TaskDefinition.ContainerDefinitions {
-		if *cdef.Name != serverContainerName {
-			// not the server
-			continue
-		}
+	hc := &sites.HealthCheck{
+		Path: path,
+	}
 
-		port := defaultPort
+	var err error
+
+	if hc.Status, err = service.intLabel(dockerLabelHCStatus); err != nil {
+		return nil, err
+	}
 
-		portLabel, found := cdef.DockerLabels[dockerLabelPort]
-		if found {
-			port, err = strconv.Atoi(*portLabel)
+	if hc.Interval, err = service.durationLabel(dockerLabelHCInterval); err != nil {
+		return nil, err
+	}
+
+	if hc.Timeout, err = service.durationLabel(dockerLabelHCTimeout); err != nil {
+		return nil, err
+	}
+
+	if hc.Rise, err = service.intLabel(dockerLabelHCRise); err != nil {
+		return nil, err
+	}
+
+	if hc.Fall, err = service.intLabel(dockerLabelHCFall); err != nil {
+		return nil, err
+	}
+
+	return hc, nil
+}
+
+// getCircuitBreaker returns the circuit breaker of the service. It returns
+// nil if no error threshold has been set.
+func getCircuitBreaker(service *ecsService) (*sites.CircuitBreaker, error) {
+	errors, err := service.intLabel(dockerLabelCBErrors)
+	if err != nil || errors <= 0 {
+		return nil, err
+	}
+
+	cb := &sites.CircuitBreaker{
+		Errors:       errors,
+		FallbackBody: service.label(dockerLabelCBBody),
+	}
+
+	if cb.EjectDuration, err = service.durationLabel(dockerLabelCBEject); err != nil {
+		return nil, err
+	}
+
+	if cb.HalfOpenRequests, err = service.intLabel(dockerLabelCBHalfOpen); err != nil {
+		return nil, err
+	}
+
+	if cb.FallbackStatus, err = service.intLabel(dockerLabelCBStatus); err != nil {
+		return nil, err
+	}
+
+	return cb, nil
+}
+
+// getRetry returns the retry settings of the service. It returns nil if
+// the number of attempts has not been set.
+func getRetry(service *ecsService) (*sites.Retry, error) {
+	attempts, err := service.intLabel(dockerLabelRetryAttempts)
+	if err != nil || attempts <= 0 {
+		return nil, err
+	}
+
+	retry := &sites.Retry{
+		Attempts: attempts,
+	}
+
+	if statuses := service.label(dockerLabelRetryStatuses); statuses != "" {
+		for _, s := range strings.Split(statuses, ",") {
+			status, err := strconv.Atoi(strings.TrimSpace(s))
 			if err != nil {
-				return "", fmt.Errorf("invalid port: %s", *portLabel)
+				return nil, fmt.Errorf("invalid %s label on service %s: %s", dockerLabelRetryStatuses, service.name, statuses)
 			}
+
+			retry.Statuses = append(retry.Statuses, status)
 		}
+	}
+
+	if budget := service.label(dockerLabelRetryBudget); budget != "" {
+		if retry.Budget, err = strconv.ParseFloat(budget, 64); err != nil {
+			return nil, fmt.Errorf("invalid %s label on service %s: %s", dockerLabelRetryBudget, service.name, budget)
+		}
+	}
+
+	maxBody, err := service.intLabel(dockerLabelRetryMaxBody)
+	if err != nil {
+		return nil, err
+	}
+
+	retry.MaxBodyBytes = int64(maxBody)
+
+	return retry, nil
+}
+
+// getStickySession returns the sticky session settings of the service. It
+// returns nil if sticky sessions are not enabled.
+func getStickySession(service *ecsService) (*sites.StickySession, error) {
+	enabled, err := service.boolLabel(dockerLabelSticky)
+	if err != nil || !enabled {
+		return nil, err
+	}
+
+	sticky := &sites.StickySession{
+		CookieName: service.label(dockerLabelStickyCookie),
+	}
+
+	if sticky.TTL, err = service.durationLabel(dockerLabelStickyTTL); err != nil {
+		return nil, err
+	}
+
+	if sticky.Secure, err = service.boolLabel(dockerLabelStickySecure); err != nil {
+		return nil, err
+	}
 
-		return fmt.Sprintf("http://%s:%d", *cdef.Hostname, port), nil
+	if sticky.HTTPOnly, err = service.boolLabel(dockerLabelStickyHTTPOnly); err != nil {
+		return nil, err
 	}
 
-	return "", nil
+	return sticky, nil
 }
@@ -1,25 +1,279 @@
 package awsecs
 
 import (
+	"encoding/json"
 	"fmt"
+	"strconv"
+	"time"
 
+	"github.com/off-sync/platform-proxy/common/sitesjson"
 	"github.com/off-sync/platform-proxy/domain/sites"
 )
 
-// GetFrontends returns a list of frontends based on the backends.
+// GetFrontends returns a list of frontends based on the services.
+// The domain defaults to '<service name>.qa.off-sync.net', and can be
+// overridden using a Docker label. Other Docker labels on the server
+// container restrict the frontend to a path.
+// Services with a split weight which share the same domain and path rules
+// are combined into a single frontend splitting requests over their
+// backends. The other settings are taken from the first service.
 func (p *ConfigProvider) GetFrontends() ([]*sites.Frontend, error) {
 	var frontends []*sites.Frontend
+	splitFrontends := make(map[string]*sites.Frontend)
 
-	backends, err := p.GetBackends()
+	services, err := p.getServices()
 	if err != nil {
 		return nil, err
 	}
 
-	for _, backend := range backends {
-		frontends = append(frontends, sites.NewFrontend(
-			backend.Name,
-			fmt.Sprintf("%s.qa.off-sync.net", backend.Name)))
+	for _, service := range services {
+		domain := service.label(dockerLabelDomain)
+		if domain == "" {
+			domain = fmt.Sprintf("%s.qa.off-sync.net", service.name)
+		}
+
+		frontend := sites.NewFrontend(service.name, domain)
+
+		frontend.CertGroup = service.label(dockerLabelCertGroup)
+		frontend.PathPrefix = service.label(dockerLabelPathPrefix)
+		frontend.PathRegex = service.label(dockerLabelPathRegex)
+
+		if frontend.StripPrefix, err = service.boolLabel(dockerLabelStripPrefix); err != nil {
+			return nil, err
+		}
+
+		if frontend.Priority, err = service.intLabel(dockerLabelPriority); err != nil {
+			return nil, err
+		}
+
+		if frontend.HSTS, err = getHSTS(service); err != nil {
+			return nil, err
+		}
+
+		if frontend.Passthrough, err = service.boolLabel(dockerLabelPassthrough); err != nil {
+			return nil, err
+		}
+
+		if frontend.TCPPort, err = service.intLabel(dockerLabelTCPPort); err != nil {
+			return nil, err
+		}
+
+		frontend.ProxyProtocol, err = sites.ParseProxyProtocol(service.label(dockerLabelProxyProto))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s label on service %s: %s", dockerLabelProxyProto, service.name, err)
+		}
+
+		if frontend.Upgrade, err = getUpgrade(service); err != nil {
+			return nil, err
+		}
+
+		if frontend.Mirror, err = getMirror(service); err != nil {
+			return nil, err
+		}
+
+		if frontend.ClientAuth, err = getClientAuth(service); err != nil {
+			return nil, err
+		}
+
+		if rules := service.label(dockerLabelRules); rules != "" {
+			frontend.Rules, err = sitesjson.ParseRules([]byte(rules))
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s label on service %s: %s", dockerLabelRules, service.name, err)
+			}
+		}
+
+		split, err := getSplit(service)
+		if err != nil {
+			return nil, err
+		}
+
+		if split == nil {
+			frontends = append(frontends, frontend)
+			continue
+		}
+
+		if override := service.label(dockerLabelSplitOverride); override != "" {
+			if frontend.SplitOverride, err = sites.ParseSplitOverride(override); err != nil {
+				return nil, fmt.Errorf("invalid %s label on service %s: %s", dockerLabelSplitOverride, service.name, err)
+			}
+		}
+
+		existing, found := splitFrontends[frontend.RouteKey()]
+		if !found {
+			frontend.Splits = []*sites.BackendSplit{split}
+			splitFrontends[frontend.RouteKey()] = frontend
+			frontends = append(frontends, frontend)
+			continue
+		}
+
+		existing.Splits = append(existing.Splits, split)
+
+		if existing.SplitOverride == nil {
+			existing.SplitOverride = frontend.SplitOverride
+		}
 	}
 
 	return frontends, nil
 }
+
+// getSplit returns the split of the service, or nil if it has no split weight.
+func getSplit(service *ecsService) (*sites.BackendSplit, error) {
+	if service.label(dockerLabelSplitWeight) == "" {
+		return nil, nil
+	}
+
+	weight, err := service.intLabel(dockerLabelSplitWeight)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sites.BackendSplit{
+		BackendName: service.name,
+		Weight:      weight,
+	}, nil
+}
+
+// getMirror returns the mirror settings of the service. It returns nil if
+// no mirror backend has been set.
+func getMirror(service *ecsService) (*sites.Mirror, error) {
+	backendName := service.label(dockerLabelMirror)
+	if backendName == "" {
+		return nil, nil
+	}
+
+	mirror := &sites.Mirror{
+		BackendName: backendName,
+	}
+
+	if percentage := service.label(dockerLabelMirrorPercent); percentage != "" {
+		var err error
+		if mirror.Percentage, err = strconv.ParseFloat(percentage, 64); err != nil {
+			return nil, fmt.Errorf("invalid %s label on service %s: %s", dockerLabelMirrorPercent, service.name, percentage)
+		}
+	}
+
+	maxBody, err := service.intLabel(dockerLabelMirrorMaxBody)
+	if err != nil {
+		return nil, err
+	}
+
+	mirror.MaxBodyBytes = int64(maxBody)
+
+	return mirror, nil
+}
+
+// getClientAuth returns the client authentication settings of the service.
+// It returns nil if no CA file has been set.
+func getClientAuth(service *ecsService) (*sites.ClientAuth, error) {
+	caFile := service.label(dockerLabelClientAuthCA)
+	if caFile == "" {
+		return nil, nil
+	}
+
+	mode, err := sites.ParseClientAuthMode(service.label(dockerLabelClientAuthMode))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s label on service %s: %s", dockerLabelClientAuthMode, service.name, err)
+	}
+
+	clientAuth := &sites.ClientAuth{
+		CAFile: caFile,
+		Mode:   mode,
+	}
+
+	// a JSON array, as the regular expressions may contain commas
+	if allowed := service.label(dockerLabelClientAuthAllowed); allowed != "" {
+		if err := json.Unmarshal([]byte(allowed), &clientAuth.AllowedPatterns); err != nil {
+			return nil, fmt.Errorf("invalid %s label on service %s: %s", dockerLabelClientAuthAllowed, service.name, err)
+		}
+	}
+
+	return clientAuth, nil
+}
+
+// getHSTS returns the HSTS policy of the service. It returns nil if
+// no maximum age has been set.
+func getHSTS(service *ecsService) (*sites.HSTS, error) {
+	maxAge, err := service.intLabel(dockerLabelHSTSMaxAge)
+	if err != nil || maxAge <= 0 {
+		return nil, err
+	}
+
+	hsts := &sites.HSTS{
+		MaxAge: time.Duration(maxAge) * time.Second,
+	}
+
+	if hsts.IncludeSubDomains, err = service.boolLabel(dockerLabelHSTSSubs); err != nil {
+		return nil, err
+	}
+
+	if hsts.Preload, err = service.boolLabel(dockerLabelHSTSPreload); err != nil {
+		return nil, err
+	}
+
+	return hsts, nil
+}
+
+// getUpgrade returns the upgrade policy of the service. It returns nil if
+// upgrades are not enabled.
+func getUpgrade(service *ecsService) (*sites.Upgrade, error) {
+	enabled, err := service.boolLabel(dockerLabelUpgrade)
+	if err != nil || !enabled {
+		return nil, err
+	}
+
+	upgrade := &sites.Upgrade{}
+
+	if upgrade.IdleTimeout, err = service.durationLabel(dockerLabelUpgradeIdle); err != nil {
+		return nil, err
+	}
+
+	return upgrade, nil
+}
+
+// boolLabel returns the boolean value of a Docker label of the server
+// container, or false if it is not set.
+func (s *ecsService) boolLabel(name string) (bool, error) {
+	value := s.label(name)
+	if value == "" {
+		return false, nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s label on service %s: %s", name, s.name, value)
+	}
+
+	return b, nil
+}
+
+// intLabel returns the integer value of a Docker label of the server
+// container, or 0 if it is not set.
+func (s *ecsService) intLabel(name string) (int, error) {
+	value := s.label(name)
+	if value == "" {
+		return 0, nil
+	}
+
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s label on service %s: %s", name, s.name, value)
+	}
+
+	return i, nil
+}
+
+// durationLabel returns the duration value of a Docker label of the server
+// container, or 0 if it is not set.
+func (s *ecsService) durationLabel(name string) (time.Duration, error) {
+	value := s.label(name)
+	if value == "" {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s label on service %s: %s", name, s.name, value)
+	}
+
+	return d, nil
+}
@@ -0,0 +1,150 @@
+package awsecs
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+// ecsService holds the information of an ECS service used to configure
+// its backend and frontend.
+type ecsService struct {
+	// name holds the name of the service.
+	name string
+
+	// server holds the URL of the server container.
+	server string
+
+	// labels holds the Docker labels of the server container.
+	labels map[string]string
+}
+
+// label returns the value of a Docker label of the server container,
+// or the empty string if it is not set.
+func (s *ecsService) label(name string) string {
+	return s.labels[name]
+}
+
+// getServices processes all services (list-services), describes them (describe-services),
+// and describes the service's task definition (describe-task-definition).
+// A service is returned for each service that has a container with the name 'server'
+// in its task definition.
+func (p *ConfigProvider) getServices() ([]*ecsService, error) {
+	var servicesErr error
+
+	serviceArns := make(chan *string)
+	go func() {
+		servicesErr = p.getServiceArns(serviceArns)
+
+		close(serviceArns)
+	}()
+
+	var ecsServices []*ecsService
+
+	for serviceArn := range serviceArns {
+		services, err := p.ecsSvc.DescribeServices(&ecs.DescribeServicesInput{
+			Cluster:  p.cluster.ClusterArn,
+			Services: []*string{serviceArn},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		service := services.Services[0]
+
+		cdef, err := p.getServerContainer(service.TaskDefinition)
+		if err != nil {
+			return nil, err
+		}
+
+		if cdef == nil {
+			// no server container present in this task definition
+			continue
+		}
+
+		server, err := getServerURL(cdef)
+		if err != nil {
+			return nil, err
+		}
+
+		ecsServices = append(ecsServices, &ecsService{
+			name:   *service.ServiceName,
+			server: server,
+			labels: aws.StringValueMap(cdef.DockerLabels),
+		})
+	}
+
+	if servicesErr != nil {
+		return nil, servicesErr
+	}
+
+	return ecsServices, nil
+}
+
+func (p *ConfigProvider) getServiceArns(out chan<- *string) error {
+	var nextToken *string
+	for {
+		services, err := p.ecsSvc.ListServices(&ecs.ListServicesInput{
+			Cluster:   p.cluster.ClusterArn,
+			NextToken: nextToken,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, serviceArn := range services.ServiceArns {
+			out <- serviceArn
+		}
+
+		nextToken = services.NextToken
+		if nextToken == nil {
+			break
+		}
+	}
+
+	return nil
+}
+
+func (p *ConfigProvider) getServerContainer(taskDefArn *string) (*ecs.ContainerDefinition, error) {
+	tdef, err := p.ecsSvc.DescribeTaskDefinition(&ecs.DescribeTaskDefinitionInput{
+		TaskDefinition: taskDefArn,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, cdef := range tdef.TaskDefinition.ContainerDefinitions {
+		if *cdef.Name != serverContainerName {
+			// not the server
+			continue
+		}
+
+		return cdef, nil
+	}
+
+	return nil, nil
+}
+
+func getServerURL(cdef *ecs.ContainerDefinition) (string, error) {
+	port := defaultPort
+
+	portLabel, found := cdef.DockerLabels[dockerLabelPort]
+	if found {
+		var err error
+		port, err = strconv.Atoi(*portLabel)
+		if err != nil {
+			return "", fmt.Errorf("invalid port: %s", *portLabel)
+		}
+	}
+
+	scheme := defaultScheme
+
+	schemeLabel, found := cdef.DockerLabels[dockerLabelScheme]
+	if found && *schemeLabel != "" {
+		scheme = *schemeLabel
+	}
+
+	return fmt.Sprintf("%s://%s:%d", scheme, *cdef.Hostname, port), nil
+}
@@ -0,0 +1,88 @@
+package certcache
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/off-sync/platform-proxy/app/interfaces"
+	"github.com/off-sync/platform-proxy/domain/certs"
+)
+
+// DefaultTTL is the default time for which certificates are cached.
+const DefaultTTL = 10 * time.Minute
+
+// MemoryCertCache implements the CertLoader and CertCache interfaces.
+// It keeps certificates loaded from another loader in memory for a limited
+// time, so changes made by other processes (like revocations) are picked up
+// once the cached certificate expires.
+type MemoryCertCache struct {
+	sync.RWMutex
+	ldr     interfaces.CertLoader
+	ttl     time.Duration
+	time    interfaces.Time
+	entries map[string]*memoryCacheEntry
+}
+
+type memoryCacheEntry struct {
+	crt       *certs.Certificate
+	expiresAt time.Time
+}
+
+// NewMemoryCertCache creates a new in-memory certificate cache in front
+// of the provided loader.
+func NewMemoryCertCache(ldr interfaces.CertLoader, ttl time.Duration, time interfaces.Time) *MemoryCertCache {
+	return &MemoryCertCache{
+		ldr:     ldr,
+		ttl:     ttl,
+		time:    time,
+		entries: make(map[string]*memoryCacheEntry),
+	}
+}
+
+func cacheKey(domains []string) string {
+	return strings.Join(domains, ",")
+}
+
+// Load returns the cached certificate for a list of domains, or loads it if
+// it is not cached or has expired. Missing certificates are not cached.
+func (c *MemoryCertCache) Load(domains []string) (*certs.Certificate, error) {
+	key := cacheKey(domains)
+	now := c.time.Now()
+
+	c.RLock()
+	entry, found := c.entries[key]
+	c.RUnlock()
+
+	if found && entry.expiresAt.After(now) {
+		return entry.crt, nil
+	}
+
+	crt, err := c.ldr.Load(domains)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	if crt == nil {
+		delete(c.entries, key)
+		return nil, nil
+	}
+
+	c.entries[key] = &memoryCacheEntry{
+		crt:       crt,
+		expiresAt: now.Add(c.ttl),
+	}
+
+	return crt, nil
+}
+
+// Evict removes the certificate for a list of domains from the cache.
+func (c *MemoryCertCache) Evict(domains []string) {
+	c.Lock()
+	defer c.Unlock()
+
+	delete(c.entries, cacheKey(domains))
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/rand"
 	"crypto/rsa"
 	"fmt"
+	"strings"
 
 	"github.com/off-sync/platform-proxy/app/interfaces"
 	"github.com/off-sync/platform-proxy/common/logging"
@@ -29,6 +30,23 @@ type LegoACMECertGen struct {
 	keyBits int
 }
 
+// LegoACMECertGenOption configures the ACME certificate generator.
+type LegoACMECertGenOption func(*LegoACMECertGen) error
+
+// HTTP01 configures the generator to solve HTTP-01 challenges using the
+// provided provider instead of DNS-01 challenges using AWS Route 53.
+func HTTP01(provider *HTTP01Provider) LegoACMECertGenOption {
+	return func(g *LegoACMECertGen) error {
+		if err := g.client.SetChallengeProvider(lego.HTTP01, provider); err != nil {
+			return err
+		}
+
+		g.client.ExcludeChallenges([]lego.Challenge{lego.TLSSNI01, lego.DNS01})
+
+		return nil
+	}
+}
+
 // SetLegoLogger sets the logger used by the lego library.
 func SetLegoLogger(log interfaces.Logger) error {
 	if log == nil {
@@ -41,7 +59,8 @@ func SetLegoLogger(log interfaces.Logger) error {
 }
 
 // NewLegoACMECertGen creates a new ACME certificate generator for the provided account.
-func NewLegoACMECertGen(account *acme.Account, log interfaces.Logger) (*LegoACMECertGen, error) {
+// By default it solves DNS-01 challenges using AWS Route 53.
+func NewLegoACMECertGen(account *acme.Account, log interfaces.Logger, options ...LegoACMECertGenOption) (*LegoACMECertGen, error) {
 	if account == nil {
 		return nil, fmt.Errorf("missing account")
 	}
@@ -63,15 +82,31 @@ func NewLegoACMECertGen(account *acme.Account, log interfaces.Logger) (*LegoACME
 	acmeClient.SetChallengeProvider(lego.DNS01, provider)
 	acmeClient.ExcludeChallenges([]lego.Challenge{lego.TLSSNI01, lego.HTTP01})
 
-	return &LegoACMECertGen{
+	g := &LegoACMECertGen{
 		user:    account,
 		client:  acmeClient,
 		keyBits: 4096,
-	}, nil
+	}
+
+	for _, o := range options {
+		if err := o(g); err != nil {
+			return nil, err
+		}
+	}
+
+	return g, nil
 }
 
 // GenCert generates a certificate using the provided ACME endpoint.
+// Wildcard domains are rejected, as the ACME v1 protocol used by lego
+// cannot issue wildcard certificates.
 func (g *LegoACMECertGen) GenCert(domains []string) (*certs.Certificate, error) {
+	for _, domain := range domains {
+		if strings.HasPrefix(domain, "*.") {
+			return nil, fmt.Errorf("wildcard certificates are not supported: %s", domain)
+		}
+	}
+
 	key, err := rsa.GenerateKey(rand.Reader, g.keyBits)
 	if err != nil {
 		return nil, fmt.Errorf("generating RSA private key: %s", err)
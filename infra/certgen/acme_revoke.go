@@ -0,0 +1,128 @@
+package certgen
+
+import (
+	"crypto"
+	"fmt"
+	"net/http"
+
+	certsCom "github.com/off-sync/platform-proxy/common/certs"
+	"github.com/off-sync/platform-proxy/domain/acme"
+	"github.com/off-sync/platform-proxy/domain/certs"
+	"github.com/off-sync/platform-proxy/infra/acmev2"
+)
+
+const (
+	// LetsEncryptStagingDirectory holds the RFC 8555 directory URL of the
+	// Let's Encrypt staging environment.
+	LetsEncryptStagingDirectory = "https://acme-staging-v02.api.letsencrypt.org/directory"
+
+	// LetsEncryptProductionDirectory holds the RFC 8555 directory URL of the
+	// Let's Encrypt production environment.
+	LetsEncryptProductionDirectory = "https://acme-v02.api.letsencrypt.org/directory"
+)
+
+// RevocationDirectory returns the RFC 8555 directory URL through which
+// certificates issued by the provided endpoint are revoked. Accounts of the
+// Let's Encrypt ACME v1 endpoints are valid for their RFC 8555 counterparts.
+// Other endpoints, like Pebble, are returned unchanged.
+func RevocationDirectory(endpoint string) string {
+	switch endpoint {
+	case LetsEncryptStagingEndpoint:
+		return LetsEncryptStagingDirectory
+	case LetsEncryptProductionEndpoint:
+		return LetsEncryptProductionDirectory
+	}
+
+	return endpoint
+}
+
+// ACMERevoker implements the CertRevoker interface using the revocation
+// defined in RFC 8555, which the lego ACME v1 client does not provide.
+// RSA and ECDSA keys are supported.
+type ACMERevoker struct {
+	account   *acme.Account
+	directory string
+	client    *http.Client
+}
+
+// ACMERevokerOption configures the ACME revoker.
+type ACMERevokerOption func(*ACMERevoker) error
+
+// RevokerHTTPClient configures the HTTP client used to reach the ACME server.
+func RevokerHTTPClient(client *http.Client) ACMERevokerOption {
+	return func(r *ACMERevoker) error {
+		if client == nil {
+			return fmt.Errorf("missing HTTP client")
+		}
+
+		r.client = client
+
+		return nil
+	}
+}
+
+// NewACMERevoker creates a new revoker for certificates of the account,
+// using the ACME server with the provided directory URL.
+func NewACMERevoker(account *acme.Account, directory string, options ...ACMERevokerOption) (*ACMERevoker, error) {
+	if account == nil {
+		return nil, fmt.Errorf("missing account")
+	}
+
+	r := &ACMERevoker{
+		account:   account,
+		directory: directory,
+		client:    http.DefaultClient,
+	}
+
+	for _, o := range options {
+		if err := o(r); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// Revoke revokes a certificate with the ACME server, passing the provided
+// reason code. The request is signed using the account key, or using the
+// private key of the certificate if useCertKey is set. The latter allows
+// revocation of certificates issued to another account, provided their
+// key is known.
+func (r *ACMERevoker) Revoke(crt *certs.Certificate, reason certs.RevocationReason, useCertKey bool) error {
+	leaf, err := certsCom.ParseLeaf(crt)
+	if err != nil {
+		return fmt.Errorf("parsing certificate: %s", err)
+	}
+
+	client, err := acmev2.NewClient(r.client, r.directory)
+	if err != nil {
+		return err
+	}
+
+	var key crypto.Signer
+	var kid string
+
+	if useCertKey {
+		// the certificate key is not registered: embed it in the request
+		key, err = certsCom.DecodePrivateKey(crt.PrivateKey)
+		if err != nil {
+			return fmt.Errorf("decoding certificate private key: %s", err)
+		}
+	} else {
+		key, err = certsCom.DecodePrivateKey([]byte(r.account.PrivateKey))
+		if err != nil {
+			return fmt.Errorf("decoding account private key: %s", err)
+		}
+
+		kid, err = client.AccountURL(key)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := client.RevokeCert(leaf.Raw, int(reason), key, kid); err != nil {
+		return fmt.Errorf("revoking certificate: %s", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package certgen
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// HTTP01Provider implements the lego ChallengeProvider interface for the
+// HTTP-01 challenge. It keeps the key authorizations in memory and serves
+// them as an http.Handler, which must be reachable on port 80 of the domains.
+// As challenges are kept in memory, the certificate must be generated by the
+// same process that serves the challenges.
+type HTTP01Provider struct {
+	sync.RWMutex
+	keyAuths map[string]string
+}
+
+// NewHTTP01Provider creates a new HTTP-01 challenge provider.
+func NewHTTP01Provider() *HTTP01Provider {
+	return &HTTP01Provider{
+		keyAuths: make(map[string]string),
+	}
+}
+
+// Present makes the key authorization for the token available.
+func (p *HTTP01Provider) Present(domain, token, keyAuth string) error {
+	p.Lock()
+	defer p.Unlock()
+
+	p.keyAuths[domain+"/"+token] = keyAuth
+
+	return nil
+}
+
+// CleanUp removes the key authorization for the token.
+func (p *HTTP01Provider) CleanUp(domain, token, keyAuth string) error {
+	p.Lock()
+	defer p.Unlock()
+
+	delete(p.keyAuths, domain+"/"+token)
+
+	return nil
+}
+
+// ServeHTTP answers a challenge request for a token.
+func (p *HTTP01Provider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+
+	host := r.Host
+	if i := strings.LastIndex(host, ":"); i >= 0 {
+		host = host[:i]
+	}
+
+	p.RLock()
+	keyAuth, found := p.keyAuths[host+"/"+token]
+	p.RUnlock()
+
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte(keyAuth))
+}
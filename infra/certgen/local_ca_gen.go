@@ -0,0 +1,116 @@
+package certgen
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/off-sync/platform-proxy/domain/certs"
+)
+
+// DefaultLocalCAValidity is the validity of certificates issued by the
+// local CA.
+const DefaultLocalCAValidity = 30 * 24 * time.Hour
+
+// LocalCACertGen implements the CertGen interface by issuing certificates
+// signed by a local CA, e.g. for authenticating the proxy to backend
+// servers which trust the CA. The certificates can be used for both server
+// and client authentication.
+type LocalCACertGen struct {
+	ca       *x509.Certificate
+	key      crypto.Signer
+	validity time.Duration
+}
+
+// NewLocalCA creates a new local CA certificate generator from the PEM
+// encoded CA certificate and private key.
+func NewLocalCA(certPEM, keyPEM []byte) (*LocalCACertGen, error) {
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("loading CA key pair: %s", err)
+	}
+
+	ca, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("parsing CA certificate: %s", err)
+	}
+
+	if !ca.IsCA {
+		return nil, fmt.Errorf("certificate of %s is not a CA certificate", ca.Subject.CommonName)
+	}
+
+	key, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported CA private key")
+	}
+
+	return &LocalCACertGen{
+		ca:       ca,
+		key:      key,
+		validity: DefaultLocalCAValidity,
+	}, nil
+}
+
+// GenCert issues a certificate for the domains, signed by the CA. The first
+// domain is used as common name. The certificate and private key are PEM
+// encoded; the certificate is followed by the CA certificate.
+func (g *LocalCACertGen) GenCert(domains []string) (*certs.Certificate, error) {
+	if len(domains) < 1 {
+		return nil, fmt.Errorf("domains missing: provide at least 1 domain")
+	}
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 128)
+	serialNumber, err := rand.Int(rand.Reader, serialNumberLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %v", err)
+	}
+
+	notBefore := time.Now().UTC().Add(-5 * time.Minute)
+
+	template := x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject: pkix.Name{
+			Organization: []string{"Off-Sync.com"},
+			CommonName:   domains[0],
+		},
+		NotBefore: notBefore,
+		NotAfter:  notBefore.Add(g.validity),
+
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+
+		DNSNames: domains,
+	}
+
+	crtBytes, err := x509.CreateCertificate(rand.Reader, &template, g.ca, &priv.PublicKey, g.key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate: %v", err)
+	}
+
+	keyBytes, err := x509.MarshalECPrivateKey(priv)
+	if err != nil {
+		return nil, err
+	}
+
+	crtPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: crtBytes})
+	crtPEM = append(crtPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: g.ca.Raw})...)
+
+	return &certs.Certificate{
+		Certificate: crtPEM,
+		PrivateKey:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes}),
+	}, nil
+}
@@ -2,13 +2,8 @@ package certstore
 
 import (
 	"fmt"
-	"strings"
 	"time"
 
-	"crypto/sha256"
-
-	"encoding/hex"
-
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/client"
@@ -57,6 +52,9 @@ const (
 
 type dynamoDBCert struct {
 	// Domains holds the domains for which this certificate is applicable.
+	// They are stored as a list (L), as their order determines the hash.
+	// Earlier versions stored them as a string set (SS), which loses the
+	// order: it is restored from the hash when such items are listed.
 	Domains []string
 
 	// SaveToken is used to prevent race-conditions when a certificate needs to
@@ -82,11 +80,32 @@ type dynamoDBCert struct {
 	// NotAfter holds the expiry date in UTC for the certificate.
 	// This field is used for automatic cleanup by the DynamoDB TTL functionality.
 	NotAfter time.Time
+
+	// Revoked holds the time in UTC at which the certificate was revoked.
+	Revoked time.Time
 }
 
+// writeCondition defines when a certificate item may be written to the table.
+type writeCondition int
+
+const (
+	// writeIfNotExists only writes the item if it does not exist yet.
+	writeIfNotExists writeCondition = iota
+
+	// writeIfClaimable only writes the item if it holds no active save token.
+	writeIfClaimable
+
+	// writeIfTokenValid only writes the item if the provided save token is still active.
+	writeIfTokenValid
+)
+
+// attributeTypeNull is the DynamoDB type of NULL attributes, as used by the
+// attribute_type condition function. The SDK only defines the scalar key
+// attribute types.
+const attributeTypeNull = "NULL"
+
 func (c *dynamoDBCert) hash() string {
-	hash := sha256.Sum256([]byte(strings.Join(c.Domains, ",")))
-	return hex.EncodeToString(hash[:])
+	return domainsHash(c.Domains)
 }
 
 func (s *DynamoDBCertStore) getItem(hash string, attrs ...string) (*dynamodb.GetItemOutput, error) {
@@ -110,7 +129,7 @@ func (s *DynamoDBCertStore) getCert(domains []string) (*dynamoDBCert, error) {
 		Domains: domains,
 	}
 
-	i, err := s.getItem(c.hash(), "SaveToken", "SaveTokenExpiresAt", "Created", "Modified", "PrivateKey", "Certificate", "NotAfter")
+	i, err := s.getItem(c.hash(), "SaveToken", "SaveTokenExpiresAt", "Created", "Modified", "PrivateKey", "Certificate", "NotAfter", "Revoked")
 	if err != nil {
 		return nil, err
 	}
@@ -120,72 +139,111 @@ func (s *DynamoDBCertStore) getCert(domains []string) (*dynamoDBCert, error) {
 		return nil, nil
 	}
 
-	c.SaveToken = dyndbutil.StringValue(i.Item["SaveToken"])
-	c.SaveTokenExpiresAt, err = dyndbutil.TimeValue(i.Item["SaveTokenExpiresAt"])
-	if err != nil {
+	if err := c.parseItem(i.Item); err != nil {
 		return nil, err
 	}
 
-	c.Created, err = dyndbutil.TimeValue(i.Item["Created"])
+	return c, nil
+}
+
+func (c *dynamoDBCert) parseItem(item map[string]*dynamodb.AttributeValue) error {
+	var err error
+
+	c.SaveToken = dyndbutil.StringValue(item["SaveToken"])
+	c.SaveTokenExpiresAt, err = dyndbutil.TimeValue(item["SaveTokenExpiresAt"])
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	c.Modified, err = dyndbutil.TimeValue(i.Item["Modified"])
+	c.Created, err = dyndbutil.TimeValue(item["Created"])
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	c.PrivateKey = dyndbutil.StringValue(i.Item["PrivateKey"])
-	c.Certificate = dyndbutil.StringValue(i.Item["Certificate"])
-	c.NotAfter, err = dyndbutil.TimeValue(i.Item["NotAfter"])
+	c.Modified, err = dyndbutil.TimeValue(item["Modified"])
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return c, nil
-}
+	c.PrivateKey = dyndbutil.StringValue(item["PrivateKey"])
+	c.Certificate = dyndbutil.StringValue(item["Certificate"])
+	c.NotAfter, err = dyndbutil.TimeValue(item["NotAfter"])
+	if err != nil {
+		return err
+	}
 
-func (s *DynamoDBCertStore) putCert(crt *dynamoDBCert) error {
-	item := &dynamodb.PutItemInput{}
+	if revoked, found := item["Revoked"]; found {
+		c.Revoked, err = dyndbutil.TimeValue(revoked)
+		if err != nil {
+			return err
+		}
+	}
 
+	return nil
+}
+
+func (s *DynamoDBCertStore) putCert(crt *dynamoDBCert, cond writeCondition, token string) error {
 	now := s.time.Now()
 	if crt.Created.IsZero() {
 		// never saved before: set created
 		crt.Created = now
+	} else {
+		// already exists: set modified
+		crt.Modified = now
+	}
+
+	return s.writeCert(crt, cond, token)
+}
+
+func (s *DynamoDBCertStore) writeCert(crt *dynamoDBCert, cond writeCondition, token string) error {
+	item := &dynamodb.PutItemInput{}
 
+	now := s.time.Now()
+	switch cond {
+	case writeIfNotExists:
 		// check that this item does not exist
 		item.ConditionExpression = aws.String("attribute_not_exists(#hash)")
 		item.ExpressionAttributeNames = map[string]*string{
 			"#hash": aws.String("Hash"),
 		}
-	} else {
-		// already exists: set modified
-		crt.Modified = now
-
+	case writeIfClaimable:
+		// check that no other process claimed a save token in the meantime
+		item.ConditionExpression = aws.String("attribute_type(SaveToken, :null) or (SaveTokenExpiresAt <= :now)")
+		item.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
+			":null": dyndbutil.StringAttr(attributeTypeNull),
+			":now":  dyndbutil.TimeAttr(now),
+		}
+	case writeIfTokenValid:
 		// check that the save tokens match
 		item.ConditionExpression = aws.String("(SaveToken = :saveToken) and (SaveTokenExpiresAt > :now)")
 		item.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
-			":saveToken": dyndbutil.StringAttr(crt.SaveToken),
+			":saveToken": dyndbutil.StringAttr(token),
 			":now":       dyndbutil.TimeAttr(now),
 		}
 	}
 
-	item.Item = map[string]*dynamodb.AttributeValue{
-		"Hash":               dyndbutil.StringAttr(crt.hash()),
-		"Domains":            dyndbutil.StringListAttr(crt.Domains),
-		"SaveToken":          dyndbutil.StringAttr(crt.SaveToken),
-		"SaveTokenExpiresAt": dyndbutil.TimeAttr(crt.SaveTokenExpiresAt),
-		"Created":            dyndbutil.TimeAttr(crt.Created),
-		"Modified":           dyndbutil.TimeAttr(crt.Modified),
-		"PrivateKey":         dyndbutil.StringAttr(crt.PrivateKey),
-		"Certificate":        dyndbutil.StringAttr(crt.Certificate),
-		"NotAfter":           dyndbutil.TimeAttr(crt.NotAfter),
-	}
+	item.Item = crt.item()
 
 	return s.putItem(item)
 }
 
+// item returns the attributes of the certificate as stored in the table.
+// The domains are stored as a list as their order determines the hash.
+func (c *dynamoDBCert) item() map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"Hash":               dyndbutil.StringAttr(c.hash()),
+		"Domains":            dyndbutil.StringListAttr(c.Domains),
+		"SaveToken":          dyndbutil.StringAttr(c.SaveToken),
+		"SaveTokenExpiresAt": dyndbutil.TimeAttr(c.SaveTokenExpiresAt),
+		"Created":            dyndbutil.TimeAttr(c.Created),
+		"Modified":           dyndbutil.TimeAttr(c.Modified),
+		"PrivateKey":         dyndbutil.StringAttr(c.PrivateKey),
+		"Certificate":        dyndbutil.StringAttr(c.Certificate),
+		"NotAfter":           dyndbutil.TimeAttr(c.NotAfter),
+		"Revoked":            dyndbutil.TimeAttr(c.Revoked),
+	}
+}
+
 // ClaimSaveToken tries to claim a save token in the store.
 // ErrTokenAlreadyClaimed is returned if a non-expired token is already present.
 func (s *DynamoDBCertStore) ClaimSaveToken(domains []string) (interfaces.CertSaveToken, error) {
@@ -195,16 +253,19 @@ func (s *DynamoDBCertStore) ClaimSaveToken(domains []string) (interfaces.CertSav
 		return "", err
 	}
 
+	cond := writeIfClaimable
 	if c == nil {
 		// certificate does not exist yet: create a new one
 		c = &dynamoDBCert{
 			Domains: domains,
 		}
+
+		cond = writeIfNotExists
 	}
 
 	now := s.time.Now()
 
-	if c.SaveToken != "" && c.SaveTokenExpiresAt.Before(now) {
+	if c.SaveToken != "" && c.SaveTokenExpiresAt.After(now) {
 		// non-expired save token present: return ErrTokenAlreadyClaimed
 		return "", interfaces.ErrTokenAlreadyClaimed
 	}
@@ -220,8 +281,13 @@ func (s *DynamoDBCertStore) ClaimSaveToken(domains []string) (interfaces.CertSav
 	}
 
 	// save the certificate to the table
-	err = s.putCert(c)
+	err = s.putCert(c, cond, "")
 	if err != nil {
+		if isConditionalCheckFailed(err) {
+			// another process claimed a save token in the meantime
+			return "", interfaces.ErrTokenAlreadyClaimed
+		}
+
 		return "", err
 	}
 
@@ -229,6 +295,7 @@ func (s *DynamoDBCertStore) ClaimSaveToken(domains []string) (interfaces.CertSav
 }
 
 // Save tries to save a certificate to the store. It is concurrent-safe.
+// The save token is released once the certificate has been saved.
 func (s *DynamoDBCertStore) Save(domains []string, token interfaces.CertSaveToken, crt *certs.Certificate) error {
 	// check if there is an existing certificate in the store
 	c, err := s.getCert(domains)
@@ -256,15 +323,31 @@ func (s *DynamoDBCertStore) Save(domains []string, token interfaces.CertSaveToke
 		if err != nil {
 			return err
 		}
+
+		// a new certificate replaces a revoked one
+		c.Revoked = time.Time{}
+	}
+
+	// release the save token
+	c.SaveToken = ""
+	c.SaveTokenExpiresAt = time.Time{}
+
+	return saveError(s.putCert(c, writeIfTokenValid, string(token)))
+}
+
+func isConditionalCheckFailed(err error) bool {
+	if awsErr, isAws := err.(awserr.Error); isAws {
+		return awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException
 	}
 
-	err = s.putCert(c)
+	return false
+}
+
+func saveError(err error) error {
 	if err != nil {
-		if awsErr, isAws := err.(awserr.Error); isAws {
-			if awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
-				// conditional check should only fail on an invalid save token
-				return interfaces.ErrInvalidSavetoken
-			}
+		if isConditionalCheckFailed(err) {
+			// conditional check should only fail on an invalid save token
+			return interfaces.ErrInvalidSavetoken
 		}
 
 		return err
@@ -273,15 +356,191 @@ func (s *DynamoDBCertStore) Save(domains []string, token interfaces.CertSaveToke
 	return nil
 }
 
+// Import tries to save a certificate entry to the store, preserving
+// its metadata. It is concurrent-safe.
+func (s *DynamoDBCertStore) Import(token interfaces.CertSaveToken, entry *certs.Entry) error {
+	if entry.Certificate == nil {
+		return fmt.Errorf("certificate for %v is missing", entry.Domains)
+	}
+
+	// check if there is an existing certificate in the store
+	c, err := s.getCert(entry.Domains)
+	if err != nil {
+		return err
+	}
+
+	if c == nil {
+		// a certificate with a valid claim token should always already exist
+		return fmt.Errorf("certificate for %v has not been claimed first", entry.Domains)
+	}
+
+	now := s.time.Now()
+	if c.SaveToken != string(token) || c.SaveTokenExpiresAt.Before(now) {
+		return interfaces.ErrInvalidSavetoken
+	}
+
+	c.PrivateKey = string(entry.Certificate.PrivateKey)
+	c.Certificate = string(entry.Certificate.Certificate)
+	c.NotAfter = entry.NotAfter
+	c.Modified = entry.Modified
+	c.Revoked = entry.Revoked
+
+	if !entry.Created.IsZero() {
+		c.Created = entry.Created
+	}
+
+	// release the save token
+	c.SaveToken = ""
+	c.SaveTokenExpiresAt = time.Time{}
+
+	return saveError(s.writeCert(c, writeIfTokenValid, string(token)))
+}
+
+// List returns all certificates in the store by scanning the table.
+// The domains of every entry are returned in the order in which they were
+// saved, so that the entry is stored under the same hash in other stores.
+// Entries which cannot be read have their error set.
+func (s *DynamoDBCertStore) List() ([]*certs.Entry, error) {
+	var entries []*certs.Entry
+
+	err := s.dyndbSvc.ScanPages(&dynamodb.ScanInput{
+		TableName: aws.String(s.tableName),
+	}, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		for _, item := range page.Items {
+			entries = append(entries, listedEntry(item))
+		}
+
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// ListRevoked returns the domains of all certificates marked as revoked.
+// Only the attributes identifying revoked certificates are read, so that
+// private keys are not transferred. Certificates of which the domains
+// cannot be read are skipped, as they cannot be identified.
+func (s *DynamoDBCertStore) ListRevoked() ([][]string, error) {
+	var revoked [][]string
+
+	err := s.dyndbSvc.ScanPages(&dynamodb.ScanInput{
+		TableName:            aws.String(s.tableName),
+		ProjectionExpression: aws.String("#hash, Domains"),
+		// revocations are cleared by writing NULL
+		FilterExpression: aws.String("attribute_type(Revoked, :number)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#hash": aws.String("Hash"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":number": dyndbutil.StringAttr("N"),
+		},
+	}, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		for _, item := range page.Items {
+			if domains, err := listedDomains(item); err == nil {
+				revoked = append(revoked, domains)
+			}
+		}
+
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return revoked, nil
+}
+
+// listedEntry returns the entry of a listed item. An item which cannot be
+// parsed does not prevent listing the others: its entry only holds the
+// domains and the error.
+func listedEntry(item map[string]*dynamodb.AttributeValue) *certs.Entry {
+	c, err := parseListedItem(item)
+	if err != nil {
+		domains, _ := dyndbutil.StringListValue(item["Domains"])
+
+		return &certs.Entry{
+			Domains: domains,
+			Err:     err,
+		}
+	}
+
+	return c.entry()
+}
+
+// parseListedItem parses an item including its domains.
+func parseListedItem(item map[string]*dynamodb.AttributeValue) (*dynamoDBCert, error) {
+	domains, err := listedDomains(item)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &dynamoDBCert{
+		Domains: domains,
+	}
+
+	if err := c.parseItem(item); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// listedDomains returns the domains of a listed item, verified against the
+// hash under which the item is stored. The order of domains stored as a
+// string set is restored using the hash.
+func listedDomains(item map[string]*dynamodb.AttributeValue) ([]string, error) {
+	domains, ordered := dyndbutil.StringListValue(item["Domains"])
+	hash := dyndbutil.StringValue(item["Hash"])
+
+	if !ordered {
+		var found bool
+		if domains, found = restoreDomainsOrder(domains, hash); !found {
+			return nil, fmt.Errorf("domains %v of certificate %s are stored without order, which cannot be restored", domains, hash)
+		}
+	}
+
+	if domainsHash(domains) != hash {
+		return nil, fmt.Errorf("domains %v do not match the hash of certificate %s", domains, hash)
+	}
+
+	return domains, nil
+}
+
+func (c *dynamoDBCert) entry() *certs.Entry {
+	entry := &certs.Entry{
+		Domains:  c.Domains,
+		Created:  c.Created,
+		Modified: c.Modified,
+		NotAfter: c.NotAfter,
+		Revoked:  c.Revoked,
+	}
+
+	if c.SaveToken != "" {
+		entry.SaveTokenExpiresAt = c.SaveTokenExpiresAt
+	}
+
+	if c.PrivateKey != "" && c.Certificate != "" {
+		entry.Certificate = &certs.Certificate{
+			PrivateKey:  []byte(c.PrivateKey),
+			Certificate: []byte(c.Certificate),
+		}
+	}
+
+	return entry
+}
+
 // Load tries to load an existing certificate from the store.
-// If returns a nil certificate if it does not exists.
+// If returns a nil certificate if it does not exists or has been revoked.
 func (s *DynamoDBCertStore) Load(domains []string) (crt *certs.Certificate, err error) {
 	c, err := s.getCert(domains)
 	if err != nil {
 		return nil, err
 	}
 
-	if c == nil || c.PrivateKey == "" || c.Certificate == "" {
+	if c == nil || c.PrivateKey == "" || c.Certificate == "" || !c.Revoked.IsZero() {
 		return nil, nil
 	}
 
@@ -290,3 +549,31 @@ func (s *DynamoDBCertStore) Load(domains []string) (crt *certs.Certificate, err
 		Certificate: []byte(c.Certificate),
 	}, nil
 }
+
+// MarkRevoked marks the certificate for a list of domains as revoked.
+// It returns ErrCertNotFound if no certificate is stored for the domains.
+func (s *DynamoDBCertStore) MarkRevoked(domains []string) error {
+	_, err := s.dyndbSvc.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(s.tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"Hash": dyndbutil.StringAttr(domainsHash(domains)),
+		},
+		UpdateExpression:    aws.String("SET Revoked = :now"),
+		ConditionExpression: aws.String("attribute_exists(#hash)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#hash": aws.String("Hash"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":now": dyndbutil.TimeAttr(s.time.Now()),
+		},
+	})
+	if err != nil {
+		if isConditionalCheckFailed(err) {
+			return interfaces.ErrCertNotFound
+		}
+
+		return err
+	}
+
+	return nil
+}
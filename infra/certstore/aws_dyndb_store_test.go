@@ -0,0 +1,105 @@
+package certstore
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	commonCerts "github.com/off-sync/platform-proxy/common/certs"
+)
+
+func TestDynamoDBCertItemPreservesDomainOrder(t *testing.T) {
+	// domains in the order in which they were requested, not sorted
+	domains := []string{"www.example.com", "example.com", "api.example.com"}
+	notAfter := time.Date(2018, 4, 1, 0, 0, 0, 0, time.UTC)
+	crt := newTestCert(t, domains, notAfter)
+
+	saved := &dynamoDBCert{
+		Domains:     domains,
+		Created:     notAfter.Add(-90 * 24 * time.Hour),
+		PrivateKey:  string(crt.PrivateKey),
+		Certificate: string(crt.Certificate),
+		NotAfter:    notAfter,
+	}
+
+	listed, err := parseListedItem(saved.item())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entry := listed.entry()
+	if !reflect.DeepEqual(entry.Domains, domains) {
+		t.Fatalf("expected domains %v, got %v", domains, entry.Domains)
+	}
+
+	if domainsHash(entry.Domains) != saved.hash() {
+		t.Fatal("listed domains should produce the hash under which the certificate was saved")
+	}
+
+	if getDomainsPath(entry.Domains) != getDomainsPath(domains) {
+		t.Fatal("listed domains should produce the path of the saved domains")
+	}
+
+	leaf, err := commonCerts.ParseLeaf(entry.Certificate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(leaf.DNSNames, entry.Domains) {
+		t.Fatalf("expected listed domains to match certificate SANs %v, got %v", leaf.DNSNames, entry.Domains)
+	}
+}
+
+func TestDynamoDBCertItemWithStringSet(t *testing.T) {
+	// grouped domains are sorted, which is the order string sets are read in
+	domains := []string{"api.example.com", "example.com", "www.example.com"}
+
+	item := (&dynamoDBCert{Domains: domains}).item()
+	item["Domains"] = &dynamodb.AttributeValue{
+		SS: aws.StringSlice([]string{"www.example.com", "api.example.com", "example.com"}),
+	}
+
+	listed, err := parseListedItem(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(listed.Domains, domains) {
+		t.Fatalf("expected domains %v, got %v", domains, listed.Domains)
+	}
+
+	// unsorted domains are restored using the hash
+	domains = []string{"www.example.com", "example.com", "api.example.com"}
+
+	item = (&dynamoDBCert{Domains: domains}).item()
+	item["Domains"] = &dynamodb.AttributeValue{
+		SS: aws.StringSlice([]string{"api.example.com", "example.com", "www.example.com"}),
+	}
+
+	listed, err = parseListedItem(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(listed.Domains, domains) {
+		t.Fatalf("expected domains %v, got %v", domains, listed.Domains)
+	}
+}
+
+func TestDynamoDBListedEntryWithMismatchingHash(t *testing.T) {
+	item := (&dynamoDBCert{Domains: []string{"www.example.com", "example.com"}}).item()
+	item["Domains"] = &dynamodb.AttributeValue{
+		SS: aws.StringSlice([]string{"www.example.com", "shop.example.com"}),
+	}
+
+	entry := listedEntry(item)
+	if entry.Err == nil {
+		t.Fatal("expected error for domains not matching the hash")
+	}
+
+	if len(entry.Domains) != 2 || entry.Certificate != nil {
+		t.Fatalf("expected only the domains to be set, got %+v", entry)
+	}
+}
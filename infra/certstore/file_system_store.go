@@ -1,11 +1,14 @@
 package certstore
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/off-sync/platform-proxy/app/interfaces"
+	commonCerts "github.com/off-sync/platform-proxy/common/certs"
 	"github.com/off-sync/platform-proxy/domain/certs"
 	"github.com/off-sync/platform-proxy/infra/filesystem"
 )
@@ -13,21 +16,33 @@ import (
 // FileSystemCertStore implements filesystem based storage for certificates.
 type FileSystemCertStore struct {
 	sync.Mutex
-	fs filesystem.FileSystem
+	fs   filesystem.FileSystem
+	time interfaces.Time
 }
 
 // NewFileSystemCertStore creates a new filesystem-backed certificate store.
-func NewFileSystemCertStore(fs filesystem.FileSystem) *FileSystemCertStore {
+func NewFileSystemCertStore(fs filesystem.FileSystem, time interfaces.Time) *FileSystemCertStore {
 	return &FileSystemCertStore{
-		fs: fs,
+		fs:   fs,
+		time: time,
 	}
 }
 
 const (
 	certSuffix = "-crt.pem"
 	keySuffix  = "-key.pem"
+	metaSuffix = "-meta.json"
 )
 
+// fileSystemCertMeta holds the metadata of a certificate which is
+// stored next to the certificate and private key.
+type fileSystemCertMeta struct {
+	Domains  []string
+	Created  time.Time
+	Modified time.Time
+	Revoked  time.Time
+}
+
 func getDomainsPath(domains []string) string {
 	escaped := make([]string, len(domains))
 
@@ -47,14 +62,46 @@ func getDomainPath(domain string) string {
 	return strings.Join(parts, "_")
 }
 
+// getPathDomains reverses getDomainsPath. It is ambiguous for domains
+// containing '_' or '+', so it is only used for certificates without
+// metadata, which holds the domains as saved.
+func getPathDomains(path string) []string {
+	escaped := strings.Split(path, "+")
+
+	domains := make([]string, len(escaped))
+	for i, e := range escaped {
+		parts := strings.Split(e, "_")
+		for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+			parts[i], parts[j] = parts[j], parts[i]
+		}
+
+		domains[i] = strings.Join(parts, ".")
+	}
+
+	return domains
+}
+
 // Load tries to retrieve a certificate for a domain.
-// Returns a nil certificate if it does not exist.
+// Returns a nil certificate if it does not exist or has been revoked.
 func (s *FileSystemCertStore) Load(domains []string) (*certs.Certificate, error) {
 	s.Lock()
 	defer s.Unlock()
 
 	path := getDomainsPath(domains)
 
+	meta, err := s.loadMeta(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if meta != nil && !meta.Revoked.IsZero() {
+		return nil, nil
+	}
+
+	return s.load(path)
+}
+
+func (s *FileSystemCertStore) load(path string) (*certs.Certificate, error) {
 	certPath := path + certSuffix
 	exists, err := s.fs.FileExists(certPath)
 	if err != nil {
@@ -86,6 +133,43 @@ func (s *FileSystemCertStore) Load(domains []string) (*certs.Certificate, error)
 	}, nil
 }
 
+// loadMeta reads the metadata of a certificate. It returns nil if
+// no metadata has been stored, which is the case for certificates
+// saved by earlier versions of this store.
+func (s *FileSystemCertStore) loadMeta(path string) (*fileSystemCertMeta, error) {
+	metaPath := path + metaSuffix
+	exists, err := s.fs.FileExists(metaPath)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	metaBytes, err := s.fs.ReadBytes(metaPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading metadata from path '%s': %s", metaPath, err)
+	}
+
+	meta := &fileSystemCertMeta{}
+	if err := json.Unmarshal(metaBytes, meta); err != nil {
+		return nil, fmt.Errorf("decoding metadata from path '%s': %s", metaPath, err)
+	}
+
+	return meta, nil
+}
+
+func (s *FileSystemCertStore) saveMeta(path string, meta *fileSystemCertMeta) error {
+	metaBytes, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+
+	metaPath := path + metaSuffix
+	if err := s.fs.WriteBytes(metaPath, metaBytes); err != nil {
+		return fmt.Errorf("writing metadata to path '%s': %s", metaPath, err)
+	}
+
+	return nil
+}
+
 // ClaimSaveToken returns a token that can be used for saving a certificate.
 func (s *FileSystemCertStore) ClaimSaveToken(domains []string) (interfaces.CertSaveToken, error) {
 	return "", nil
@@ -99,6 +183,26 @@ func (s *FileSystemCertStore) Save(domains []string, token interfaces.CertSaveTo
 
 	path := getDomainsPath(domains)
 
+	meta, err := s.loadMeta(path)
+	if err != nil {
+		return err
+	}
+
+	now := s.time.Now()
+	if meta == nil {
+		meta = &fileSystemCertMeta{
+			Domains: domains,
+			Created: now,
+		}
+	} else {
+		meta.Modified = now
+		meta.Revoked = time.Time{}
+	}
+
+	return s.save(path, crt, meta)
+}
+
+func (s *FileSystemCertStore) save(path string, crt *certs.Certificate, meta *fileSystemCertMeta) error {
 	certPath := path + certSuffix
 	if err := s.fs.WriteBytes(certPath, crt.Certificate); err != nil {
 		return fmt.Errorf("writing certificate to path '%s': %s", certPath, err)
@@ -109,5 +213,145 @@ func (s *FileSystemCertStore) Save(domains []string, token interfaces.CertSaveTo
 		return fmt.Errorf("writing private key to path '%s': %s", keyPath, err)
 	}
 
-	return nil
+	return s.saveMeta(path, meta)
+}
+
+// Import stores a certificate entry, preserving its metadata.
+// Any token can be provided as this store is not concurrent-safe.
+func (s *FileSystemCertStore) Import(token interfaces.CertSaveToken, entry *certs.Entry) error {
+	if entry.Certificate == nil {
+		return fmt.Errorf("certificate for %v is missing", entry.Domains)
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	return s.save(getDomainsPath(entry.Domains), entry.Certificate, &fileSystemCertMeta{
+		Domains:  entry.Domains,
+		Created:  entry.Created,
+		Modified: entry.Modified,
+		Revoked:  entry.Revoked,
+	})
+}
+
+// List returns all certificates in the store.
+func (s *FileSystemCertStore) List() ([]*certs.Entry, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	files, err := s.fs.ListFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*certs.Entry
+	for _, file := range files {
+		if !strings.HasSuffix(file, certSuffix) {
+			continue
+		}
+
+		path := strings.TrimSuffix(file, certSuffix)
+
+		crt, err := s.load(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if crt == nil {
+			// private key is missing
+			continue
+		}
+
+		meta, err := s.loadMeta(path)
+		if err != nil {
+			return nil, err
+		}
+
+		entry := &certs.Entry{
+			Certificate: crt,
+		}
+
+		if meta != nil && len(meta.Domains) > 0 {
+			entry.Domains = meta.Domains
+			entry.Created = meta.Created
+			entry.Modified = meta.Modified
+			entry.Revoked = meta.Revoked
+		} else {
+			// saved by an earlier version without metadata: parse the path
+			entry.Domains = getPathDomains(path)
+		}
+
+		entry.NotAfter, err = commonCerts.NotAfter(crt)
+		if err != nil {
+			return nil, fmt.Errorf("reading expiry date of '%s': %s", file, err)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// ListRevoked returns the domains of all certificates marked as revoked.
+// Only the metadata is read, as certificates without metadata cannot have
+// been revoked.
+func (s *FileSystemCertStore) ListRevoked() ([][]string, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	files, err := s.fs.ListFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	var revoked [][]string
+	for _, file := range files {
+		if !strings.HasSuffix(file, certSuffix) {
+			continue
+		}
+
+		meta, err := s.loadMeta(strings.TrimSuffix(file, certSuffix))
+		if err != nil {
+			return nil, err
+		}
+
+		if meta != nil && !meta.Revoked.IsZero() {
+			revoked = append(revoked, meta.Domains)
+		}
+	}
+
+	return revoked, nil
+}
+
+// MarkRevoked marks the certificate for a list of domains as revoked.
+// It returns ErrCertNotFound if no certificate is stored for the domains.
+func (s *FileSystemCertStore) MarkRevoked(domains []string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	path := getDomainsPath(domains)
+
+	exists, err := s.fs.FileExists(path + certSuffix)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return interfaces.ErrCertNotFound
+	}
+
+	meta, err := s.loadMeta(path)
+	if err != nil {
+		return err
+	}
+
+	if meta == nil {
+		meta = &fileSystemCertMeta{
+			Domains: domains,
+		}
+	}
+
+	meta.Revoked = s.time.Now()
+
+	return s.saveMeta(path, meta)
 }
@@ -0,0 +1,97 @@
+package certstore
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/off-sync/platform-proxy/infra/filesystem"
+)
+
+func newFileSystemCertStore(t *testing.T) (*FileSystemCertStore, filesystem.FileSystem) {
+	fs, err := filesystem.NewLocalFileSystem(filesystem.Root(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock := &fakeTime{now: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	return NewFileSystemCertStore(fs, clock), fs
+}
+
+func TestFileSystemCertStoreListUsesMetadata(t *testing.T) {
+	s, _ := newFileSystemCertStore(t)
+
+	// '_' is ambiguous in paths, so the domains must be read from the metadata
+	domains := []string{"my_host.example.com", "example.com"}
+	crt := newTestCert(t, domains, time.Date(2018, 4, 1, 0, 0, 0, 0, time.UTC))
+
+	if err := s.Save(domains, "", crt); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(entries))
+	}
+
+	if !reflect.DeepEqual(entries[0].Domains, domains) {
+		t.Fatalf("expected domains %v, got %v", domains, entries[0].Domains)
+	}
+}
+
+func TestFileSystemCertStoreListWithoutMetadata(t *testing.T) {
+	s, fs := newFileSystemCertStore(t)
+
+	domains := []string{"example.com", "www.example.com"}
+	crt := newTestCert(t, domains, time.Date(2018, 4, 1, 0, 0, 0, 0, time.UTC))
+
+	// certificates saved by earlier versions have no metadata
+	path := getDomainsPath(domains)
+	if err := fs.WriteBytes(path+certSuffix, crt.Certificate); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fs.WriteBytes(path+keySuffix, crt.PrivateKey); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 || !reflect.DeepEqual(entries[0].Domains, domains) {
+		t.Fatalf("expected domains %v parsed from the path, got %v", domains, entries)
+	}
+}
+
+func TestFileSystemCertStoreListRevoked(t *testing.T) {
+	s, _ := newFileSystemCertStore(t)
+
+	revoked := []string{"my_host.example.com", "example.com"}
+	valid := []string{"shop.example.com"}
+
+	for _, domains := range [][]string{revoked, valid} {
+		if err := s.Save(domains, "", newTestCert(t, domains, time.Date(2018, 4, 1, 0, 0, 0, 0, time.UTC))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.MarkRevoked(revoked); err != nil {
+		t.Fatal(err)
+	}
+
+	listed, err := s.ListRevoked()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(listed, [][]string{revoked}) {
+		t.Fatalf("expected revoked %v, got %v", [][]string{revoked}, listed)
+	}
+}
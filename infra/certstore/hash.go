@@ -0,0 +1,61 @@
+package certstore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+)
+
+// domainsHash returns the key under which a certificate for the provided
+// domains is stored.
+func domainsHash(domains []string) string {
+	hash := sha256.Sum256([]byte(strings.Join(domains, ",")))
+	return hex.EncodeToString(hash[:])
+}
+
+// maxUnorderedDomains limits the number of domains for which every order is
+// tried to restore the order of domains stored without one.
+const maxUnorderedDomains = 8
+
+// restoreDomainsOrder returns the order of the domains matching the hash,
+// trying the sorted order before all others. It returns false if no order
+// matches or if there are too many domains to try them all.
+func restoreDomainsOrder(domains []string, hash string) ([]string, bool) {
+	ordered := make([]string, len(domains))
+	copy(ordered, domains)
+	sort.Strings(ordered)
+
+	if domainsHash(ordered) == hash {
+		return ordered, true
+	}
+
+	if len(ordered) > maxUnorderedDomains {
+		return domains, false
+	}
+
+	// Heap's algorithm generates every order by swapping two domains
+	c := make([]int, len(ordered))
+	for i := 1; i < len(ordered); {
+		if c[i] >= i {
+			c[i] = 0
+			i++
+			continue
+		}
+
+		if i%2 == 0 {
+			ordered[0], ordered[i] = ordered[i], ordered[0]
+		} else {
+			ordered[c[i]], ordered[i] = ordered[i], ordered[c[i]]
+		}
+
+		if domainsHash(ordered) == hash {
+			return ordered, true
+		}
+
+		c[i]++
+		i = 1
+	}
+
+	return domains, false
+}
@@ -0,0 +1,354 @@
+package certstore
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/off-sync/platform-proxy/app/interfaces"
+	commonCerts "github.com/off-sync/platform-proxy/common/certs"
+	"github.com/off-sync/platform-proxy/common/sqlutil"
+	"github.com/off-sync/platform-proxy/domain/certs"
+	uuid "github.com/satori/go.uuid"
+)
+
+// SQLCertStore is a certificate store implementation using a database/sql
+// database. SQLite and PostgreSQL are supported.
+// It is concurrent-safe: save tokens are claimed and checked using
+// conditional updates on the certificate's row.
+type SQLCertStore struct {
+	db        *sql.DB
+	dialect   sqlutil.Dialect
+	tableName string
+	time      interfaces.Time
+}
+
+var sqlCertMigrations = []sqlutil.Migration{
+	{
+		Version: 1,
+		Statements: []string{
+			`CREATE TABLE {table} (
+	hash VARCHAR(64) NOT NULL PRIMARY KEY,
+	domains TEXT NOT NULL,
+	save_token VARCHAR(64) NOT NULL DEFAULT '',
+	save_token_expires_at BIGINT NOT NULL DEFAULT 0,
+	created BIGINT NOT NULL DEFAULT 0,
+	modified BIGINT NOT NULL DEFAULT 0,
+	private_key TEXT NOT NULL DEFAULT '',
+	certificate TEXT NOT NULL DEFAULT '',
+	not_after BIGINT NOT NULL DEFAULT 0
+)`,
+			`CREATE INDEX {table}_not_after ON {table} (not_after)`,
+		},
+	},
+	{
+		Version: 2,
+		Statements: []string{
+			`ALTER TABLE {table} ADD COLUMN revoked BIGINT NOT NULL DEFAULT 0`,
+		},
+	},
+}
+
+// NewSQLCertStore creates a new SQL certificate store using the provided
+// database and dialect. It creates or migrates the table before returning.
+func NewSQLCertStore(db *sql.DB, dialect sqlutil.Dialect, tableName string, time interfaces.Time) (*SQLCertStore, error) {
+	if !sqlutil.ValidTableName(tableName) {
+		return nil, fmt.Errorf("invalid table name: %s", tableName)
+	}
+
+	if err := sqlutil.Migrate(db, dialect, tableName, sqlCertMigrations); err != nil {
+		return nil, err
+	}
+
+	return &SQLCertStore{
+		db:        db,
+		dialect:   dialect,
+		tableName: tableName,
+		time:      time,
+	}, nil
+}
+
+func (s *SQLCertStore) query(query string) string {
+	return sqlutil.Query(s.dialect, s.tableName, query)
+}
+
+func (s *SQLCertStore) exists(hash string) (bool, error) {
+	var n int
+	err := s.db.QueryRow(s.query("SELECT COUNT(*) FROM {table} WHERE hash = ?"), hash).Scan(&n)
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// ClaimSaveToken tries to claim a save token in the store.
+// ErrTokenAlreadyClaimed is returned if a non-expired token is already present.
+func (s *SQLCertStore) ClaimSaveToken(domains []string) (interfaces.CertSaveToken, error) {
+	hash := domainsHash(domains)
+
+	now := s.time.Now()
+
+	// create new save token and set expiry to 15 minutes from now
+	token := uuid.NewV4().String()
+	expiresAt := sqlutil.TimeValue(now.Add(15 * time.Minute))
+
+	// claim the token on an existing row if no other token is active,
+	// moving NotAfter forward in the same way as the DynamoDB store
+	res, err := s.db.Exec(s.query(`UPDATE {table}
+SET save_token = ?, save_token_expires_at = ?,
+	not_after = CASE WHEN not_after < ? THEN ? ELSE not_after END
+WHERE hash = ? AND (save_token = '' OR save_token_expires_at <= ?)`),
+		token, expiresAt,
+		expiresAt, expiresAt,
+		hash, sqlutil.TimeValue(now))
+	if err != nil {
+		return "", err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return "", err
+	}
+
+	if n > 0 {
+		return interfaces.CertSaveToken(token), nil
+	}
+
+	exists, err := s.exists(hash)
+	if err != nil {
+		return "", err
+	}
+
+	if exists {
+		// non-expired save token present
+		return "", interfaces.ErrTokenAlreadyClaimed
+	}
+
+	// certificate does not exist yet: create a new row holding the token
+	_, err = s.db.Exec(s.query(`INSERT INTO {table}
+(hash, domains, save_token, save_token_expires_at, created, not_after)
+VALUES (?, ?, ?, ?, ?, ?)`),
+		hash, strings.Join(domains, ","), token, expiresAt, sqlutil.TimeValue(now), expiresAt)
+	if err != nil {
+		if exists, existsErr := s.exists(hash); existsErr == nil && exists {
+			// another process inserted the row first
+			return "", interfaces.ErrTokenAlreadyClaimed
+		}
+
+		return "", err
+	}
+
+	return interfaces.CertSaveToken(token), nil
+}
+
+// Save tries to save a certificate to the store. It is concurrent-safe.
+// The save token is released once the certificate has been saved.
+func (s *SQLCertStore) Save(domains []string, token interfaces.CertSaveToken, crt *certs.Certificate) error {
+	hash := domainsHash(domains)
+
+	now := sqlutil.TimeValue(s.time.Now())
+
+	var res sql.Result
+	var err error
+
+	if crt.PrivateKey != nil && crt.Certificate != nil {
+		// get expiry date from certificate
+		var notAfter time.Time
+		notAfter, err = commonCerts.NotAfter(crt)
+		if err != nil {
+			return err
+		}
+
+		res, err = s.db.Exec(s.query(`UPDATE {table}
+SET save_token = '', save_token_expires_at = 0, modified = ?,
+	private_key = ?, certificate = ?, not_after = ?, revoked = 0
+WHERE hash = ? AND save_token = ? AND save_token_expires_at > ?`),
+			now,
+			string(crt.PrivateKey), string(crt.Certificate), sqlutil.TimeValue(notAfter),
+			hash, string(token), now)
+		if err != nil {
+			return err
+		}
+	} else {
+		res, err = s.db.Exec(s.query(`UPDATE {table}
+SET save_token = '', save_token_expires_at = 0, modified = ?
+WHERE hash = ? AND save_token = ? AND save_token_expires_at > ?`),
+			now,
+			hash, string(token), now)
+		if err != nil {
+			return err
+		}
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n > 0 {
+		return nil
+	}
+
+	exists, err := s.exists(hash)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		// a certificate with a valid claim token should always already exist
+		return fmt.Errorf("certificate for %v has not been claimed first", domains)
+	}
+
+	// conditional update should only fail on an invalid save token
+	return interfaces.ErrInvalidSavetoken
+}
+
+// Load tries to load an existing certificate from the store.
+// If returns a nil certificate if it does not exists or has been revoked.
+func (s *SQLCertStore) Load(domains []string) (*certs.Certificate, error) {
+	var privateKey, certificate string
+	var revoked int64
+
+	err := s.db.QueryRow(
+		s.query("SELECT private_key, certificate, revoked FROM {table} WHERE hash = ?"),
+		domainsHash(domains)).Scan(&privateKey, &certificate, &revoked)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if privateKey == "" || certificate == "" || revoked != 0 {
+		return nil, nil
+	}
+
+	return &certs.Certificate{
+		PrivateKey:  []byte(privateKey),
+		Certificate: []byte(certificate),
+	}, nil
+}
+
+// Import tries to save a certificate entry to the store, preserving
+// its metadata. It is concurrent-safe.
+// The save token is released once the entry has been saved.
+func (s *SQLCertStore) Import(token interfaces.CertSaveToken, entry *certs.Entry) error {
+	if entry.Certificate == nil {
+		return fmt.Errorf("certificate for %v is missing", entry.Domains)
+	}
+
+	hash := domainsHash(entry.Domains)
+
+	now := sqlutil.TimeValue(s.time.Now())
+
+	res, err := s.db.Exec(s.query(`UPDATE {table}
+SET save_token = '', save_token_expires_at = 0,
+	created = CASE WHEN ? = 0 THEN created ELSE ? END, modified = ?,
+	private_key = ?, certificate = ?, not_after = ?, revoked = ?
+WHERE hash = ? AND save_token = ? AND save_token_expires_at > ?`),
+		sqlutil.TimeValue(entry.Created), sqlutil.TimeValue(entry.Created), sqlutil.TimeValue(entry.Modified),
+		string(entry.Certificate.PrivateKey), string(entry.Certificate.Certificate), sqlutil.TimeValue(entry.NotAfter),
+		sqlutil.TimeValue(entry.Revoked),
+		hash, string(token), now)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n < 1 {
+		return interfaces.ErrInvalidSavetoken
+	}
+
+	return nil
+}
+
+// List returns all certificates in the store.
+func (s *SQLCertStore) List() ([]*certs.Entry, error) {
+	rows, err := s.db.Query(s.query(`SELECT domains, save_token_expires_at, created, modified, not_after, revoked, private_key, certificate
+FROM {table} ORDER BY domains`))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*certs.Entry
+	for rows.Next() {
+		var domains, privateKey, certificate string
+		var saveTokenExpiresAt, created, modified, notAfter, revoked int64
+
+		err := rows.Scan(&domains, &saveTokenExpiresAt, &created, &modified, &notAfter, &revoked, &privateKey, &certificate)
+		if err != nil {
+			return nil, err
+		}
+
+		entry := &certs.Entry{
+			Domains:            strings.Split(domains, ","),
+			SaveTokenExpiresAt: sqlutil.ParseTime(saveTokenExpiresAt),
+			Created:            sqlutil.ParseTime(created),
+			Modified:           sqlutil.ParseTime(modified),
+			NotAfter:           sqlutil.ParseTime(notAfter),
+			Revoked:            sqlutil.ParseTime(revoked),
+		}
+
+		if privateKey != "" && certificate != "" {
+			entry.Certificate = &certs.Certificate{
+				PrivateKey:  []byte(privateKey),
+				Certificate: []byte(certificate),
+			}
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// ListRevoked returns the domains of all certificates marked as revoked.
+func (s *SQLCertStore) ListRevoked() ([][]string, error) {
+	rows, err := s.db.Query(s.query(`SELECT domains FROM {table} WHERE revoked <> 0 ORDER BY domains`))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revoked [][]string
+	for rows.Next() {
+		var domains string
+		if err := rows.Scan(&domains); err != nil {
+			return nil, err
+		}
+
+		revoked = append(revoked, strings.Split(domains, ","))
+	}
+
+	return revoked, rows.Err()
+}
+
+// MarkRevoked marks the certificate for a list of domains as revoked.
+// It returns ErrCertNotFound if no certificate is stored for the domains.
+func (s *SQLCertStore) MarkRevoked(domains []string) error {
+	res, err := s.db.Exec(
+		s.query("UPDATE {table} SET revoked = ? WHERE hash = ?"),
+		sqlutil.TimeValue(s.time.Now()), domainsHash(domains))
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n < 1 {
+		return interfaces.ErrCertNotFound
+	}
+
+	return nil
+}
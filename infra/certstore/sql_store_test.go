@@ -0,0 +1,202 @@
+package certstore
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"database/sql"
+	"encoding/pem"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/off-sync/platform-proxy/app/interfaces"
+	"github.com/off-sync/platform-proxy/common/sqlutil"
+	"github.com/off-sync/platform-proxy/domain/certs"
+
+	_ "github.com/mattn/go-sqlite3" // SQLite driver
+)
+
+type fakeTime struct {
+	now time.Time
+}
+
+func (t *fakeTime) Now() time.Time { return t.now }
+
+func openSQLite(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every connection to :memory: opens a new, empty database
+	db.SetMaxOpenConns(1)
+
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func newSQLCertStore(t *testing.T, db *sql.DB, clock *fakeTime) *SQLCertStore {
+	s, err := NewSQLCertStore(db, sqlutil.SQLite, "certs", clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+// newTestCert creates a self-signed certificate for the domains,
+// valid until notAfter.
+func newTestCert(t *testing.T, domains []string, notAfter time.Time) *certs.Certificate {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: domains[0]},
+		DNSNames:     domains,
+		NotBefore:    notAfter.Add(-90 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &certs.Certificate{
+		PrivateKey:  pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+		Certificate: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+func TestSQLCertStoreMigrationsAreIdempotent(t *testing.T) {
+	db := openSQLite(t)
+	clock := &fakeTime{now: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	newSQLCertStore(t, db, clock)
+	s := newSQLCertStore(t, db, clock)
+
+	var n int
+	err := db.QueryRow("SELECT COUNT(*) FROM schema_migrations WHERE table_name = 'certs'").Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != len(sqlCertMigrations) {
+		t.Fatalf("expected %d recorded migrations, got %d", len(sqlCertMigrations), n)
+	}
+
+	// the columns added by later migrations must be usable
+	domains := []string{"example.com"}
+	if _, err := s.ClaimSaveToken(domains); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.MarkRevoked(domains); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSQLCertStoreClaimSaveTokenConflicts(t *testing.T) {
+	clock := &fakeTime{now: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)}
+	s := newSQLCertStore(t, openSQLite(t), clock)
+
+	domains := []string{"example.com", "www.example.com"}
+
+	if _, err := s.ClaimSaveToken(domains); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.ClaimSaveToken(domains); err != interfaces.ErrTokenAlreadyClaimed {
+		t.Fatalf("expected ErrTokenAlreadyClaimed while the first token is valid, got %v", err)
+	}
+
+	clock.now = clock.now.Add(16 * time.Minute)
+
+	if _, err := s.ClaimSaveToken(domains); err != nil {
+		t.Fatalf("expected claim to succeed after the first token expired, got %v", err)
+	}
+}
+
+func TestSQLCertStoreSaveRejectsInvalidToken(t *testing.T) {
+	clock := &fakeTime{now: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)}
+	s := newSQLCertStore(t, openSQLite(t), clock)
+
+	domains := []string{"example.com"}
+	crt := newTestCert(t, domains, clock.now.Add(90*24*time.Hour))
+
+	token, err := s.ClaimSaveToken(domains)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Save(domains, "wrong-token", crt); err != interfaces.ErrInvalidSavetoken {
+		t.Fatalf("expected ErrInvalidSavetoken for a wrong token, got %v", err)
+	}
+
+	clock.now = clock.now.Add(16 * time.Minute)
+
+	if err := s.Save(domains, token, crt); err != interfaces.ErrInvalidSavetoken {
+		t.Fatalf("expected ErrInvalidSavetoken for an expired token, got %v", err)
+	}
+
+	if err := s.Save([]string{"other.example.com"}, token, crt); err == nil {
+		t.Fatal("expected error when saving unclaimed domains")
+	}
+
+	token, err = s.ClaimSaveToken(domains)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Save(domains, token, crt); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := s.Load(domains)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded == nil || string(loaded.Certificate) != string(crt.Certificate) {
+		t.Fatal("expected saved certificate to be loaded")
+	}
+}
+
+func TestSQLCertStoreListRevoked(t *testing.T) {
+	clock := &fakeTime{now: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)}
+	s := newSQLCertStore(t, openSQLite(t), clock)
+
+	revoked := []string{"example.com", "www.example.com"}
+	valid := []string{"shop.example.com"}
+
+	for _, domains := range [][]string{revoked, valid} {
+		token, err := s.ClaimSaveToken(domains)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.Save(domains, token, newTestCert(t, domains, clock.now.Add(90*24*time.Hour))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.MarkRevoked(revoked); err != nil {
+		t.Fatal(err)
+	}
+
+	listed, err := s.ListRevoked()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(listed, [][]string{revoked}) {
+		t.Fatalf("expected revoked %v, got %v", [][]string{revoked}, listed)
+	}
+}
@@ -0,0 +1,534 @@
+package fileconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/off-sync/platform-proxy/common/sitesjson"
+	"github.com/off-sync/platform-proxy/domain/sites"
+)
+
+// DefaultPollInterval is the interval on which the file is checked for
+// changes if no interval has been set.
+const DefaultPollInterval = 30 * time.Second
+
+// ConfigProvider provides a ConfigProvider implementation based on a JSON
+// file. The file is read each time the configuration is requested.
+type ConfigProvider struct {
+	sync.Mutex
+	notificationChans []chan bool
+	path              string
+	pollInterval      time.Duration
+	watching          sync.Once
+}
+
+// Option configures the file Configuration Provider.
+type Option func(*ConfigProvider)
+
+// PollInterval sets the interval on which the modification time of the
+// file is checked. A change is sent to the notification channels. The
+// file is not checked if the interval is 0.
+func PollInterval(interval time.Duration) Option {
+	return func(p *ConfigProvider) {
+		p.pollInterval = interval
+	}
+}
+
+type fileConfig struct {
+	Backends  []*fileBackend  `json:"backends"`
+	Frontends []*fileFrontend `json:"frontends"`
+}
+
+type fileBackend struct {
+	Name        string           `json:"name"`
+	Servers     []*fileServer    `json:"servers"`
+	Algorithm   string           `json:"algorithm"`
+	HashKey     string           `json:"hash_key"`
+	HealthCheck *fileHealthCheck `json:"health_check"`
+
+	Transport *fileTransport `json:"transport"`
+
+	TLS *fileBackendTLS `json:"tls"`
+
+	CircuitBreaker *fileCircuitBreaker `json:"circuit_breaker"`
+
+	Retry *fileRetry `json:"retry"`
+
+	StickySession *fileStickySession `json:"sticky_session"`
+}
+
+type fileBackendTLS struct {
+	CAFile             string `json:"ca_file"`
+	ServerName         string `json:"server_name"`
+	CertFile           string `json:"cert_file"`
+	KeyFile            string `json:"key_file"`
+	LocalCAClientCert  bool   `json:"local_ca_client_cert"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+}
+
+type fileTransport struct {
+	// DialTimeout, TLSHandshakeTimeout, ResponseHeaderTimeout, KeepAlive
+	// and IdleConnTimeout hold durations, such as '10s'.
+	DialTimeout           string `json:"dial_timeout"`
+	TLSHandshakeTimeout   string `json:"tls_handshake_timeout"`
+	ResponseHeaderTimeout string `json:"response_header_timeout"`
+	KeepAlive             string `json:"keep_alive"`
+	IdleConnTimeout       string `json:"idle_conn_timeout"`
+	MaxIdleConnsPerHost   int    `json:"max_idle_conns_per_host"`
+	DisableHTTP2          bool   `json:"disable_http2"`
+}
+
+type fileStickySession struct {
+	CookieName string `json:"cookie_name"`
+	// TTL holds a duration, such as '24h'.
+	TTL      string `json:"ttl"`
+	Secure   bool   `json:"secure"`
+	HTTPOnly bool   `json:"http_only"`
+}
+
+type fileRetry struct {
+	Attempts     int     `json:"attempts"`
+	Statuses     []int   `json:"statuses"`
+	Budget       float64 `json:"budget"`
+	MaxBodyBytes int64   `json:"max_body_bytes"`
+}
+
+type fileCircuitBreaker struct {
+	Errors int `json:"errors"`
+	// EjectDuration holds a duration, such as '30s'.
+	EjectDuration       string `json:"eject_duration"`
+	HalfOpenRequests    int    `json:"half_open_requests"`
+	FallbackStatus      int    `json:"fallback_status"`
+	FallbackBody        string `json:"fallback_body"`
+	FallbackContentType string `json:"fallback_content_type"`
+}
+
+type fileSplit struct {
+	Name   string `json:"name"`
+	Weight int    `json:"weight"`
+}
+
+// fileServer is either a URL, or an object with a URL and a weight.
+type fileServer struct {
+	URL    string `json:"url"`
+	Weight int    `json:"weight"`
+}
+
+// UnmarshalJSON decodes a server defined by its URL only, or by an object.
+func (s *fileServer) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &s.URL)
+	}
+
+	type server fileServer
+
+	return json.Unmarshal(data, (*server)(s))
+}
+
+type fileHealthCheck struct {
+	Path   string `json:"path"`
+	Status int    `json:"status"`
+	// Interval and Timeout hold durations, such as '500ms' or '10s'.
+	Interval string `json:"interval"`
+	Timeout  string `json:"timeout"`
+	Rise     int    `json:"rise"`
+	Fall     int    `json:"fall"`
+}
+
+type fileFrontend struct {
+	Domain      string       `json:"domain"`
+	Backend     string       `json:"backend"`
+	CertGroup   string       `json:"cert_group"`
+	PathPrefix  string       `json:"path_prefix"`
+	PathRegex   string       `json:"path_regex"`
+	StripPrefix bool         `json:"strip_prefix"`
+	Priority    int          `json:"priority"`
+	HSTS        *fileHSTS    `json:"hsts"`
+	Upgrade     *fileUpgrade `json:"upgrade"`
+
+	Rules []*sitesjson.Rule `json:"rules"`
+
+	Backends      []*fileSplit `json:"backends"`
+	SplitOverride string       `json:"split_override"`
+
+	Mirror *fileMirror `json:"mirror"`
+
+	ClientAuth *fileClientAuth `json:"client_auth"`
+
+	Passthrough bool `json:"passthrough"`
+	TCPPort     int  `json:"tcp_port"`
+
+	// ProxyProtocol holds the version of the PROXY protocol header sent
+	// to the backend of a layer 4 frontend: v1 or v2.
+	ProxyProtocol string `json:"proxy_protocol"`
+}
+
+type fileMirror struct {
+	Backend      string  `json:"backend"`
+	Percentage   float64 `json:"percentage"`
+	MaxBodyBytes int64   `json:"max_body_bytes"`
+}
+
+type fileClientAuth struct {
+	CAFile string `json:"ca_file"`
+	// Mode holds whether a certificate is required or optional.
+	Mode            string   `json:"mode"`
+	AllowedPatterns []string `json:"allowed_patterns"`
+}
+
+type fileHSTS struct {
+	// MaxAge holds the maximum age in seconds.
+	MaxAge            int  `json:"max_age"`
+	IncludeSubDomains bool `json:"include_subdomains"`
+	Preload           bool `json:"preload"`
+}
+
+type fileUpgrade struct {
+	// IdleTimeout holds a duration, such as '30s' or '5m'.
+	IdleTimeout string `json:"idle_timeout"`
+}
+
+// New returns a new file Configuration Provider. It reads the file
+// before returning to check its validity.
+func New(path string, options ...Option) (*ConfigProvider, error) {
+	p := &ConfigProvider{
+		path:         path,
+		pollInterval: DefaultPollInterval,
+	}
+
+	for _, o := range options {
+		o(p)
+	}
+
+	if _, err := p.read(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (p *ConfigProvider) read() (*fileConfig, error) {
+	data, err := ioutil.ReadFile(p.path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &fileConfig{}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("decoding configuration file '%s': %s", p.path, err)
+	}
+
+	return cfg, nil
+}
+
+// GetNotificationChannel creates a new channel to which configuration updates are sent.
+// Updates are sent when the modification time of the file changes.
+func (p *ConfigProvider) GetNotificationChannel() <-chan bool {
+	c := make(chan bool, 1)
+
+	p.Lock()
+	p.notificationChans = append(p.notificationChans, c)
+	p.Unlock()
+
+	if p.pollInterval > 0 {
+		p.watching.Do(func() {
+			go p.watch(p.modTime())
+		})
+	}
+
+	return c
+}
+
+// watch notifies the channels when the modification time of the file
+// changes from modTime.
+func (p *ConfigProvider) watch(modTime time.Time) {
+	for range time.Tick(p.pollInterval) {
+		if t := p.modTime(); !t.Equal(modTime) {
+			modTime = t
+			p.notify()
+		}
+	}
+}
+
+// modTime returns the modification time of the file, or the zero time if
+// it cannot be read.
+func (p *ConfigProvider) modTime() time.Time {
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return info.ModTime()
+}
+
+// notify sends an update to the notification channels. Channels already
+// holding an update are skipped.
+func (p *ConfigProvider) notify() {
+	p.Lock()
+	defer p.Unlock()
+
+	for _, c := range p.notificationChans {
+		select {
+		case c <- true:
+		default:
+		}
+	}
+}
+
+// GetBackends returns the backends defined in the configuration file.
+func (p *ConfigProvider) GetBackends() ([]*sites.Backend, error) {
+	cfg, err := p.read()
+	if err != nil {
+		return nil, err
+	}
+
+	var backends []*sites.Backend
+	for _, b := range cfg.Backends {
+		backend := &sites.Backend{
+			Name: b.Name,
+		}
+
+		for _, s := range b.Servers {
+			server, err := sites.NewServer(s.URL, s.Weight)
+			if err != nil {
+				return nil, err
+			}
+
+			backend.Servers = append(backend.Servers, server)
+		}
+
+		backend.Algorithm, err = sites.ParseAlgorithm(b.Algorithm)
+		if err != nil {
+			return nil, fmt.Errorf("invalid algorithm for backend %s: %s", b.Name, err)
+		}
+
+		if b.HashKey != "" {
+			if backend.HashKey, err = sites.ParseHashKey(b.HashKey); err != nil {
+				return nil, fmt.Errorf("invalid hash key for backend %s: %s", b.Name, err)
+			}
+		}
+
+		if b.Transport != nil {
+			if backend.Transport, err = convertTransport(b.Transport); err != nil {
+				return nil, fmt.Errorf("invalid transport for backend %s: %s", b.Name, err)
+			}
+		}
+
+		if b.TLS != nil {
+			backend.TLS = &sites.BackendTLS{
+				CAFile:             b.TLS.CAFile,
+				ServerName:         b.TLS.ServerName,
+				CertFile:           b.TLS.CertFile,
+				KeyFile:            b.TLS.KeyFile,
+				LocalCAClientCert:  b.TLS.LocalCAClientCert,
+				InsecureSkipVerify: b.TLS.InsecureSkipVerify,
+			}
+		}
+
+		if b.HealthCheck != nil {
+			if backend.HealthCheck, err = convertHealthCheck(b.HealthCheck); err != nil {
+				return nil, fmt.Errorf("invalid health check for backend %s: %s", b.Name, err)
+			}
+		}
+
+		if b.CircuitBreaker != nil {
+			if backend.CircuitBreaker, err = convertCircuitBreaker(b.CircuitBreaker); err != nil {
+				return nil, fmt.Errorf("invalid circuit breaker for backend %s: %s", b.Name, err)
+			}
+		}
+
+		if b.StickySession != nil {
+			backend.StickySession = &sites.StickySession{
+				CookieName: b.StickySession.CookieName,
+				Secure:     b.StickySession.Secure,
+				HTTPOnly:   b.StickySession.HTTPOnly,
+			}
+
+			if b.StickySession.TTL != "" {
+				backend.StickySession.TTL, err = time.ParseDuration(b.StickySession.TTL)
+				if err != nil {
+					return nil, fmt.Errorf("invalid sticky session TTL for backend %s: %s", b.Name, err)
+				}
+			}
+		}
+
+		if b.Retry != nil {
+			backend.Retry = &sites.Retry{
+				Attempts:     b.Retry.Attempts,
+				Statuses:     b.Retry.Statuses,
+				Budget:       b.Retry.Budget,
+				MaxBodyBytes: b.Retry.MaxBodyBytes,
+			}
+		}
+
+		backends = append(backends, backend)
+	}
+
+	return backends, nil
+}
+
+// GetFrontends returns the frontends defined in the configuration file.
+func (p *ConfigProvider) GetFrontends() ([]*sites.Frontend, error) {
+	cfg, err := p.read()
+	if err != nil {
+		return nil, err
+	}
+
+	var frontends []*sites.Frontend
+	for _, f := range cfg.Frontends {
+		frontend := sites.NewFrontend(f.Backend, f.Domain)
+		frontend.CertGroup = f.CertGroup
+		frontend.PathPrefix = f.PathPrefix
+		frontend.PathRegex = f.PathRegex
+		frontend.StripPrefix = f.StripPrefix
+		frontend.Priority = f.Priority
+		frontend.Passthrough = f.Passthrough
+		frontend.TCPPort = f.TCPPort
+
+		for _, s := range f.Backends {
+			frontend.Splits = append(frontend.Splits, &sites.BackendSplit{
+				BackendName: s.Name,
+				Weight:      s.Weight,
+			})
+		}
+
+		if f.SplitOverride != "" {
+			if frontend.SplitOverride, err = sites.ParseSplitOverride(f.SplitOverride); err != nil {
+				return nil, fmt.Errorf("invalid split override for frontend %s: %s", f.Domain, err)
+			}
+		}
+
+		if f.Mirror != nil {
+			frontend.Mirror = &sites.Mirror{
+				BackendName:  f.Mirror.Backend,
+				Percentage:   f.Mirror.Percentage,
+				MaxBodyBytes: f.Mirror.MaxBodyBytes,
+			}
+		}
+
+		if f.ClientAuth != nil && f.ClientAuth.CAFile != "" {
+			mode, err := sites.ParseClientAuthMode(f.ClientAuth.Mode)
+			if err != nil {
+				return nil, fmt.Errorf("invalid client authentication for frontend %s: %s", f.Domain, err)
+			}
+
+			frontend.ClientAuth = &sites.ClientAuth{
+				CAFile:          f.ClientAuth.CAFile,
+				Mode:            mode,
+				AllowedPatterns: f.ClientAuth.AllowedPatterns,
+			}
+		}
+
+		if f.HSTS != nil && f.HSTS.MaxAge > 0 {
+			frontend.HSTS = &sites.HSTS{
+				MaxAge:            time.Duration(f.HSTS.MaxAge) * time.Second,
+				IncludeSubDomains: f.HSTS.IncludeSubDomains,
+				Preload:           f.HSTS.Preload,
+			}
+		}
+
+		frontend.ProxyProtocol, err = sites.ParseProxyProtocol(f.ProxyProtocol)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PROXY protocol for frontend %s: %s", f.Domain, err)
+		}
+
+		if f.Upgrade != nil {
+			frontend.Upgrade = &sites.Upgrade{}
+
+			if f.Upgrade.IdleTimeout != "" {
+				frontend.Upgrade.IdleTimeout, err = time.ParseDuration(f.Upgrade.IdleTimeout)
+				if err != nil {
+					return nil, fmt.Errorf("invalid upgrade idle timeout for frontend %s: %s", f.Domain, err)
+				}
+			}
+		}
+
+		frontend.Rules, err = sitesjson.ConvertRules(f.Rules)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rules for frontend %s: %s", f.Domain, err)
+		}
+
+		frontends = append(frontends, frontend)
+	}
+
+	return frontends, nil
+}
+
+func convertTransport(f *fileTransport) (*sites.Transport, error) {
+	t := &sites.Transport{
+		MaxIdleConnsPerHost: f.MaxIdleConnsPerHost,
+		DisableHTTP2:        f.DisableHTTP2,
+	}
+
+	durations := []struct {
+		value string
+		d     *time.Duration
+	}{
+		{f.DialTimeout, &t.DialTimeout},
+		{f.TLSHandshakeTimeout, &t.TLSHandshakeTimeout},
+		{f.ResponseHeaderTimeout, &t.ResponseHeaderTimeout},
+		{f.KeepAlive, &t.KeepAlive},
+		{f.IdleConnTimeout, &t.IdleConnTimeout},
+	}
+
+	for _, duration := range durations {
+		if duration.value == "" {
+			continue
+		}
+
+		var err error
+		if *duration.d, err = time.ParseDuration(duration.value); err != nil {
+			return nil, err
+		}
+	}
+
+	return t, nil
+}
+
+func convertHealthCheck(f *fileHealthCheck) (*sites.HealthCheck, error) {
+	hc := &sites.HealthCheck{
+		Path:   f.Path,
+		Status: f.Status,
+		Rise:   f.Rise,
+		Fall:   f.Fall,
+	}
+
+	var err error
+
+	if f.Interval != "" {
+		if hc.Interval, err = time.ParseDuration(f.Interval); err != nil {
+			return nil, err
+		}
+	}
+
+	if f.Timeout != "" {
+		if hc.Timeout, err = time.ParseDuration(f.Timeout); err != nil {
+			return nil, err
+		}
+	}
+
+	return hc, nil
+}
+
+func convertCircuitBreaker(f *fileCircuitBreaker) (*sites.CircuitBreaker, error) {
+	cb := &sites.CircuitBreaker{
+		Errors:              f.Errors,
+		HalfOpenRequests:    f.HalfOpenRequests,
+		FallbackStatus:      f.FallbackStatus,
+		FallbackBody:        f.FallbackBody,
+		FallbackContentType: f.FallbackContentType,
+	}
+
+	if f.EjectDuration != "" {
+		var err error
+		if cb.EjectDuration, err = time.ParseDuration(f.EjectDuration); err != nil {
+			return nil, err
+		}
+	}
+
+	return cb, nil
+}
@@ -0,0 +1,45 @@
+package fileconfig
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNotificationOnModification(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := ioutil.WriteFile(path, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := New(path, PollInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := p.GetNotificationChannel()
+
+	select {
+	case <-c:
+		t.Fatal("expected no notification for an unmodified file")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// move the modification time explicitly, as file systems may not
+	// register a write this quickly
+	modTime := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case update := <-c:
+		if !update {
+			t.Fatal("expected update")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected notification for a modified file")
+	}
+}
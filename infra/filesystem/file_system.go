@@ -10,4 +10,5 @@ type FileSystem interface {
 	WriteBytes(path string, data []byte) error
 	Write(path string, r io.Reader) error
 	ReadBytes(path string) ([]byte, error)
+	ListFiles() ([]string, error)
 }
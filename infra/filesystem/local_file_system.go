@@ -76,3 +76,27 @@ func (fs *LocalFileSystem) Write(path string, r io.Reader) error {
 func (fs *LocalFileSystem) ReadBytes(path string) ([]byte, error) {
 	return ioutil.ReadFile(fs.root + path)
 }
+
+// ListFiles returns the paths of all files in the root of the file system.
+func (fs *LocalFileSystem) ListFiles() ([]string, error) {
+	dir := fs.root
+	if dir == "" {
+		dir = "."
+	}
+
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, fi := range infos {
+		if fi.IsDir() {
+			continue
+		}
+
+		paths = append(paths, fi.Name())
+	}
+
+	return paths, nil
+}
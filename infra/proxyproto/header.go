@@ -0,0 +1,239 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/off-sync/platform-proxy/domain/sites"
+)
+
+const (
+	v1Prefix    = "PROXY "
+	v1MaxLength = 107
+
+	v2Version     = 0x20
+	v2CmdLocal    = 0x00
+	v2CmdProxy    = 0x01
+	v2FamTCP4     = 0x11
+	v2FamTCP6     = 0x21
+	v2HeaderBytes = 16
+)
+
+var v2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// ErrInvalidHeader is returned when a connection starts with a malformed
+// PROXY protocol header.
+var ErrInvalidHeader = errors.New("invalid PROXY protocol header")
+
+// ErrMissingHeader is returned when a connection from a trusted source
+// does not start with a PROXY protocol header.
+var ErrMissingHeader = errors.New("missing PROXY protocol header")
+
+// readHeader reads a version 1 or 2 header from the reader. It returns the
+// source and destination addresses from the header, which are nil if the
+// header does not contain addresses. It returns ErrMissingHeader if the
+// reader does not start with a header.
+func readHeader(r *bufio.Reader) (src, dst net.Addr, err error) {
+	prefix, err := r.Peek(len(v1Prefix))
+	if err != nil {
+		return nil, nil, ErrMissingHeader
+	}
+
+	if string(prefix) == v1Prefix {
+		return readV1(r)
+	}
+
+	sig, err := r.Peek(len(v2Signature))
+	if err != nil || !bytes.Equal(sig, v2Signature) {
+		return nil, nil, ErrMissingHeader
+	}
+
+	return readV2(r)
+}
+
+// readV1 reads a header like 'PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\n'.
+func readV1(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	var line []byte
+	for len(line) < v1MaxLength {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		line = append(line, b)
+
+		if b == '\n' {
+			break
+		}
+	}
+
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, nil, ErrInvalidHeader
+	}
+
+	fields := strings.Fields(string(line[:len(line)-2]))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil, nil
+	}
+
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, nil, ErrInvalidHeader
+	}
+
+	src, err := parseV1Addr(fields[2], fields[4])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dst, err := parseV1Addr(fields[3], fields[5])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return src, dst, nil
+}
+
+func parseV1Addr(ip, port string) (net.Addr, error) {
+	addr := &net.TCPAddr{IP: net.ParseIP(ip)}
+	if addr.IP == nil {
+		return nil, ErrInvalidHeader
+	}
+
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, ErrInvalidHeader
+	}
+
+	addr.Port = int(p)
+
+	return addr, nil
+}
+
+// readV2 reads a binary header. Type-length-value fields are skipped.
+func readV2(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	hdr := make([]byte, v2HeaderBytes)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return nil, nil, err
+	}
+
+	if hdr[12]&0xf0 != v2Version {
+		return nil, nil, ErrInvalidHeader
+	}
+
+	body := make([]byte, binary.BigEndian.Uint16(hdr[14:16]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, nil, err
+	}
+
+	switch hdr[12] & 0x0f {
+	case v2CmdLocal:
+		// health checks of the load balancer itself
+		return nil, nil, nil
+	case v2CmdProxy:
+	default:
+		return nil, nil, ErrInvalidHeader
+	}
+
+	var ipLen int
+	switch hdr[13] {
+	case v2FamTCP4:
+		ipLen = net.IPv4len
+	case v2FamTCP6:
+		ipLen = net.IPv6len
+	default:
+		// other protocols do not have TCP addresses
+		return nil, nil, nil
+	}
+
+	if len(body) < 2*ipLen+4 {
+		return nil, nil, ErrInvalidHeader
+	}
+
+	src := &net.TCPAddr{
+		IP:   net.IP(body[:ipLen]),
+		Port: int(binary.BigEndian.Uint16(body[2*ipLen:])),
+	}
+
+	dst := &net.TCPAddr{
+		IP:   net.IP(body[ipLen : 2*ipLen]),
+		Port: int(binary.BigEndian.Uint16(body[2*ipLen+2:])),
+	}
+
+	return src, dst, nil
+}
+
+// WriteHeader writes a header of the provided version, passing the source
+// and destination addresses of a client connection.
+func WriteHeader(w io.Writer, version sites.ProxyProtocol, src, dst net.Addr) error {
+	var hdr []byte
+	switch version {
+	case sites.ProxyProtocolNone:
+		return nil
+	case sites.ProxyProtocolV1:
+		hdr = v1Header(src, dst)
+	case sites.ProxyProtocolV2:
+		hdr = v2Header(src, dst)
+	default:
+		return fmt.Errorf("unsupported PROXY protocol version: %d", version)
+	}
+
+	_, err := w.Write(hdr)
+
+	return err
+}
+
+func v1Header(src, dst net.Addr) []byte {
+	s, sok := src.(*net.TCPAddr)
+	d, dok := dst.(*net.TCPAddr)
+	if !sok || !dok {
+		return []byte("PROXY UNKNOWN\r\n")
+	}
+
+	proto := "TCP4"
+	if s.IP.To4() == nil || d.IP.To4() == nil {
+		proto = "TCP6"
+	}
+
+	return []byte(fmt.Sprintf("PROXY %s %s %s %d %d\r\n", proto, s.IP, d.IP, s.Port, d.Port))
+}
+
+func v2Header(src, dst net.Addr) []byte {
+	hdr := make([]byte, v2HeaderBytes)
+	copy(hdr, v2Signature)
+
+	s, sok := src.(*net.TCPAddr)
+	d, dok := dst.(*net.TCPAddr)
+	if !sok || !dok {
+		hdr[12] = v2Version | v2CmdLocal
+		return hdr
+	}
+
+	hdr[12] = v2Version | v2CmdProxy
+
+	var body []byte
+	if s4, d4 := s.IP.To4(), d.IP.To4(); s4 != nil && d4 != nil {
+		hdr[13] = v2FamTCP4
+		body = append(body, s4...)
+		body = append(body, d4...)
+	} else {
+		hdr[13] = v2FamTCP6
+		body = append(body, s.IP.To16()...)
+		body = append(body, d.IP.To16()...)
+	}
+
+	ports := make([]byte, 4)
+	binary.BigEndian.PutUint16(ports, uint16(s.Port))
+	binary.BigEndian.PutUint16(ports[2:], uint16(d.Port))
+	body = append(body, ports...)
+
+	binary.BigEndian.PutUint16(hdr[14:], uint16(len(body)))
+
+	return append(hdr, body...)
+}
@@ -0,0 +1,71 @@
+package proxyproto
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/off-sync/platform-proxy/domain/sites"
+)
+
+func TestWriteHeader(t *testing.T) {
+	src4 := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 56324}
+	dst4 := &net.TCPAddr{IP: net.ParseIP("192.0.2.2"), Port: 443}
+	src6 := &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 56324}
+	dst6 := &net.TCPAddr{IP: net.ParseIP("2001:db8::2"), Port: 443}
+	unix := &net.UnixAddr{Name: "/run/proxy.sock", Net: "unix"}
+
+	v2 := func(cmdFam []byte, body ...byte) []byte {
+		hdr := append([]byte("\r\n\r\n\x00\r\nQUIT\n"), cmdFam...)
+		hdr = append(hdr, byte(len(body)>>8), byte(len(body)))
+
+		return append(hdr, body...)
+	}
+
+	tests := []struct {
+		name     string
+		version  sites.ProxyProtocol
+		src, dst net.Addr
+		expected []byte
+	}{
+		{"none", sites.ProxyProtocolNone, src4, dst4, nil},
+		{"v1-tcp4", sites.ProxyProtocolV1, src4, dst4, []byte("PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\n")},
+		{"v1-tcp6", sites.ProxyProtocolV1, src6, dst6, []byte("PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\n")},
+		{"v1-unknown", sites.ProxyProtocolV1, unix, unix, []byte("PROXY UNKNOWN\r\n")},
+		{"v2-tcp4", sites.ProxyProtocolV2, src4, dst4, v2([]byte{0x21, 0x11},
+			192, 0, 2, 1,
+			192, 0, 2, 2,
+			0xdc, 0x04, 0x01, 0xbb,
+		)},
+		{"v2-tcp6", sites.ProxyProtocolV2, src6, dst6, v2([]byte{0x21, 0x21},
+			0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x01,
+			0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x02,
+			0xdc, 0x04, 0x01, 0xbb,
+		)},
+		{"v2-local", sites.ProxyProtocolV2, unix, unix, v2([]byte{0x20, 0x00})},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteHeader(&buf, test.version, test.src, test.dst); err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(buf.Bytes(), test.expected) {
+				t.Errorf("expected header %q, got %q", test.expected, buf.Bytes())
+			}
+		})
+	}
+}
+
+func TestWriteHeaderRejectsUnsupportedVersion(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteHeader(&buf, sites.ProxyProtocolV2+1, nil, nil); err == nil {
+		t.Error("expected error")
+	}
+
+	if buf.Len() > 0 {
+		t.Errorf("expected nothing to be written, got %q", buf.Bytes())
+	}
+}
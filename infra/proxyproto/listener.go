@@ -0,0 +1,191 @@
+package proxyproto
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HeaderTimeout limits the time a trusted source can take to send its header.
+const HeaderTimeout = 10 * time.Second
+
+// listener accepts connections from the wrapped listener, reading the
+// PROXY protocol header of connections from trusted sources.
+type listener struct {
+	net.Listener
+	trusted []*net.IPNet
+	conns   chan net.Conn
+	errs    chan error
+	done    chan struct{}
+	once    sync.Once
+}
+
+// NewListener wraps the listener so that the remote address of connections
+// from trusted sources, such as a network load balancer, is taken from their
+// PROXY protocol header. Trusted sources must send a header: otherwise a
+// client could send its own header through a source which does not, and
+// choose its address. Connections from trusted sources without a valid
+// header are closed. Connections from other sources are returned unchanged,
+// including any header they send.
+func NewListener(ln net.Listener, trusted []*net.IPNet) net.Listener {
+	l := &listener{
+		Listener: ln,
+		trusted:  trusted,
+		conns:    make(chan net.Conn),
+		errs:     make(chan error),
+		done:     make(chan struct{}),
+	}
+
+	go l.acceptLoop()
+
+	return l
+}
+
+// ParseCIDRs parses a comma separated list of CIDRs, such as
+// '10.0.0.0/8,192.168.0.0/16'.
+func ParseCIDRs(value string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+
+	for _, s := range strings.Split(value, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR: %s", s)
+		}
+
+		nets = append(nets, ipNet)
+	}
+
+	return nets, nil
+}
+
+func (l *listener) acceptLoop() {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			select {
+			case l.errs <- err:
+			case <-l.done:
+				return
+			}
+
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				continue
+			}
+
+			return
+		}
+
+		if !l.isTrusted(conn.RemoteAddr()) {
+			l.deliver(conn)
+			continue
+		}
+
+		// the header is read in the background, so that slow
+		// sources do not block other connections
+		go l.handle(conn)
+	}
+}
+
+func (l *listener) handle(conn net.Conn) {
+	r := bufio.NewReader(conn)
+
+	conn.SetReadDeadline(time.Now().Add(HeaderTimeout))
+	src, dst, err := readHeader(r)
+	conn.SetReadDeadline(time.Time{})
+
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	l.deliver(&Conn{
+		Conn:       conn,
+		r:          r,
+		remoteAddr: src,
+		localAddr:  dst,
+	})
+}
+
+func (l *listener) deliver(conn net.Conn) {
+	select {
+	case l.conns <- conn:
+	case <-l.done:
+		conn.Close()
+	}
+}
+
+func (l *listener) isTrusted(addr net.Addr) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+
+	for _, ipNet := range l.trusted {
+		if ipNet.Contains(tcpAddr.IP) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Accept returns the next connection.
+func (l *listener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case err := <-l.errs:
+		return nil, err
+	case <-l.done:
+		return nil, fmt.Errorf("listener closed")
+	}
+}
+
+// Close closes the wrapped listener.
+func (l *listener) Close() error {
+	l.once.Do(func() { close(l.done) })
+
+	return l.Listener.Close()
+}
+
+// Conn is a connection of which the addresses have been read from its
+// PROXY protocol header.
+type Conn struct {
+	net.Conn
+	r          *bufio.Reader
+	remoteAddr net.Addr
+	localAddr  net.Addr
+}
+
+// Read reads data following the header.
+func (c *Conn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// RemoteAddr returns the address of the client from the header, or the
+// remote address of the connection if the header has no addresses.
+func (c *Conn) RemoteAddr() net.Addr {
+	if c.remoteAddr != nil {
+		return c.remoteAddr
+	}
+
+	return c.Conn.RemoteAddr()
+}
+
+// LocalAddr returns the destination address from the header, or the
+// local address of the connection if the header has no addresses.
+func (c *Conn) LocalAddr() net.Addr {
+	if c.localAddr != nil {
+		return c.localAddr
+	}
+
+	return c.Conn.LocalAddr()
+}
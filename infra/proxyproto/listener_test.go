@@ -0,0 +1,125 @@
+package proxyproto
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/off-sync/platform-proxy/domain/sites"
+)
+
+// accept connects to a listener trusting the CIDRs, sends the data and
+// returns the accepted connection, or nil if it was closed.
+func accept(t *testing.T, cidrs string, data []byte) (net.Conn, []byte) {
+	trusted, err := ParseCIDRs(cidrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ln := NewListener(tcp, trusted)
+	defer ln.Close()
+
+	client, err := net.Dial("tcp", tcp.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	client.Write(data)
+	client.(*net.TCPConn).CloseWrite()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			close(accepted)
+			return
+		}
+
+		accepted <- conn
+	}()
+
+	select {
+	case conn := <-accepted:
+		defer conn.Close()
+
+		body, _ := ioutil.ReadAll(conn)
+
+		return conn, body
+	case <-time.After(500 * time.Millisecond):
+		// the header was rejected: the client sees the connection closed
+		client.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if n, err := client.Read(make([]byte, 1)); n > 0 || err == nil {
+			t.Fatal("expected the connection to be closed")
+		}
+
+		return nil, nil
+	}
+}
+
+func TestListener(t *testing.T) {
+	src := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 56324}
+	dst := &net.TCPAddr{IP: net.ParseIP("192.0.2.2"), Port: 443}
+
+	header := func(version sites.ProxyProtocol) []byte {
+		var buf bytes.Buffer
+		if err := WriteHeader(&buf, version, src, dst); err != nil {
+			t.Fatal(err)
+		}
+
+		return buf.Bytes()
+	}
+
+	tests := []struct {
+		name       string
+		cidrs      string
+		data       []byte
+		accepted   bool
+		remoteAddr string
+		body       string
+	}{
+		{"v1", "127.0.0.0/8", append(header(sites.ProxyProtocolV1), "GET /"...), true, src.String(), "GET /"},
+		{"v2", "127.0.0.0/8", append(header(sites.ProxyProtocolV2), "GET /"...), true, src.String(), "GET /"},
+		{"trusted-without-header", "127.0.0.0/8", []byte("GET / HTTP/1.1\r\n"), false, "", ""},
+		{"trusted-invalid-header", "127.0.0.0/8", []byte("PROXY TCP4 nonsense\r\n"), false, "", ""},
+		{"untrusted-without-header", "10.0.0.0/8", []byte("GET /"), true, "127.0.0.1", "GET /"},
+		{"untrusted-header-ignored", "10.0.0.0/8", header(sites.ProxyProtocolV1), true, "127.0.0.1", string(header(sites.ProxyProtocolV1))},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, body := accept(t, test.cidrs, test.data)
+
+			if !test.accepted {
+				if conn != nil {
+					t.Fatal("expected the connection to be rejected")
+				}
+				return
+			}
+
+			if conn == nil {
+				t.Fatal("expected the connection to be accepted")
+			}
+
+			host := conn.RemoteAddr().String()
+			if test.remoteAddr == "127.0.0.1" {
+				host, _, _ = net.SplitHostPort(host)
+			}
+
+			if host != test.remoteAddr {
+				t.Errorf("expected remote address %s, got %s", test.remoteAddr, host)
+			}
+
+			if string(body) != test.body {
+				t.Errorf("expected body %q, got %q", test.body, body)
+			}
+		})
+	}
+}
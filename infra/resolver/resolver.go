@@ -0,0 +1,206 @@
+package resolver
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/off-sync/platform-proxy/app/interfaces"
+	"github.com/off-sync/platform-proxy/domain/sites"
+)
+
+// Addr holds a resolved address of a server.
+type Addr struct {
+	// Server holds the server which resolved to the address.
+	Server *sites.Server
+	// Host holds the IP address and port.
+	Host string
+	// ServerName holds the host name which resolved to the IP address,
+	// e.g. to verify the TLS certificate of the server. For SRV records
+	// this is the target of the record.
+	ServerName string
+}
+
+// UpdateFunc is called with the addresses which have been added and the
+// addresses which have been removed since the previous resolution.
+type UpdateFunc func(added, removed []*Addr)
+
+// LookupFunc returns the addresses of a server.
+type LookupFunc func(server *sites.Server) ([]*Addr, error)
+
+// Resolver periodically resolves the host names of the servers of a
+// backend and reports changes in their addresses. If a host name cannot
+// be resolved, the last known addresses of its server are kept, so a
+// transient DNS failure does not remove servers.
+type Resolver struct {
+	backend  *sites.Backend
+	interval time.Duration
+	lookup   LookupFunc
+	update   UpdateFunc
+	log      interfaces.Logger
+
+	sync.Mutex
+	known   map[*sites.Server][]*Addr
+	current map[string]*Addr
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// New creates a new resolver for the backend, which resolves the servers
+// on the interval once started. An interval of 0 disables resolving after
+// the first time.
+func New(backend *sites.Backend, interval time.Duration, update UpdateFunc, log interfaces.Logger) *Resolver {
+	return &Resolver{
+		backend:  backend,
+		interval: interval,
+		lookup:   Lookup,
+		update:   update,
+		log:      log.WithField("backend", backend.Name),
+		known:    make(map[*sites.Server][]*Addr),
+		current:  make(map[string]*Addr),
+		stop:     make(chan struct{}),
+	}
+}
+
+// Resolve resolves the servers and reports the changes in their
+// addresses. Servers which cannot be resolved, and have not been resolved
+// before, have no addresses until they can be resolved.
+func (r *Resolver) Resolve() {
+	r.Lock()
+	defer r.Unlock()
+
+	var next []*Addr
+	hosts := make(map[string]*Addr)
+
+	for _, server := range r.backend.Servers {
+		addrs, err := r.lookup(server)
+		if err != nil {
+			r.log.
+				WithField("server", server).
+				WithField("addrs", len(r.known[server])).
+				WithError(err).
+				Warn("resolving server: keeping last known addresses")
+
+			addrs = r.known[server]
+		} else {
+			r.known[server] = addrs
+		}
+
+		for _, addr := range addrs {
+			if _, found := hosts[addr.Host]; found {
+				continue
+			}
+
+			hosts[addr.Host] = addr
+			next = append(next, addr)
+		}
+	}
+
+	var added, removed []*Addr
+
+	for _, addr := range next {
+		if _, found := r.current[addr.Host]; !found {
+			added = append(added, addr)
+		}
+	}
+
+	for host, addr := range r.current {
+		if _, found := hosts[host]; !found {
+			removed = append(removed, addr)
+		}
+	}
+
+	r.current = hosts
+
+	if len(added) > 0 || len(removed) > 0 {
+		r.update(added, removed)
+	}
+}
+
+// Start starts resolving the servers on the interval.
+func (r *Resolver) Start() {
+	if r.interval <= 0 {
+		return
+	}
+
+	r.wg.Add(1)
+	go r.run()
+}
+
+// Close stops resolving and waits for a resolution in progress to
+// complete.
+func (r *Resolver) Close() {
+	close(r.stop)
+	r.wg.Wait()
+}
+
+func (r *Resolver) run() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.Resolve()
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// Lookup returns the addresses of a server. Servers naming an SRV record
+// are resolved using the targets and ports of the record with the highest
+// priority, otherwise the port of the server URL is used.
+func Lookup(server *sites.Server) ([]*Addr, error) {
+	if !server.IsSRV() {
+		return lookupHost(server, server.URL.Hostname(), server.URL.Port())
+	}
+
+	_, records, err := net.LookupSRV("", "", server.URL.Hostname())
+	if err != nil {
+		return nil, err
+	}
+
+	var addrs []*Addr
+
+	// records are sorted by priority: only the records with the lowest
+	// value are used, the others are backups
+	for _, record := range records {
+		if record.Priority != records[0].Priority {
+			break
+		}
+
+		target := strings.TrimSuffix(record.Target, ".")
+
+		a, err := lookupHost(server, target, strconv.Itoa(int(record.Port)))
+		if err != nil {
+			return nil, err
+		}
+
+		addrs = append(addrs, a...)
+	}
+
+	return addrs, nil
+}
+
+func lookupHost(server *sites.Server, hostname, port string) ([]*Addr, error) {
+	ips, err := net.LookupHost(hostname)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := make([]*Addr, len(ips))
+	for i, ip := range ips {
+		addrs[i] = &Addr{
+			Server:     server,
+			Host:       net.JoinHostPort(ip, port),
+			ServerName: hostname,
+		}
+	}
+
+	return addrs, nil
+}
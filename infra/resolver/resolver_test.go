@@ -0,0 +1,213 @@
+package resolver
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/off-sync/platform-proxy/app/interfaces"
+	"github.com/off-sync/platform-proxy/domain/sites"
+)
+
+type nopLogger struct{}
+
+func (l nopLogger) WithField(key string, value interface{}) interfaces.Logger { return l }
+func (l nopLogger) WithError(err error) interfaces.Logger                     { return l }
+func (l nopLogger) Debug(msg string)                                          {}
+func (l nopLogger) Info(msg string)                                           {}
+func (l nopLogger) Warn(msg string)                                           {}
+func (l nopLogger) Error(msg string)                                          {}
+func (l nopLogger) Fatal(msg string)                                          { panic(msg) }
+
+// fakeDNS resolves host names to the IP addresses set for them, or fails
+// if none are set.
+type fakeDNS struct {
+	sync.Mutex
+	ips map[string][]string
+}
+
+func (d *fakeDNS) set(hostname string, ips ...string) {
+	d.Lock()
+	defer d.Unlock()
+
+	d.ips[hostname] = ips
+}
+
+func (d *fakeDNS) lookup(server *sites.Server) ([]*Addr, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	hostname := server.URL.Hostname()
+
+	ips, found := d.ips[hostname]
+	if !found {
+		return nil, fmt.Errorf("lookup %s: no such host", hostname)
+	}
+
+	var addrs []*Addr
+	for _, ip := range ips {
+		addrs = append(addrs, &Addr{
+			Server:     server,
+			Host:       net.JoinHostPort(ip, server.URL.Port()),
+			ServerName: hostname,
+		})
+	}
+
+	return addrs, nil
+}
+
+// updates records the hosts of the addresses reported by a resolver.
+type updates struct {
+	sync.Mutex
+	added, removed [][]string
+	notify         chan struct{}
+}
+
+func (u *updates) update(added, removed []*Addr) {
+	u.Lock()
+	u.added = append(u.added, hosts(added))
+	u.removed = append(u.removed, hosts(removed))
+	u.Unlock()
+
+	select {
+	case u.notify <- struct{}{}:
+	default:
+	}
+}
+
+// last returns the hosts of the last update, or nil if there was none.
+func (u *updates) last() (added, removed []string) {
+	u.Lock()
+	defer u.Unlock()
+
+	if len(u.added) == 0 {
+		return nil, nil
+	}
+
+	return u.added[len(u.added)-1], u.removed[len(u.removed)-1]
+}
+
+func (u *updates) count() int {
+	u.Lock()
+	defer u.Unlock()
+
+	return len(u.added)
+}
+
+func hosts(addrs []*Addr) []string {
+	hosts := []string{}
+	for _, addr := range addrs {
+		hosts = append(hosts, addr.Host)
+	}
+
+	sort.Strings(hosts)
+
+	return hosts
+}
+
+func newTestResolver(t *testing.T, interval time.Duration, servers ...string) (*Resolver, *fakeDNS, *updates) {
+	backend, err := sites.NewBackend("www", servers...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dns := &fakeDNS{ips: make(map[string][]string)}
+	u := &updates{notify: make(chan struct{}, 1)}
+
+	r := New(backend, interval, u.update, nopLogger{})
+	r.lookup = dns.lookup
+
+	return r, dns, u
+}
+
+func expectUpdate(t *testing.T, u *updates, expectedAdded, expectedRemoved []string) {
+	t.Helper()
+
+	added, removed := u.last()
+
+	if !reflect.DeepEqual(added, expectedAdded) {
+		t.Errorf("expected added %v, got %v", expectedAdded, added)
+	}
+
+	if !reflect.DeepEqual(removed, expectedRemoved) {
+		t.Errorf("expected removed %v, got %v", expectedRemoved, removed)
+	}
+}
+
+func TestResolveReportsChangedAddresses(t *testing.T) {
+	r, dns, u := newTestResolver(t, 0, "http://a.example.com:8080", "http://b.example.com:8080")
+
+	// addresses shared by servers are only reported once
+	dns.set("a.example.com", "10.0.0.1", "10.0.0.2")
+	dns.set("b.example.com", "10.0.0.2")
+
+	r.Resolve()
+	expectUpdate(t, u, []string{"10.0.0.1:8080", "10.0.0.2:8080"}, []string{})
+
+	dns.set("a.example.com", "10.0.0.1", "10.0.0.3")
+	dns.set("b.example.com", "10.0.0.3")
+
+	r.Resolve()
+	expectUpdate(t, u, []string{"10.0.0.3:8080"}, []string{"10.0.0.2:8080"})
+
+	r.Resolve()
+	if n := u.count(); n != 2 {
+		t.Errorf("expected no update for unchanged addresses, got %d updates", n)
+	}
+}
+
+func TestResolveKeepsLastKnownAddressesOnFailure(t *testing.T) {
+	r, dns, u := newTestResolver(t, 0, "http://a.example.com:8080", "http://b.example.com:8080")
+
+	dns.set("a.example.com", "10.0.0.1")
+
+	// b.example.com cannot be resolved: it has no addresses yet
+	r.Resolve()
+	expectUpdate(t, u, []string{"10.0.0.1:8080"}, []string{})
+
+	dns.set("b.example.com", "10.0.0.2")
+
+	r.Resolve()
+	expectUpdate(t, u, []string{"10.0.0.2:8080"}, []string{})
+
+	dns.Lock()
+	delete(dns.ips, "a.example.com")
+	dns.Unlock()
+
+	r.Resolve()
+	if n := u.count(); n != 2 {
+		t.Errorf("expected the addresses of a.example.com to be kept, got %d updates", n)
+	}
+
+	// the last known addresses are replaced once it resolves again
+	dns.set("a.example.com", "10.0.0.3")
+
+	r.Resolve()
+	expectUpdate(t, u, []string{"10.0.0.3:8080"}, []string{"10.0.0.1:8080"})
+}
+
+func TestStartResolvesOnInterval(t *testing.T) {
+	r, dns, u := newTestResolver(t, 10*time.Millisecond, "http://a.example.com:8080")
+
+	dns.set("a.example.com", "10.0.0.1")
+
+	r.Resolve()
+	<-u.notify
+
+	r.Start()
+	defer r.Close()
+
+	dns.set("a.example.com", "10.0.0.2")
+
+	select {
+	case <-u.notify:
+	case <-time.After(time.Second):
+		t.Fatal("expected servers to be resolved again")
+	}
+
+	expectUpdate(t, u, []string{"10.0.0.2:8080"}, []string{"10.0.0.1:8080"})
+}
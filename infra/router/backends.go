@@ -0,0 +1,264 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/off-sync/platform-proxy/app/interfaces"
+	"github.com/off-sync/platform-proxy/domain/sites"
+	"github.com/off-sync/platform-proxy/infra/resolver"
+	"github.com/vulcand/oxy/forward"
+)
+
+// backendHandler load balances requests over the healthy server addresses
+// of a backend.
+type backendHandler struct {
+	http.Handler
+	pool           *serverPool
+	serverNames    *serverNames
+	resolver       *resolver.Resolver
+	healthChecker  *healthChecker
+	circuitBreaker *circuitBreaker
+	transport      http.RoundTripper
+	log            interfaces.Logger
+}
+
+// newBackendHandler creates a handler which load balances requests over
+// the addresses of all servers of the backend, using its algorithm. The protocol used to reach
+// the servers is based on the scheme of their URLs. Upgraded connections
+// are tunneled to the selected server. The host names of the servers are
+// resolved again on the resolve interval, unless it is 0. The handler must
+// be started before use, and closed when it is no longer used.
+func newBackendHandler(backend *sites.Backend, options *backendOptions, log interfaces.Logger) (*backendHandler, error) {
+	scheme, err := backendScheme(backend)
+	if err != nil {
+		return nil, err
+	}
+
+	if backend.TLS != nil && scheme != schemeHTTPS {
+		return nil, fmt.Errorf("TLS settings require https servers")
+	}
+
+	if err := validateTransport(scheme, backend.Transport); err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := newBackendTLSConfig(backend.TLS, options.clientCerts)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := backend.Transport.WithDefaults()
+	names := newServerNames()
+
+	dialer := &serverDialer{
+		dialer:           newDialer(settings),
+		tlsConfig:        tlsConfig,
+		handshakeTimeout: settings.TLSHandshakeTimeout,
+		serverNames:      names,
+	}
+
+	transport, err := newTransport(scheme, settings, dialer)
+	if err != nil {
+		return nil, err
+	}
+
+	if backend.Retry != nil {
+		transport = &connErrTransport{transport}
+	}
+
+	fwd, err := newForwarder(scheme, transport, log)
+	if err != nil {
+		return nil, fmt.Errorf("creating forwarder: %s", err)
+	}
+
+	pool := newServerPool()
+
+	var next http.Handler = newUpgradeForwarder(fwd, dialer, log)
+
+	var cb *circuitBreaker
+	if backend.CircuitBreaker != nil {
+		cb = newCircuitBreaker(backend, pool, next, log)
+		next = cb
+	}
+
+	var sticky *stickySessions
+	if backend.StickySession != nil {
+		sticky = newStickySessions(backend.StickySession, options.stickySecret)
+		next = sticky.wrap(next)
+	}
+
+	lb, err := newLoadBalancer(backend, next)
+	if err != nil {
+		return nil, fmt.Errorf("creating load balancer: %s", err)
+	}
+
+	pool.lb = lb
+
+	if sticky != nil {
+		sticky.lb = lb
+	}
+
+	h := &backendHandler{
+		Handler:        lb,
+		pool:           pool,
+		serverNames:    names,
+		healthChecker:  newHealthChecker(backend, transport, pool, log),
+		circuitBreaker: cb,
+		transport:      transport,
+		log:            log.WithField("backend", backend.Name),
+	}
+
+	h.resolver = resolver.New(backend, options.resolveInterval, h.updateAddrs, log)
+	h.resolver.Resolve()
+
+	if sticky != nil {
+		h.Handler = sticky
+	}
+
+	if backend.Retry != nil {
+		h.Handler = newRetrier(backend, h.Handler, log)
+	}
+
+	if cb != nil {
+		h.Handler = fallback(cb, pool, h.Handler)
+	}
+
+	return h, nil
+}
+
+// updateAddrs adds the resolved server addresses to, and removes the
+// addresses which no longer resolve from the load balancer.
+func (h *backendHandler) updateAddrs(added, removed []*resolver.Addr) {
+	for _, addr := range removed {
+		u := forwardURL(addr.Server.URL)
+		u.Host = addr.Host
+
+		log := h.log.
+			WithField("server", addr.Server).
+			WithField("addr", u)
+
+		log.Info("removing server")
+
+		h.healthChecker.remove(u)
+
+		if h.circuitBreaker != nil {
+			h.circuitBreaker.remove(u)
+		}
+
+		if err := h.pool.remove(u); err != nil {
+			log.WithError(err).Error("removing server")
+		}
+
+		h.serverNames.remove(u.Host)
+	}
+
+	for _, addr := range added {
+		u := forwardURL(addr.Server.URL)
+		u.Host = addr.Host
+
+		log := h.log.
+			WithField("server", addr.Server).
+			WithField("addr", u).
+			WithField("weight", addr.Server.EffectiveWeight())
+
+		log.Info("adding server")
+
+		h.serverNames.set(u.Host, addr.ServerName)
+
+		if err := h.pool.add(u, addr.Server.EffectiveWeight()); err != nil {
+			log.WithError(err).Error("adding server")
+			continue
+		}
+
+		host := addr.Server.URL.Host
+		if addr.Server.IsSRV() {
+			host = addr.ServerName
+		}
+
+		h.healthChecker.add(addr.Server.URL, u, host)
+
+		if h.circuitBreaker != nil {
+			h.circuitBreaker.add(u)
+		}
+	}
+}
+
+// start starts resolving the servers and the health checks.
+func (h *backendHandler) start() {
+	h.resolver.Start()
+	h.healthChecker.start()
+}
+
+// close stops resolving the servers, the health checks and the circuit
+// breaker, and closes the idle connections to the servers. Connections of
+// requests in progress are left open.
+func (h *backendHandler) close() {
+	h.resolver.Close()
+	h.healthChecker.close()
+
+	if h.circuitBreaker != nil {
+		h.circuitBreaker.close()
+	}
+
+	if t, ok := h.transport.(interface{ CloseIdleConnections() }); ok {
+		t.CloseIdleConnections()
+	}
+}
+
+// circuitStates returns the circuit states of all server addresses, or
+// nil if the backend has no circuit breaker.
+func (h *backendHandler) circuitStates() []*CircuitState {
+	if h.circuitBreaker == nil {
+		return nil
+	}
+
+	return h.circuitBreaker.states()
+}
+
+// serverNames maps the server addresses used by the load balancer to the
+// host names of the servers.
+type serverNames struct {
+	sync.RWMutex
+	names map[string]string
+}
+
+func newServerNames() *serverNames {
+	return &serverNames{
+		names: make(map[string]string),
+	}
+}
+
+func (n *serverNames) get(addr string) (string, bool) {
+	n.RLock()
+	defer n.RUnlock()
+
+	name, found := n.names[addr]
+
+	return name, found
+}
+
+func (n *serverNames) set(addr, name string) {
+	n.Lock()
+	defer n.Unlock()
+
+	n.names[addr] = name
+}
+
+func (n *serverNames) remove(addr string) {
+	n.Lock()
+	defer n.Unlock()
+
+	delete(n.names, addr)
+}
+
+// newForwarder creates the handler forwarding requests to the server
+// selected by the load balancer.
+func newForwarder(scheme string, transport http.RoundTripper, log interfaces.Logger) (http.Handler, error) {
+	if scheme == schemeGRPC {
+		return newGRPCForwarder(transport, log), nil
+	}
+
+	return forward.New(forward.RoundTripper(transport))
+}
@@ -0,0 +1,392 @@
+package router
+
+import (
+	"fmt"
+	"hash/crc32"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/off-sync/platform-proxy/domain/sites"
+	"github.com/vulcand/oxy/roundrobin"
+)
+
+// hashReplicas holds the number of points on the hash ring per unit of
+// server weight.
+const hashReplicas = 100
+
+// loadBalancer selects a server address for each request, sets it as the
+// request URL and passes the request to the next handler.
+type loadBalancer interface {
+	http.Handler
+	UpsertServer(u *url.URL, weight int) error
+	RemoveServer(u *url.URL) error
+	Servers() []*url.URL
+}
+
+// newLoadBalancer creates a load balancer using the algorithm of the backend.
+func newLoadBalancer(backend *sites.Backend, next http.Handler) (loadBalancer, error) {
+	switch backend.Algorithm {
+	case sites.RoundRobin:
+		rr, err := roundrobin.New(next)
+		if err != nil {
+			return nil, err
+		}
+
+		return &roundRobin{RoundRobin: rr, next: next}, nil
+
+	case sites.LeastConnections:
+		return newBalancer(next, leastConnections()), nil
+
+	case sites.PowerOfTwoChoices:
+		rnd := &lockedRand{r: rand.New(rand.NewSource(time.Now().UnixNano()))}
+
+		return newBalancer(next, func(servers []*balancedServer, req *http.Request) *balancedServer {
+			return pickPowerOfTwo(servers, rnd)
+		}), nil
+
+	case sites.ConsistentHash:
+		key := backend.HashKey
+		if key == nil {
+			key = &sites.HashKey{Source: sites.HashClientIP}
+		}
+
+		b := newBalancer(next, nil)
+		b.pick = func(servers []*balancedServer, req *http.Request) *balancedServer {
+			return b.ring.get(hashKeyValue(key, req), triedHosts(req))
+		}
+
+		return b, nil
+	}
+
+	return nil, fmt.Errorf("unsupported load balancing algorithm: %s", backend.Algorithm)
+}
+
+// roundRobin adapts the oxy weighted round robin load balancer.
+type roundRobin struct {
+	*roundrobin.RoundRobin
+	next http.Handler
+}
+
+// ServeHTTP passes the request to the next server address. Retries skip
+// the addresses of earlier attempts, unless all addresses have been tried.
+func (r *roundRobin) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	tried := triedHosts(req)
+	if len(tried) == 0 {
+		r.RoundRobin.ServeHTTP(w, req)
+		return
+	}
+
+	// every address is returned within a round of the total weight
+	round := 0
+	for _, u := range r.Servers() {
+		weight, _ := r.ServerWeight(u)
+		round += weight
+	}
+
+	for i := 0; i < round; i++ {
+		u, err := r.NextServer()
+		if err != nil {
+			break
+		}
+
+		if tried[u.Host] {
+			continue
+		}
+
+		r2 := new(http.Request)
+		*r2 = *req
+		r2.URL = u
+
+		r.next.ServeHTTP(w, r2)
+
+		return
+	}
+
+	r.RoundRobin.ServeHTTP(w, req)
+}
+
+func (r *roundRobin) UpsertServer(u *url.URL, weight int) error {
+	return r.RoundRobin.UpsertServer(u, roundrobin.Weight(weight))
+}
+
+// balancer implements the load balancing algorithms which are not provided
+// by oxy. It tracks the number of requests in progress per server address.
+type balancer struct {
+	sync.RWMutex
+	next    http.Handler
+	pick    func(servers []*balancedServer, req *http.Request) *balancedServer
+	servers []*balancedServer
+	ring    *hashRing
+}
+
+type balancedServer struct {
+	url    *url.URL
+	weight int
+	active int64
+}
+
+func newBalancer(next http.Handler, pick func([]*balancedServer, *http.Request) *balancedServer) *balancer {
+	return &balancer{
+		next: next,
+		pick: pick,
+		ring: newHashRing(nil),
+	}
+}
+
+func (b *balancer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	b.RLock()
+	var s *balancedServer
+	if servers := untried(b.servers, triedHosts(req)); len(servers) > 0 {
+		s = b.pick(servers, req)
+	}
+	b.RUnlock()
+
+	if s == nil {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+
+	atomic.AddInt64(&s.active, 1)
+	defer atomic.AddInt64(&s.active, -1)
+
+	r2 := new(http.Request)
+	*r2 = *req
+	r2.URL = new(url.URL)
+	*r2.URL = *s.url
+
+	b.next.ServeHTTP(w, r2)
+}
+
+// UpsertServer adds the server address, or updates its weight.
+func (b *balancer) UpsertServer(u *url.URL, weight int) error {
+	b.Lock()
+	defer b.Unlock()
+
+	for _, s := range b.servers {
+		if s.url.String() == u.String() {
+			s.weight = weight
+			b.ring = newHashRing(b.servers)
+
+			return nil
+		}
+	}
+
+	b.servers = append(b.servers, &balancedServer{
+		url:    u,
+		weight: weight,
+	})
+	b.ring = newHashRing(b.servers)
+
+	return nil
+}
+
+// RemoveServer removes the server address.
+func (b *balancer) RemoveServer(u *url.URL) error {
+	b.Lock()
+	defer b.Unlock()
+
+	for i, s := range b.servers {
+		if s.url.String() == u.String() {
+			b.servers = append(b.servers[:i:i], b.servers[i+1:]...)
+			b.ring = newHashRing(b.servers)
+
+			return nil
+		}
+	}
+
+	return fmt.Errorf("server not found: %s", u)
+}
+
+// Servers returns the server addresses.
+func (b *balancer) Servers() []*url.URL {
+	b.RLock()
+	defer b.RUnlock()
+
+	urls := make([]*url.URL, len(b.servers))
+	for i, s := range b.servers {
+		urls[i] = s.url
+	}
+
+	return urls
+}
+
+// untried returns the servers to which no earlier attempt of the request
+// was forwarded, or all servers if they have all been tried.
+func untried(servers []*balancedServer, tried map[string]bool) []*balancedServer {
+	if len(tried) == 0 {
+		return servers
+	}
+
+	var filtered []*balancedServer
+	for _, s := range servers {
+		if !tried[s.url.Host] {
+			filtered = append(filtered, s)
+		}
+	}
+
+	if len(filtered) == 0 {
+		return servers
+	}
+
+	return filtered
+}
+
+// load returns the number of requests in progress relative to the weight.
+func (s *balancedServer) load() float64 {
+	return float64(atomic.LoadInt64(&s.active)) / float64(s.weight)
+}
+
+// leastConnections returns a function picking the server with the lowest
+// load. Ties are broken by starting the search at the next server each time.
+func leastConnections() func([]*balancedServer, *http.Request) *balancedServer {
+	var next uint32
+
+	return func(servers []*balancedServer, req *http.Request) *balancedServer {
+		start := int(atomic.AddUint32(&next, 1)) % len(servers)
+
+		best := servers[start]
+		for i := 1; i < len(servers); i++ {
+			s := servers[(start+i)%len(servers)]
+			if s.load() < best.load() {
+				best = s
+			}
+		}
+
+		return best
+	}
+}
+
+func pickPowerOfTwo(servers []*balancedServer, rnd *lockedRand) *balancedServer {
+	if len(servers) == 1 {
+		return servers[0]
+	}
+
+	i, j := rnd.twoDistinct(len(servers))
+
+	a, b := servers[i], servers[j]
+	if b.load() < a.load() {
+		return b
+	}
+
+	return a
+}
+
+// lockedRand is a source of random numbers safe for concurrent use.
+type lockedRand struct {
+	sync.Mutex
+	r *rand.Rand
+}
+
+// intn returns a random number in [0, n), n > 0.
+func (l *lockedRand) intn(n int) int {
+	l.Lock()
+	defer l.Unlock()
+
+	return l.r.Intn(n)
+}
+
+// float64 returns a random number in [0.0, 1.0).
+func (l *lockedRand) float64() float64 {
+	l.Lock()
+	defer l.Unlock()
+
+	return l.r.Float64()
+}
+
+// twoDistinct returns two distinct random numbers in [0, n), n > 1.
+func (l *lockedRand) twoDistinct(n int) (int, int) {
+	l.Lock()
+	defer l.Unlock()
+
+	i := l.r.Intn(n)
+	j := l.r.Intn(n - 1)
+	if j >= i {
+		j++
+	}
+
+	return i, j
+}
+
+// hashKeyValue returns the value of the hash key of the request. Requests
+// without a value, e.g. because the cookie is not set, use the client IP.
+func hashKeyValue(key *sites.HashKey, req *http.Request) string {
+	switch key.Source {
+	case sites.HashHeader:
+		if v := req.Header.Get(key.Name); v != "" {
+			return v
+		}
+	case sites.HashCookie:
+		if c, err := req.Cookie(key.Name); err == nil && c.Value != "" {
+			return c.Value
+		}
+	}
+
+	ip, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+
+	return ip
+}
+
+// hashRing maps hash keys to servers. Each server occupies a number of
+// points on the ring proportional to its weight. A key maps to the server
+// owning the first point at or after the hash of the key, skipping the
+// servers of earlier attempts of a retried request.
+type hashRing struct {
+	points  []uint32
+	servers map[uint32]*balancedServer
+}
+
+func newHashRing(servers []*balancedServer) *hashRing {
+	r := &hashRing{
+		servers: make(map[uint32]*balancedServer),
+	}
+
+	for _, s := range servers {
+		for i := 0; i < hashReplicas*s.weight; i++ {
+			point := crc32.ChecksumIEEE([]byte(s.url.Host + "#" + strconv.Itoa(i)))
+			if _, taken := r.servers[point]; taken {
+				continue
+			}
+
+			r.servers[point] = s
+			r.points = append(r.points, point)
+		}
+	}
+
+	sort.Slice(r.points, func(i, j int) bool {
+		return r.points[i] < r.points[j]
+	})
+
+	return r
+}
+
+func (r *hashRing) get(key string, tried map[string]bool) *balancedServer {
+	if len(r.points) < 1 {
+		return nil
+	}
+
+	hash := crc32.ChecksumIEEE([]byte(key))
+
+	start := sort.Search(len(r.points), func(i int) bool {
+		return r.points[i] >= hash
+	})
+
+	for i := 0; i < len(r.points); i++ {
+		s := r.servers[r.points[(start+i)%len(r.points)]]
+		if !tried[s.url.Host] {
+			return s
+		}
+	}
+
+	// all servers have been tried
+	return r.servers[r.points[start%len(r.points)]]
+}
@@ -0,0 +1,123 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/off-sync/platform-proxy/domain/sites"
+)
+
+// benchmarkServers holds the server set shared by the load balancer
+// benchmarks: ten addresses with weights 1 to 3.
+func benchmarkServers(b *testing.B) []*url.URL {
+	var servers []*url.URL
+	for i := 0; i < 10; i++ {
+		u, err := url.Parse(fmt.Sprintf("http://10.0.0.%d:8080", i+1))
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		servers = append(servers, u)
+	}
+
+	return servers
+}
+
+func newBenchmarkBalancer(b *testing.B, algorithm sites.Algorithm) loadBalancer {
+	backend := &sites.Backend{
+		Name:      "bench",
+		Algorithm: algorithm,
+		HashKey:   &sites.HashKey{Source: sites.HashHeader, Name: "X-User"},
+	}
+
+	// the selected server is not called
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	lb, err := newLoadBalancer(backend, next)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for i, u := range benchmarkServers(b) {
+		if err := lb.UpsertServer(u, i%3+1); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	return lb
+}
+
+// newBenchmarkRequests returns requests with different hash keys.
+func newBenchmarkRequests() []*http.Request {
+	reqs := make([]*http.Request, 64)
+	for i := range reqs {
+		reqs[i] = httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+		reqs[i].Header.Set("X-User", fmt.Sprintf("user-%d", i))
+	}
+
+	return reqs
+}
+
+func benchmarkBalancer(b *testing.B, algorithm sites.Algorithm) {
+	lb := newBenchmarkBalancer(b, algorithm)
+	reqs := newBenchmarkRequests()
+	w := httptest.NewRecorder()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		lb.ServeHTTP(w, reqs[i%len(reqs)])
+	}
+}
+
+func benchmarkBalancerParallel(b *testing.B, algorithm sites.Algorithm) {
+	lb := newBenchmarkBalancer(b, algorithm)
+	reqs := newBenchmarkRequests()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		w := httptest.NewRecorder()
+
+		for i := 0; pb.Next(); i++ {
+			lb.ServeHTTP(w, reqs[i%len(reqs)])
+		}
+	})
+}
+
+func BenchmarkRoundRobin(b *testing.B) {
+	benchmarkBalancer(b, sites.RoundRobin)
+}
+
+func BenchmarkLeastConnections(b *testing.B) {
+	benchmarkBalancer(b, sites.LeastConnections)
+}
+
+func BenchmarkPowerOfTwoChoices(b *testing.B) {
+	benchmarkBalancer(b, sites.PowerOfTwoChoices)
+}
+
+func BenchmarkConsistentHash(b *testing.B) {
+	benchmarkBalancer(b, sites.ConsistentHash)
+}
+
+func BenchmarkRoundRobinParallel(b *testing.B) {
+	benchmarkBalancerParallel(b, sites.RoundRobin)
+}
+
+func BenchmarkLeastConnectionsParallel(b *testing.B) {
+	benchmarkBalancerParallel(b, sites.LeastConnections)
+}
+
+func BenchmarkPowerOfTwoChoicesParallel(b *testing.B) {
+	benchmarkBalancerParallel(b, sites.PowerOfTwoChoices)
+}
+
+func BenchmarkConsistentHashParallel(b *testing.B) {
+	benchmarkBalancerParallel(b, sites.ConsistentHash)
+}
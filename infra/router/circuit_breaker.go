@@ -0,0 +1,375 @@
+package router
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/off-sync/platform-proxy/app/interfaces"
+	"github.com/off-sync/platform-proxy/domain/sites"
+)
+
+// Circuit states of a server address.
+const (
+	circuitClosed   = "closed"
+	circuitOpen     = "open"
+	circuitHalfOpen = "half-open"
+)
+
+// CircuitState holds the circuit breaker state of a single server address.
+type CircuitState struct {
+	Backend string `json:"backend"`
+	Addr    string `json:"addr"`
+	State   string `json:"state"`
+	Errors  int    `json:"errors"`
+	// OpenUntil holds the time at which an open circuit is half-opened.
+	OpenUntil time.Time `json:"open_until,omitempty"`
+}
+
+// circuitBreaker records the outcome of requests forwarded to each server
+// address of a backend. Addresses returning consecutive errors are ejected
+// from the load balancer, until probe requests show they have recovered.
+// Probes bypass the load balancer, so a half-open address stays ejected
+// and only receives the limited number of probes.
+type circuitBreaker struct {
+	backend  string
+	settings *sites.CircuitBreaker
+	pool     *serverPool
+	log      interfaces.Logger
+	next     http.Handler
+
+	sync.Mutex
+	circuits map[string]*circuit
+	closed   bool
+}
+
+// circuit holds the state of a server address.
+type circuit struct {
+	addr      *url.URL
+	state     string
+	errors    int
+	successes int
+	openUntil time.Time
+	// probes holds the number of probe requests in progress.
+	probes int32
+}
+
+// newCircuitBreaker creates a circuit breaker which passes requests to the
+// next handler, which forwards them to the server address in the request
+// URL as set by the load balancer.
+func newCircuitBreaker(backend *sites.Backend, pool *serverPool, next http.Handler, log interfaces.Logger) *circuitBreaker {
+	return &circuitBreaker{
+		backend:  backend.Name,
+		settings: backend.CircuitBreaker.WithDefaults(),
+		pool:     pool,
+		log:      log.WithField("backend", backend.Name),
+		next:     next,
+		circuits: make(map[string]*circuit),
+	}
+}
+
+// add adds a server address with a closed circuit.
+func (b *circuitBreaker) add(addr *url.URL) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.circuits[addr.Host] = &circuit{
+		addr:  addr,
+		state: circuitClosed,
+	}
+}
+
+// remove removes the circuit of a server address.
+func (b *circuitBreaker) remove(addr *url.URL) {
+	b.Lock()
+	defer b.Unlock()
+
+	delete(b.circuits, addr.Host)
+}
+
+// close stops half-opening circuits.
+func (b *circuitBreaker) close() {
+	b.Lock()
+	b.closed = true
+	b.Unlock()
+}
+
+func (b *circuitBreaker) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	rec := &statusRecorder{ResponseWriter: w}
+
+	b.next.ServeHTTP(rec, req)
+
+	b.record(req.URL.Host, rec.status < http.StatusInternalServerError)
+}
+
+// probe forwards the request to the address of a half-open circuit, if it
+// admits another probe. Only requests with a safe method probe a server
+// which may still be broken, and upgrade requests are excluded as they would
+// hold the probe for the lifetime of the connection. It returns false if the
+// request was not forwarded.
+func (b *circuitBreaker) probe(w http.ResponseWriter, req *http.Request) bool {
+	if !isSafeMethod(req.Method) || isUpgradeRequest(req) {
+		return false
+	}
+
+	c := b.admitProbe()
+	if c == nil {
+		return false
+	}
+	defer atomic.AddInt32(&c.probes, -1)
+
+	r2 := new(http.Request)
+	*r2 = *req
+	r2.URL = new(url.URL)
+	*r2.URL = *c.addr
+
+	rec := &statusRecorder{ResponseWriter: w}
+
+	b.next.ServeHTTP(rec, r2)
+
+	b.recordProbe(c, rec.status < http.StatusInternalServerError)
+
+	return true
+}
+
+// isSafeMethod returns whether requests with the method do not change the
+// state of the server, unlike idempotent methods such as PUT and DELETE.
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+
+	return false
+}
+
+// admitProbe returns a half-open circuit with fewer than HalfOpenRequests
+// probes in progress, after counting the new probe.
+func (b *circuitBreaker) admitProbe() *circuit {
+	b.Lock()
+	defer b.Unlock()
+
+	for _, c := range b.circuits {
+		if c.state != circuitHalfOpen || !b.pool.isOnlyExcluded(c.addr, excludedEjected) {
+			// an unhealthy server is not probed
+			continue
+		}
+
+		if atomic.AddInt32(&c.probes, 1) <= int32(b.settings.HalfOpenRequests) {
+			return c
+		}
+
+		atomic.AddInt32(&c.probes, -1)
+	}
+
+	return nil
+}
+
+// record records the outcome of a request forwarded to the address by the
+// load balancer.
+func (b *circuitBreaker) record(host string, success bool) {
+	b.Lock()
+	defer b.Unlock()
+
+	c, found := b.circuits[host]
+	if !found || c.state != circuitClosed {
+		// requests in progress while the circuit was opened
+		return
+	}
+
+	if success {
+		c.errors = 0
+		return
+	}
+
+	c.errors++
+
+	if c.errors >= b.settings.Errors {
+		b.open(c)
+	}
+}
+
+// recordProbe records the outcome of a probe. The circuit is closed after
+// HalfOpenRequests successful probes, and opened again by the first failed
+// probe.
+func (b *circuitBreaker) recordProbe(c *circuit, success bool) {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.circuits[c.addr.Host] != c || c.state != circuitHalfOpen {
+		// removed, or opened by another probe
+		return
+	}
+
+	if !success {
+		c.errors++
+		b.open(c)
+
+		return
+	}
+
+	c.successes++
+
+	if c.successes < b.settings.HalfOpenRequests {
+		return
+	}
+
+	c.state = circuitClosed
+
+	log := b.log.WithField("addr", c.addr.Host)
+
+	log.Info("circuit closed: server recovered")
+
+	if err := b.pool.include(c.addr, excludedEjected); err != nil {
+		log.WithError(err).Error("restoring server")
+	}
+}
+
+// open ejects the server address from the load balancer, and schedules
+// half-opening its circuit.
+func (b *circuitBreaker) open(c *circuit) {
+	wasClosed := c.state == circuitClosed
+
+	c.state = circuitOpen
+	c.successes = 0
+	c.openUntil = time.Now().Add(b.settings.EjectDuration)
+
+	log := b.log.
+		WithField("addr", c.addr.Host).
+		WithField("errors", c.errors)
+
+	log.Warn("circuit opened: ejecting server")
+
+	if wasClosed {
+		// a half-open server is still ejected
+		if err := b.pool.exclude(c.addr, excludedEjected); err != nil {
+			log.WithError(err).Error("ejecting server")
+		}
+	}
+
+	time.AfterFunc(b.settings.EjectDuration, func() {
+		b.halfOpen(c)
+	})
+}
+
+// halfOpen allows probe requests to the server address, to find out
+// whether it has recovered. It stays ejected from the load balancer.
+func (b *circuitBreaker) halfOpen(c *circuit) {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.closed || b.circuits[c.addr.Host] != c {
+		// closed, or the address has been removed
+		return
+	}
+
+	c.state = circuitHalfOpen
+	c.errors = 0
+	c.openUntil = time.Time{}
+
+	b.log.
+		WithField("addr", c.addr.Host).
+		Info("circuit half-opened: probing server")
+}
+
+// states returns the circuit states of all server addresses, ordered by
+// address.
+func (b *circuitBreaker) states() []*CircuitState {
+	b.Lock()
+	defer b.Unlock()
+
+	var states []*CircuitState
+	for _, c := range b.circuits {
+		states = append(states, &CircuitState{
+			Backend:   b.backend,
+			Addr:      c.addr.Host,
+			State:     c.state,
+			Errors:    c.errors,
+			OpenUntil: c.openUntil,
+		})
+	}
+
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Addr < states[j].Addr
+	})
+
+	return states
+}
+
+// fallback passes requests to the half-open circuits of the circuit breaker
+// as probes, or otherwise to the load balancer. It returns the fallback
+// response of the circuit breaker when the load balancer has no server
+// addresses left. Probes are not retried.
+func fallback(cb *circuitBreaker, pool *serverPool, lb http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if cb.probe(w, req) {
+			return
+		}
+
+		if !pool.isEmpty() {
+			lb.ServeHTTP(w, req)
+			return
+		}
+
+		w.Header().Set("Content-Type", cb.settings.FallbackContentType)
+		w.WriteHeader(cb.settings.FallbackStatus)
+		io.WriteString(w, cb.settings.FallbackBody)
+	})
+}
+
+// statusRecorder records the status code of a response. It supports
+// flushing and hijacking, for streaming and upgraded connections.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+
+	return r.ResponseWriter.Write(p)
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+
+	if r.status == 0 {
+		r.status = http.StatusSwitchingProtocols
+	}
+
+	return hj.Hijack()
+}
+
+func (r *statusRecorder) CloseNotify() <-chan bool {
+	if cn, ok := r.ResponseWriter.(http.CloseNotifier); ok {
+		return cn.CloseNotify()
+	}
+
+	return make(chan bool)
+}
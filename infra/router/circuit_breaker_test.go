@@ -0,0 +1,222 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/off-sync/platform-proxy/domain/sites"
+)
+
+// flakyServer fails while failing is set, and otherwise answers once
+// release is closed.
+type flakyServer struct {
+	failing int32
+	hits    int32
+	release chan struct{}
+}
+
+func (s *flakyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt32(&s.hits, 1)
+
+	if atomic.LoadInt32(&s.failing) == 1 {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	<-s.release
+}
+
+func newCircuitBreakerTestRouter(t *testing.T, server *flakyServer) *Router {
+	srv := httptest.NewServer(server)
+	t.Cleanup(srv.Close)
+
+	backend := newTestBackend(t, "flaky", srv.URL)
+	backend.CircuitBreaker = &sites.CircuitBreaker{
+		Errors:           2,
+		EjectDuration:    50 * time.Millisecond,
+		HalfOpenRequests: 2,
+		FallbackStatus:   http.StatusServiceUnavailable,
+	}
+
+	// upgrades are allowed, so they reach the circuit breaker
+	frontend := sites.NewFrontend("flaky", "example.com")
+	frontend.Upgrade = &sites.Upgrade{}
+
+	return newTestRouter(t, []*sites.Backend{backend}, []*sites.Frontend{frontend})
+}
+
+func serve(rtr http.Handler) int {
+	w := httptest.NewRecorder()
+	rtr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+
+	return w.Code
+}
+
+func circuitState(t *testing.T, rtr *Router) string {
+	states := rtr.CircuitStates()
+	if len(states) != 1 {
+		t.Fatalf("expected 1 circuit, got %d", len(states))
+	}
+
+	return states[0].State
+}
+
+// openCircuit makes the server fail until its circuit opens, and waits for
+// the circuit to be half-opened.
+func openCircuit(t *testing.T, rtr *Router, server *flakyServer) {
+	atomic.StoreInt32(&server.failing, 1)
+
+	for i := 0; i < 2; i++ {
+		if status := serve(rtr); status != http.StatusInternalServerError {
+			t.Fatalf("expected status 500, got %d", status)
+		}
+	}
+
+	if state := circuitState(t, rtr); state != circuitOpen {
+		t.Fatalf("expected circuit to be open, got %s", state)
+	}
+
+	hits := atomic.LoadInt32(&server.hits)
+	if status := serve(rtr); status != http.StatusServiceUnavailable {
+		t.Fatalf("expected fallback status 503, got %d", status)
+	}
+
+	if atomic.LoadInt32(&server.hits) != hits {
+		t.Fatal("expected an open circuit to receive no requests")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if state := circuitState(t, rtr); state != circuitHalfOpen {
+		t.Fatalf("expected circuit to be half-open, got %s", state)
+	}
+}
+
+func TestCircuitBreakerLimitsProbes(t *testing.T) {
+	server := &flakyServer{release: make(chan struct{})}
+	rtr := newCircuitBreakerTestRouter(t, server)
+
+	openCircuit(t, rtr, server)
+
+	atomic.StoreInt32(&server.failing, 0)
+	atomic.StoreInt32(&server.hits, 0)
+
+	var wg sync.WaitGroup
+	statuses := make([]int, 2)
+	for i := range statuses {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			statuses[i] = serve(rtr)
+		}(i)
+	}
+
+	for atomic.LoadInt32(&server.hits) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// both probes are in progress: the server is not used for others
+	if status := serve(rtr); status != http.StatusServiceUnavailable {
+		t.Errorf("expected fallback status 503 while probing, got %d", status)
+	}
+
+	if hits := atomic.LoadInt32(&server.hits); hits != 2 {
+		t.Errorf("expected 2 probes, got %d", hits)
+	}
+
+	close(server.release)
+	wg.Wait()
+
+	for _, status := range statuses {
+		if status != http.StatusOK {
+			t.Errorf("expected probe status 200, got %d", status)
+		}
+	}
+
+	if state := circuitState(t, rtr); state != circuitClosed {
+		t.Fatalf("expected circuit to be closed, got %s", state)
+	}
+
+	if status := serve(rtr); status != http.StatusOK {
+		t.Errorf("expected a restored server to be used, got %d", status)
+	}
+}
+
+func TestCircuitBreakerReopensOnFailedProbe(t *testing.T) {
+	server := &flakyServer{release: make(chan struct{})}
+	close(server.release)
+
+	rtr := newCircuitBreakerTestRouter(t, server)
+
+	openCircuit(t, rtr, server)
+
+	if status := serve(rtr); status != http.StatusInternalServerError {
+		t.Fatalf("expected failed probe with status 500, got %d", status)
+	}
+
+	if state := circuitState(t, rtr); state != circuitOpen {
+		t.Fatalf("expected circuit to be open again, got %s", state)
+	}
+
+	atomic.StoreInt32(&server.failing, 0)
+	time.Sleep(100 * time.Millisecond)
+
+	for i := 0; i < 2; i++ {
+		if status := serve(rtr); status != http.StatusOK {
+			t.Fatalf("expected probe status 200, got %d", status)
+		}
+	}
+
+	if state := circuitState(t, rtr); state != circuitClosed {
+		t.Fatalf("expected circuit to be closed, got %s", state)
+	}
+}
+
+func TestCircuitBreakerOnlyProbesWithSafeRequests(t *testing.T) {
+	server := &flakyServer{release: make(chan struct{})}
+	close(server.release)
+
+	rtr := newCircuitBreakerTestRouter(t, server)
+
+	openCircuit(t, rtr, server)
+
+	atomic.StoreInt32(&server.failing, 0)
+	hits := atomic.LoadInt32(&server.hits)
+
+	post := httptest.NewRequest(http.MethodPost, "http://example.com/", nil)
+
+	upgrade := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	upgrade.Header.Set("Connection", "Upgrade")
+	upgrade.Header.Set("Upgrade", "websocket")
+
+	for _, req := range []*http.Request{post, upgrade} {
+		w := httptest.NewRecorder()
+		rtr.ServeHTTP(w, req)
+
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s: expected fallback status 503, got %d", req.Method, w.Code)
+		}
+	}
+
+	if atomic.LoadInt32(&server.hits) != hits {
+		t.Fatal("expected unsafe and upgrade requests not to probe the server")
+	}
+
+	if state := circuitState(t, rtr); state != circuitHalfOpen {
+		t.Fatalf("expected circuit to stay half-open, got %s", state)
+	}
+
+	for i := 0; i < 2; i++ {
+		if status := serve(rtr); status != http.StatusOK {
+			t.Fatalf("expected probe status 200, got %d", status)
+		}
+	}
+
+	if state := circuitState(t, rtr); state != circuitClosed {
+		t.Fatalf("expected circuit to be closed, got %s", state)
+	}
+}
@@ -0,0 +1,230 @@
+package router
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/off-sync/platform-proxy/app/interfaces"
+	"github.com/off-sync/platform-proxy/domain/sites"
+)
+
+// Headers passing the identity of the verified client certificate to the
+// backend. They are removed from all requests, so clients cannot set them
+// for frontends with or without client authentication.
+const (
+	headerClientCertCN          = "X-Client-Cert-CN"
+	headerClientCertSAN         = "X-Client-Cert-SAN"
+	headerClientCertSerial      = "X-Client-Cert-Serial"
+	headerClientCertFingerprint = "X-Client-Cert-Fingerprint"
+)
+
+var clientCertHeaders = []string{
+	headerClientCertCN,
+	headerClientCertSAN,
+	headerClientCertSerial,
+	headerClientCertFingerprint,
+}
+
+// clientAuthPolicy verifies the client certificates of a frontend.
+type clientAuthPolicy struct {
+	matchHost func(host string) bool
+	cas       []*x509.Certificate
+	roots     *x509.CertPool
+	required  bool
+	patterns  []*regexp.Regexp
+	log       interfaces.Logger
+}
+
+// frontendHost matches the hosts of a frontend, and holds its client
+// authentication policy, which is nil if it does not authenticate clients.
+type frontendHost struct {
+	matchHost  func(host string) bool
+	clientAuth *clientAuthPolicy
+}
+
+func newClientAuthPolicy(frontend *sites.Frontend, matchHost func(host string) bool, log interfaces.Logger) (*clientAuthPolicy, error) {
+	settings := frontend.ClientAuth
+
+	caPEM, err := ioutil.ReadFile(settings.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading CA file: %s", err)
+	}
+
+	p := &clientAuthPolicy{
+		matchHost: matchHost,
+		roots:     x509.NewCertPool(),
+		required:  settings.Mode == sites.ClientAuthRequired,
+		log:       log.WithField("frontend", frontend.Domain),
+	}
+
+	for {
+		var block *pem.Block
+		block, caPEM = pem.Decode(caPEM)
+		if block == nil {
+			break
+		}
+
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		ca, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing CA certificate: %s", err)
+		}
+
+		p.cas = append(p.cas, ca)
+		p.roots.AddCert(ca)
+	}
+
+	if len(p.cas) < 1 {
+		return nil, fmt.Errorf("no certificates found in CA file %s", settings.CAFile)
+	}
+
+	p.patterns, err = compileAllowedPatterns(settings.AllowedPatterns)
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// compileAllowedPatterns compiles the patterns anchored at both ends, as a
+// pattern must match the whole name: "client" does not allow "not-a-client".
+func compileAllowedPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	var compiled []*regexp.Regexp
+	for _, pattern := range patterns {
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed pattern %s: %s", pattern, err)
+		}
+
+		compiled = append(compiled, re)
+	}
+
+	return compiled, nil
+}
+
+// verify returns the client certificate of the request if it is issued by
+// one of the CAs and allowed by the patterns. It returns nil if the client
+// did not present a certificate.
+func (p *clientAuthPolicy) verify(r *http.Request) (*x509.Certificate, error) {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) < 1 {
+		return nil, nil
+	}
+
+	// the TLS handshake accepts the CAs of all frontends matching the
+	// server name: verify against the CAs of this frontend
+	cert := r.TLS.PeerCertificates[0]
+
+	intermediates := x509.NewCertPool()
+	for _, c := range r.TLS.PeerCertificates[1:] {
+		intermediates.AddCert(c)
+	}
+
+	_, err := cert.Verify(x509.VerifyOptions{
+		Roots:         p.roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.allows(cert) {
+		return nil, fmt.Errorf("client certificate of %s not allowed", cert.Subject.CommonName)
+	}
+
+	return cert, nil
+}
+
+// allows returns whether the common name or a subject alternative name
+// of the certificate fully matches one of the patterns, or if there are none.
+func (p *clientAuthPolicy) allows(cert *x509.Certificate) bool {
+	if len(p.patterns) < 1 {
+		return true
+	}
+
+	names := append([]string{cert.Subject.CommonName}, subjectAltNames(cert)...)
+
+	for _, re := range p.patterns {
+		for _, name := range names {
+			if re.MatchString(name) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func subjectAltNames(cert *x509.Certificate) []string {
+	var names []string
+	names = append(names, cert.DNSNames...)
+	names = append(names, cert.EmailAddresses...)
+
+	return names
+}
+
+// requireClientCert passes requests with an allowed client certificate to
+// the next handler, adding headers identifying the client. Requests without
+// a certificate are only passed if the certificate is optional.
+func requireClientCert(p *clientAuthPolicy, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cert, err := p.verify(r)
+		if cert != nil {
+			setClientCertHeaders(r.Header, cert)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if err == nil && !p.required {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.TLS != nil && !p.matchHost(r.TLS.ServerName) {
+			// the connection was set up for another server name, e.g. a
+			// reused HTTP/2 connection: the client must open a new one
+			http.Error(w, http.StatusText(http.StatusMisdirectedRequest), http.StatusMisdirectedRequest)
+			return
+		}
+
+		if err != nil {
+			p.log.
+				WithField("host", r.Host).
+				WithField("remote_addr", r.RemoteAddr).
+				WithError(err).
+				Info("rejecting client certificate")
+
+			http.Error(w, "client certificate not allowed", http.StatusForbidden)
+			return
+		}
+
+		http.Error(w, "client certificate required", http.StatusForbidden)
+	})
+}
+
+// removeClientCertHeaders removes the client certificate headers set by
+// the client.
+func removeClientCertHeaders(h http.Header) {
+	for _, name := range clientCertHeaders {
+		h.Del(name)
+	}
+}
+
+func setClientCertHeaders(h http.Header, cert *x509.Certificate) {
+	fingerprint := sha256.Sum256(cert.Raw)
+
+	h.Set(headerClientCertCN, cert.Subject.CommonName)
+	h.Set(headerClientCertSAN, strings.Join(subjectAltNames(cert), ","))
+	h.Set(headerClientCertSerial, cert.SerialNumber.Text(16))
+	h.Set(headerClientCertFingerprint, hex.EncodeToString(fingerprint[:]))
+}
@@ -0,0 +1,71 @@
+package router
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/off-sync/platform-proxy/domain/sites"
+)
+
+func TestAllowedPatternsMatchWholeNames(t *testing.T) {
+	patterns, err := compileAllowedPatterns([]string{"client", `.*\.example\.com|admin`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &clientAuthPolicy{patterns: patterns}
+
+	tests := []struct {
+		name     string
+		expected bool
+	}{
+		{"client", true},
+		{"not-a-client", false},
+		{"client.evil.org", false},
+		{"api.example.com", true},
+		{"api.example.com.evil.org", false},
+		{"admin", true},
+		{"administrator", false},
+	}
+
+	for _, test := range tests {
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: test.name}}
+
+		if actual := p.allows(cert); actual != test.expected {
+			t.Errorf("%s: expected allowed %v, got %v", test.name, test.expected, actual)
+		}
+	}
+}
+
+func TestClientCertHeadersRemovedWithoutClientAuth(t *testing.T) {
+	var received http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header
+	}))
+	defer srv.Close()
+
+	rtr := newTestRouter(t, []*sites.Backend{newTestBackend(t, "www", srv.URL)}, []*sites.Frontend{
+		sites.NewFrontend("www", "example.com"),
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	for _, h := range clientCertHeaders {
+		req.Header.Set(h, "forged")
+	}
+
+	w := httptest.NewRecorder()
+	rtr.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	for _, h := range clientCertHeaders {
+		if v := received.Get(h); v != "" {
+			t.Errorf("expected %s to be removed, got %q", h, v)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package router
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/off-sync/platform-proxy/app/interfaces"
+)
+
+const (
+	// gRPC status code returned when the deadline of the client expired.
+	grpcDeadlineExceeded = 4
+
+	// gRPC status code returned when the server could not be reached.
+	grpcUnavailable = 14
+)
+
+// hopHeaders are removed from forwarded requests and responses. TE is not
+// included: gRPC requires 'TE: trailers' to reach the server.
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// grpcForwarder forwards gRPC requests to the server selected by the load
+// balancer. Unlike the oxy forwarder it streams request and response bodies
+// without buffering and propagates trailers, which carry the gRPC status.
+type grpcForwarder struct {
+	transport http.RoundTripper
+	log       interfaces.Logger
+}
+
+func newGRPCForwarder(transport http.RoundTripper, log interfaces.Logger) *grpcForwarder {
+	return &grpcForwarder{
+		transport: transport,
+		log:       log,
+	}
+}
+
+// ServeHTTP forwards the request to the server in the request URL, which
+// is set by the load balancer. The path is taken from the request URI.
+func (f *grpcForwarder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	u, err := url.ParseRequestURI(req.RequestURI)
+	if err != nil {
+		writeGRPCError(w, grpcUnavailable, "invalid request URI")
+		return
+	}
+
+	u.Scheme = req.URL.Scheme
+	u.Host = req.URL.Host
+
+	ctx := req.Context()
+	if timeout, ok := parseGRPCTimeout(req.Header.Get("Grpc-Timeout")); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	outReq := req.WithContext(ctx)
+	outReq.URL = u
+	outReq.RequestURI = ""
+	outReq.Header = copyHeader(make(http.Header), req.Header)
+	removeHopHeaders(outReq.Header)
+
+	if req.ContentLength == 0 {
+		outReq.Body = nil
+	}
+
+	resp, err := f.transport.RoundTrip(outReq)
+	if err != nil {
+		f.log.
+			WithError(err).
+			WithField("server", u.Host).
+			Warn("forwarding gRPC request")
+
+		writeGRPCError(w, grpcErrorCode(ctx), err.Error())
+		return
+	}
+	defer resp.Body.Close()
+
+	copyHeader(w.Header(), resp.Header)
+	removeHopHeaders(w.Header())
+	w.WriteHeader(resp.StatusCode)
+
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+
+	if err := copyStream(w, resp.Body, flusher); err != nil {
+		f.log.
+			WithError(err).
+			WithField("server", u.Host).
+			Warn("streaming gRPC response")
+
+		// the server did not end the response: end it with a status
+		// instead of its trailers
+		w.Header().Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(grpcErrorCode(ctx)))
+		w.Header().Set(http.TrailerPrefix+"Grpc-Message", url.PathEscape(err.Error()))
+		return
+	}
+
+	// trailers are only known after the body has been read completely
+	for k, vv := range resp.Trailer {
+		for _, v := range vv {
+			w.Header().Add(http.TrailerPrefix+k, v)
+		}
+	}
+}
+
+// grpcErrorCode returns the gRPC status code of a request to the server
+// which failed: DEADLINE_EXCEEDED if the grpc-timeout of the client
+// expired, UNAVAILABLE otherwise.
+func grpcErrorCode(ctx context.Context) int {
+	if ctx.Err() == context.DeadlineExceeded {
+		return grpcDeadlineExceeded
+	}
+
+	return grpcUnavailable
+}
+
+// copyStream copies src to dst, flushing after every write so that
+// streamed messages are delivered immediately.
+func copyStream(dst io.Writer, src io.Reader, flusher http.Flusher) error {
+	buf := make([]byte, 32*1024)
+
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			if _, werr := dst.Write(buf[:n]); werr != nil {
+				return werr
+			}
+
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// writeGRPCError writes a trailers-only gRPC response with the provided
+// status code and message.
+func writeGRPCError(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Grpc-Status", strconv.Itoa(code))
+	w.Header().Set("Grpc-Message", url.PathEscape(msg))
+	w.WriteHeader(http.StatusOK)
+}
+
+// parseGRPCTimeout parses the value of a grpc-timeout header: at most 8
+// digits followed by a unit (H, M, S, m, u or n).
+func parseGRPCTimeout(value string) (time.Duration, bool) {
+	if len(value) < 2 || len(value) > 9 {
+		return 0, false
+	}
+
+	units := map[byte]time.Duration{
+		'H': time.Hour,
+		'M': time.Minute,
+		'S': time.Second,
+		'm': time.Millisecond,
+		'u': time.Microsecond,
+		'n': time.Nanosecond,
+	}
+
+	unit, ok := units[value[len(value)-1]]
+	if !ok {
+		return 0, false
+	}
+
+	n, err := strconv.ParseInt(value[:len(value)-1], 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+
+	return time.Duration(n) * unit, true
+}
+
+func copyHeader(dst, src http.Header) http.Header {
+	for k, vv := range src {
+		for _, v := range vv {
+			dst.Add(k, v)
+		}
+	}
+
+	return dst
+}
+
+func removeHopHeaders(h http.Header) {
+	for _, c := range h["Connection"] {
+		for _, k := range strings.Split(c, ",") {
+			h.Del(strings.TrimSpace(k))
+		}
+	}
+
+	for _, k := range hopHeaders {
+		h.Del(k)
+	}
+}
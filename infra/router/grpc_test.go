@@ -0,0 +1,233 @@
+package router
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/off-sync/platform-proxy/domain/sites"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// grpcFrame encodes a gRPC length-prefixed message.
+func grpcFrame(msg string) []byte {
+	frame := make([]byte, 5+len(msg))
+	binary.BigEndian.PutUint32(frame[1:5], uint32(len(msg)))
+	copy(frame[5:], msg)
+
+	return frame
+}
+
+// readGRPCFrame decodes a gRPC length-prefixed message.
+func readGRPCFrame(r io.Reader) (string, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return "", err
+	}
+
+	msg := make([]byte, binary.BigEndian.Uint32(header[1:5]))
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return "", err
+	}
+
+	return string(msg), nil
+}
+
+// newGRPCServer returns a h2c server implementing a test service:
+//
+//	Unary:  echoes the request message
+//	Stream: sends the request message and 'done', the latter only after
+//	        release is closed
+//	Fail:   responds with status NOT_FOUND
+//	Slow:   sends the response headers and waits for the request to end
+//	Hang:   waits for the request to end without sending a response
+func newGRPCServer(t *testing.T, release <-chan struct{}) *httptest.Server {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 || r.Header.Get("Te") != "trailers" {
+			t.Errorf("expected HTTP/2 request with TE: trailers, got %s, TE %q", r.Proto, r.Header.Get("Te"))
+		}
+
+		msg, err := readGRPCFrame(r.Body)
+		if err != nil {
+			t.Errorf("reading request message: %s", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+
+		switch r.URL.Path {
+		case "/test.Echo/Unary":
+			w.Write(grpcFrame(msg))
+		case "/test.Echo/Stream":
+			w.Write(grpcFrame(msg))
+			w.(http.Flusher).Flush()
+
+			select {
+			case <-release:
+			case <-time.After(5 * time.Second):
+				t.Error("first streamed message not received by the client")
+			}
+
+			w.Write(grpcFrame("done"))
+		case "/test.Echo/Fail":
+			w.Header().Set("Grpc-Status", "5")
+			w.Header().Set("Grpc-Message", "not found")
+			return
+		case "/test.Echo/Slow":
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return
+		case "/test.Echo/Hang":
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+
+		w.Header().Set("Grpc-Status", "0")
+	})
+
+	return httptest.NewServer(h2c.NewHandler(handler, &http2.Server{}))
+}
+
+// newH2CClient returns a client speaking HTTP/2 over plain TCP.
+func newH2CClient() *http.Client {
+	return &http.Client{
+		Transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+				return net.Dial(network, addr)
+			},
+		},
+	}
+}
+
+// grpcStatus returns the gRPC status and message of a response, which
+// are sent as trailers or, for trailers-only responses, as headers.
+func grpcStatus(resp *http.Response) (string, string) {
+	if status := resp.Trailer.Get("Grpc-Status"); status != "" {
+		return status, resp.Trailer.Get("Grpc-Message")
+	}
+
+	return resp.Header.Get("Grpc-Status"), resp.Header.Get("Grpc-Message")
+}
+
+func TestGRPCForwarding(t *testing.T) {
+	release := make(chan struct{})
+	grpcSrv := newGRPCServer(t, release)
+	defer grpcSrv.Close()
+
+	backend := newTestBackend(t, "grpc", strings.Replace(grpcSrv.URL, "http://", "grpc://", 1))
+	rtr := newTestRouter(t, []*sites.Backend{backend}, []*sites.Frontend{
+		sites.NewFrontend("grpc", "example.com"),
+	})
+
+	proxy := httptest.NewServer(h2c.NewHandler(rtr, &http2.Server{}))
+	defer proxy.Close()
+
+	client := newH2CClient()
+
+	call := func(method, timeout string) *http.Response {
+		req, err := http.NewRequest(http.MethodPost, proxy.URL+"/test.Echo/"+method, bytes.NewReader(grpcFrame("hello")))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Host = "example.com"
+		req.Header.Set("Content-Type", "application/grpc")
+		req.Header.Set("Te", "trailers")
+		if timeout != "" {
+			req.Header.Set("Grpc-Timeout", timeout)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("%s: %s", method, err)
+		}
+
+		return resp
+	}
+
+	t.Run("unary", func(t *testing.T) {
+		resp := call("Unary", "")
+		defer resp.Body.Close()
+
+		if msg, err := readGRPCFrame(resp.Body); err != nil || msg != "hello" {
+			t.Fatalf("expected message hello, got %q (%v)", msg, err)
+		}
+
+		io.Copy(ioutil.Discard, resp.Body)
+
+		if status, _ := grpcStatus(resp); status != "0" {
+			t.Errorf("expected status 0, got %q", status)
+		}
+	})
+
+	t.Run("server-streaming", func(t *testing.T) {
+		resp := call("Stream", "")
+		defer resp.Body.Close()
+
+		// the second message is only sent once the first one arrived
+		if msg, err := readGRPCFrame(resp.Body); err != nil || msg != "hello" {
+			t.Fatalf("expected message hello, got %q (%v)", msg, err)
+		}
+
+		close(release)
+
+		if msg, err := readGRPCFrame(resp.Body); err != nil || msg != "done" {
+			t.Fatalf("expected message done, got %q (%v)", msg, err)
+		}
+
+		io.Copy(ioutil.Discard, resp.Body)
+
+		if status, _ := grpcStatus(resp); status != "0" {
+			t.Errorf("expected status 0, got %q", status)
+		}
+	})
+
+	t.Run("status-and-message", func(t *testing.T) {
+		resp := call("Fail", "")
+		defer resp.Body.Close()
+
+		io.Copy(ioutil.Discard, resp.Body)
+
+		if status, msg := grpcStatus(resp); status != "5" || msg != "not found" {
+			t.Errorf("expected status 5 with message 'not found', got %q %q", status, msg)
+		}
+	})
+
+	for _, method := range []string{"Slow", "Hang"} {
+		t.Run("timeout-"+method, func(t *testing.T) {
+			start := time.Now()
+
+			resp := call(method, "100m")
+			defer resp.Body.Close()
+
+			io.Copy(ioutil.Discard, resp.Body)
+
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("expected the call to be cut off after 100ms, took %s", elapsed)
+			}
+
+			if status, _ := grpcStatus(resp); status != "4" {
+				t.Errorf("expected status 4 (DEADLINE_EXCEEDED), got %q", status)
+			}
+		})
+	}
+}
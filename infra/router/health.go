@@ -0,0 +1,276 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/off-sync/platform-proxy/app/interfaces"
+	"github.com/off-sync/platform-proxy/domain/sites"
+)
+
+// ServerHealth holds the health state of a single server address.
+type ServerHealth struct {
+	Backend   string    `json:"backend"`
+	Server    string    `json:"server"`
+	Addr      string    `json:"addr"`
+	Healthy   bool      `json:"healthy"`
+	LastCheck time.Time `json:"last_check,omitempty"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// healthChecker periodically checks all server addresses of a backend,
+// removing servers from the load balancer when they fail the check and
+// adding them again when they recover. Servers of backends without a
+// health check are always considered healthy.
+type healthChecker struct {
+	backend string
+	check   *sites.HealthCheck
+	client  *http.Client
+	pool    *serverPool
+	log     interfaces.Logger
+
+	sync.Mutex
+	servers []*serverState
+	started bool
+	closed  bool
+}
+
+// serverState holds the health state of a server address.
+type serverState struct {
+	sync.Mutex
+	server    *url.URL
+	addr      *url.URL
+	host      string
+	ctx       context.Context
+	cancel    context.CancelFunc
+	healthy   bool
+	passed    int
+	failed    int
+	lastCheck time.Time
+	lastError string
+}
+
+func newHealthChecker(backend *sites.Backend, transport http.RoundTripper, pool *serverPool, log interfaces.Logger) *healthChecker {
+	c := &healthChecker{
+		backend: backend.Name,
+		pool:    pool,
+		log:     log.WithField("backend", backend.Name),
+	}
+
+	if backend.HealthCheck != nil {
+		c.check = backend.HealthCheck.WithDefaults()
+		c.client = &http.Client{
+			Transport: transport,
+			Timeout:   c.check.Timeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				// the status of the redirect is checked
+				return http.ErrUseLastResponse
+			},
+		}
+	}
+
+	return c
+}
+
+// add adds a server address which is in the load balancer. Servers are
+// considered healthy until they fail the check. The host is sent in the
+// Host header of the checks. Addresses added after the health checker
+// has been started are checked immediately.
+func (c *healthChecker) add(server, addr *url.URL, host string) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.closed {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s := &serverState{
+		server:  server,
+		addr:    addr,
+		host:    host,
+		ctx:     ctx,
+		cancel:  cancel,
+		healthy: true,
+	}
+
+	c.servers = append(c.servers, s)
+
+	if c.started {
+		c.startServer(s)
+	}
+}
+
+// remove stops checking a server address.
+func (c *healthChecker) remove(addr *url.URL) {
+	c.Lock()
+	defer c.Unlock()
+
+	for i, s := range c.servers {
+		if s.addr.Host == addr.Host {
+			s.cancel()
+			c.servers = append(c.servers[:i], c.servers[i+1:]...)
+			return
+		}
+	}
+}
+
+// start starts checking all server addresses, if the backend has a
+// health check. Each address is checked immediately, and then on the
+// interval.
+func (c *healthChecker) start() {
+	c.Lock()
+	defer c.Unlock()
+
+	c.started = true
+
+	for _, s := range c.servers {
+		c.startServer(s)
+	}
+}
+
+func (c *healthChecker) startServer(s *serverState) {
+	if c.check == nil {
+		return
+	}
+
+	go c.run(s)
+}
+
+// close stops all checks. Checks in progress are canceled instead of
+// awaited, so closing does not delay a configuration update.
+func (c *healthChecker) close() {
+	c.Lock()
+	c.closed = true
+	for _, s := range c.servers {
+		s.cancel()
+	}
+	c.servers = nil
+	c.Unlock()
+}
+
+func (c *healthChecker) run(s *serverState) {
+	ticker := time.NewTicker(c.check.Interval)
+	defer ticker.Stop()
+
+	for {
+		err := c.checkServer(s)
+		if s.ctx.Err() != nil {
+			// stopped during the check: its result is meaningless
+			return
+		}
+
+		c.update(s, err)
+
+		select {
+		case <-ticker.C:
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// checkServer requests the health check path from the server address,
+// using the host name of the server.
+func (c *healthChecker) checkServer(s *serverState) error {
+	u := &url.URL{
+		Scheme: s.addr.Scheme,
+		Host:   s.addr.Host,
+		Path:   c.check.Path,
+	}
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	req = req.WithContext(s.ctx)
+	req.Host = s.host
+	req.Header.Set("User-Agent", "platform-proxy health check")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if !c.check.Passes(resp.StatusCode) {
+		return fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// update records the result of a check, removing the server from the load
+// balancer after Fall consecutive failures, and adding it again after Rise
+// consecutive passes.
+func (c *healthChecker) update(s *serverState, checkErr error) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.lastCheck = time.Now()
+	s.lastError = ""
+
+	log := c.log.
+		WithField("server", s.server).
+		WithField("addr", s.addr)
+
+	if checkErr != nil {
+		s.lastError = checkErr.Error()
+		s.passed = 0
+		s.failed++
+
+		if s.healthy && s.failed >= c.check.Fall {
+			s.healthy = false
+
+			log.WithError(checkErr).Warn("server unhealthy: removing from load balancer")
+
+			if err := c.pool.exclude(s.addr, excludedUnhealthy); err != nil {
+				log.WithError(err).Error("removing server")
+			}
+		}
+
+		return
+	}
+
+	s.failed = 0
+	s.passed++
+
+	if !s.healthy && s.passed >= c.check.Rise {
+		s.healthy = true
+
+		log.Info("server healthy: adding to load balancer")
+
+		if err := c.pool.include(s.addr, excludedUnhealthy); err != nil {
+			log.WithError(err).Error("adding server")
+		}
+	}
+}
+
+// health returns the health state of all server addresses.
+func (c *healthChecker) health() []*ServerHealth {
+	c.Lock()
+	defer c.Unlock()
+
+	var health []*ServerHealth
+
+	for _, s := range c.servers {
+		s.Lock()
+		health = append(health, &ServerHealth{
+			Backend:   c.backend,
+			Server:    s.server.String(),
+			Addr:      s.addr.Host,
+			Healthy:   s.healthy,
+			LastCheck: s.lastCheck,
+			LastError: s.lastError,
+		})
+		s.Unlock()
+	}
+
+	return health
+}
@@ -0,0 +1,135 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/off-sync/platform-proxy/domain/sites"
+)
+
+// waitForHealth waits until the health state of the only server of the
+// router matches.
+func waitForHealth(t *testing.T, rtr *Router, healthy bool, timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+
+	for time.Now().Before(deadline) {
+		health := rtr.ServerHealth()
+		if len(health) == 1 && health[0].Healthy == healthy && !health[0].LastCheck.IsZero() {
+			return
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	t.Fatalf("expected healthy %v, got %+v", healthy, rtr.ServerHealth()[0])
+}
+
+func TestHealthCheckMarksServerDownAndUp(t *testing.T) {
+	var down int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" && atomic.LoadInt32(&down) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	backend := newTestBackend(t, "www", srv.URL)
+	backend.HealthCheck = &sites.HealthCheck{
+		Path:     "/health",
+		Interval: 10 * time.Millisecond,
+		Rise:     2,
+		Fall:     2,
+	}
+
+	rtr := newTestRouter(t, []*sites.Backend{backend}, []*sites.Frontend{sites.NewFrontend("www", "example.com")})
+
+	status := func() int {
+		w := httptest.NewRecorder()
+		rtr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+
+		return w.Code
+	}
+
+	waitForHealth(t, rtr, true, time.Second)
+
+	atomic.StoreInt32(&down, 1)
+	waitForHealth(t, rtr, false, time.Second)
+
+	if code := status(); code == http.StatusOK {
+		t.Fatal("expected unhealthy server not to receive requests")
+	}
+
+	atomic.StoreInt32(&down, 0)
+	waitForHealth(t, rtr, true, time.Second)
+
+	if code := status(); code != http.StatusOK {
+		t.Fatalf("expected recovered server to receive requests, got %d", code)
+	}
+}
+
+func TestHealthCheckRunsImmediately(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	backend := newTestBackend(t, "www", srv.URL)
+	backend.HealthCheck = &sites.HealthCheck{
+		Interval: time.Hour,
+		Fall:     1,
+	}
+
+	rtr := newTestRouter(t, []*sites.Backend{backend}, []*sites.Frontend{sites.NewFrontend("www", "example.com")})
+
+	// the first check does not wait for the interval
+	waitForHealth(t, rtr, false, time.Second)
+}
+
+func TestUpdateDoesNotWaitForHealthChecks(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	backend := newTestBackend(t, "www", srv.URL)
+	backend.HealthCheck = &sites.HealthCheck{
+		Interval: time.Hour,
+		Timeout:  time.Minute,
+	}
+
+	rtr := newTestRouter(t, []*sites.Backend{backend}, []*sites.Frontend{sites.NewFrontend("www", "example.com")})
+
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("expected health check to start")
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- rtr.Update(nil, nil) }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected update not to wait for the health check in progress")
+	}
+}
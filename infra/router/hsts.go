@@ -0,0 +1,18 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/off-sync/platform-proxy/domain/sites"
+)
+
+// hsts adds the Strict-Transport-Security header to all responses.
+func hsts(policy *sites.HSTS, handler http.Handler) http.Handler {
+	value := policy.HeaderValue()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", value)
+
+		handler.ServeHTTP(w, r)
+	})
+}
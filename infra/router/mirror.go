@@ -0,0 +1,245 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/off-sync/platform-proxy/app/interfaces"
+	"github.com/off-sync/platform-proxy/domain/sites"
+)
+
+// Limits of the mirrored requests of a frontend, so a slow mirror backend
+// cannot pile up requests in the proxy.
+const (
+	maxMirrorRequests = 100
+	mirrorTimeout     = 30 * time.Second
+)
+
+// MirrorStats compares the responses of the backend of a frontend with
+// those of its mirror backend. Statuses counts each combination of status
+// codes, e.g. '200/500' for a primary 200 and a mirror 500.
+type MirrorStats struct {
+	Domain     string           `json:"domain"`
+	PathPrefix string           `json:"path_prefix,omitempty"`
+	PathRegex  string           `json:"path_regex,omitempty"`
+	Backend    string           `json:"backend"`
+	Mirrored   int64            `json:"mirrored"`
+	Skipped    int64            `json:"skipped"`
+	Matched    int64            `json:"matched"`
+	Mismatched int64            `json:"mismatched"`
+	Statuses   map[string]int64 `json:"statuses"`
+}
+
+// mirror sends a sample of the requests of a frontend to the mirror
+// backend as well. Mirrored requests are sent asynchronously and their
+// responses are discarded, so they never delay or fail the request.
+// Requests are skipped if the body is too large or too many mirrored
+// requests are in progress.
+type mirror struct {
+	sync.Mutex
+	settings *sites.Mirror
+	next     http.Handler
+	shadow   http.Handler
+	slots    chan struct{}
+	rnd      *lockedRand
+	stats    MirrorStats
+	log      interfaces.Logger
+}
+
+func newMirror(frontend *sites.Frontend, next, shadow http.Handler, log interfaces.Logger) *mirror {
+	settings := frontend.Mirror.WithDefaults()
+
+	return &mirror{
+		settings: settings,
+		next:     next,
+		shadow:   shadow,
+		slots:    make(chan struct{}, maxMirrorRequests),
+		rnd:      &lockedRand{r: rand.New(rand.NewSource(time.Now().UnixNano()))},
+		stats: MirrorStats{
+			Domain:     frontend.Domain,
+			PathPrefix: frontend.PathPrefix,
+			PathRegex:  frontend.PathRegex,
+			Backend:    settings.BackendName,
+			Statuses:   make(map[string]int64),
+		},
+		log: log.
+			WithField("domain", frontend.Domain).
+			WithField("mirror", settings.BackendName),
+	}
+}
+
+func (m *mirror) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if isUpgradeRequest(req) || m.rnd.float64()*100 >= m.settings.Percentage {
+		m.next.ServeHTTP(w, req)
+		return
+	}
+
+	select {
+	case m.slots <- struct{}{}:
+	default:
+		m.skip()
+		m.next.ServeHTTP(w, req)
+		return
+	}
+
+	shadow, ok := m.newShadowRequest(req)
+	if !ok {
+		<-m.slots
+		m.skip()
+		m.next.ServeHTTP(w, req)
+		return
+	}
+
+	primary := make(chan int, 1)
+	go m.send(shadow, primary)
+
+	rec := &statusRecorder{ResponseWriter: w}
+	defer func() {
+		primary <- rec.status
+	}()
+
+	m.next.ServeHTTP(rec, req)
+}
+
+// newShadowRequest returns a copy of the request which can be sent to the
+// mirror backend. The body is buffered, and replaced in the original
+// request. It returns false if the body is too large or of unknown size.
+func (m *mirror) newShadowRequest(req *http.Request) (*http.Request, bool) {
+	var body []byte
+
+	if req.Body != nil && req.ContentLength != 0 {
+		if req.ContentLength < 0 || req.ContentLength > m.settings.MaxBodyBytes {
+			return nil, false
+		}
+
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(body), req.Body))
+
+		if err != nil {
+			return nil, false
+		}
+	}
+
+	shadow := req.WithContext(context.Background())
+
+	u := *req.URL
+	shadow.URL = &u
+	shadow.Header = copyHeader(make(http.Header), req.Header)
+	shadow.Trailer = nil
+	shadow.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	return shadow, true
+}
+
+// send sends the request to the mirror backend, and records its status
+// together with the status of the primary response.
+func (m *mirror) send(req *http.Request, primary <-chan int) {
+	defer func() {
+		<-m.slots
+	}()
+
+	defer func() {
+		if err := recover(); err != nil {
+			m.log.
+				WithField("panic", err).
+				Error("sending mirrored request")
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), mirrorTimeout)
+	defer cancel()
+
+	w := &discardWriter{header: make(http.Header)}
+	m.shadow.ServeHTTP(w, req.WithContext(ctx))
+
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	primaryStatus := <-primary
+	if primaryStatus == 0 {
+		primaryStatus = http.StatusOK
+	}
+
+	m.record(req, primaryStatus, w.status)
+}
+
+func (m *mirror) record(req *http.Request, primary, shadow int) {
+	if primary != shadow {
+		m.log.
+			WithField("method", req.Method).
+			WithField("path", req.URL.Path).
+			WithField("status", primary).
+			WithField("mirror_status", shadow).
+			Debug("mirror status differs")
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	m.stats.Mirrored++
+
+	if primary == shadow {
+		m.stats.Matched++
+	} else {
+		m.stats.Mismatched++
+	}
+
+	m.stats.Statuses[fmt.Sprintf("%d/%d", primary, shadow)]++
+}
+
+func (m *mirror) skip() {
+	m.Lock()
+	defer m.Unlock()
+
+	m.stats.Skipped++
+}
+
+// statistics returns a copy of the statistics.
+func (m *mirror) statistics() *MirrorStats {
+	m.Lock()
+	defer m.Unlock()
+
+	stats := m.stats
+	stats.Statuses = make(map[string]int64, len(m.stats.Statuses))
+	for k, v := range m.stats.Statuses {
+		stats.Statuses[k] = v
+	}
+
+	return &stats
+}
+
+// discardWriter discards the response of a mirrored request, only
+// recording its status code.
+type discardWriter struct {
+	header http.Header
+	status int
+}
+
+func (w *discardWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *discardWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *discardWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	return len(p), nil
+}
+
+func (w *discardWriter) Flush() {}
@@ -0,0 +1,168 @@
+package router
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/off-sync/platform-proxy/domain/sites"
+)
+
+// mirroredRequest holds what a server received of a request.
+type mirroredRequest struct {
+	method, uri, header, body string
+}
+
+// newRecordingServer returns a server sending the requests it receives
+// on the channel, answering them with the status.
+func newRecordingServer(status int, requests chan<- *mirroredRequest) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		requests <- &mirroredRequest{
+			method: r.Method,
+			uri:    r.RequestURI,
+			header: r.Header.Get("X-Test"),
+			body:   string(body),
+		}
+
+		w.WriteHeader(status)
+		w.Write([]byte("primary"))
+	}))
+}
+
+func newMirrorTestRouter(t *testing.T, primary, shadow string) *Router {
+	frontend := sites.NewFrontend("primary", "example.com")
+	frontend.Mirror = &sites.Mirror{BackendName: "shadow"}
+
+	return newTestRouter(t, []*sites.Backend{
+		newTestBackend(t, "primary", primary),
+		newTestBackend(t, "shadow", shadow),
+	}, []*sites.Frontend{frontend})
+}
+
+// waitForMirrorStats waits until the mirrored request has been recorded
+// and returns the statistics.
+func waitForMirrorStats(t *testing.T, rtr *Router) *MirrorStats {
+	deadline := time.Now().Add(5 * time.Second)
+
+	for time.Now().Before(deadline) {
+		if stats := rtr.MirrorStats(); len(stats) == 1 && stats[0].Mirrored > 0 {
+			return stats[0]
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatal("expected mirrored request to be recorded")
+	return nil
+}
+
+func TestMirrorReceivesCopyOfRequest(t *testing.T) {
+	primaryRequests := make(chan *mirroredRequest, 1)
+	primary := newRecordingServer(http.StatusOK, primaryRequests)
+	defer primary.Close()
+
+	shadowRequests := make(chan *mirroredRequest, 1)
+	shadow := newRecordingServer(http.StatusOK, shadowRequests)
+	defer shadow.Close()
+
+	rtr := newMirrorTestRouter(t, primary.URL, shadow.URL)
+
+	req := httptest.NewRequest(http.MethodPost, "http://example.com/orders?id=1", strings.NewReader(`{"item": 42}`))
+	req.Header.Set("X-Test", "mirrored")
+
+	w := httptest.NewRecorder()
+	rtr.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || w.Body.String() != "primary" {
+		t.Fatalf("expected primary response, got %d: %s", w.Code, w.Body.String())
+	}
+
+	expected := mirroredRequest{
+		method: http.MethodPost,
+		uri:    "/orders?id=1",
+		header: "mirrored",
+		body:   `{"item": 42}`,
+	}
+
+	// the body is buffered for the mirror, but still reaches the primary
+	if received := <-primaryRequests; *received != expected {
+		t.Errorf("expected primary request %+v, got %+v", expected, *received)
+	}
+
+	select {
+	case received := <-shadowRequests:
+		if *received != expected {
+			t.Errorf("expected mirrored request %+v, got %+v", expected, *received)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected request to be mirrored")
+	}
+
+	if stats := waitForMirrorStats(t, rtr); stats.Matched != 1 {
+		t.Errorf("expected matching statuses, got %+v", stats)
+	}
+}
+
+func TestSlowMirrorDoesNotDelayPrimary(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("primary"))
+	}))
+	defer primary.Close()
+
+	release := make(chan struct{})
+
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer shadow.Close()
+	defer close(release)
+
+	rtr := newMirrorTestRouter(t, primary.URL, shadow.URL)
+
+	done := make(chan *httptest.ResponseRecorder, 1)
+	go func() {
+		w := httptest.NewRecorder()
+		rtr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+		done <- w
+	}()
+
+	// the mirror does not answer until the test ends
+	select {
+	case w := <-done:
+		if w.Code != http.StatusOK || w.Body.String() != "primary" {
+			t.Errorf("expected primary response, got %d: %s", w.Code, w.Body.String())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected primary response not to wait for the mirror")
+	}
+}
+
+func TestFailingMirrorDoesNotChangePrimary(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Primary", "true")
+		w.Write([]byte("primary"))
+	}))
+	defer primary.Close()
+
+	// connections to the mirror are refused
+	shadow := httptest.NewServer(http.NotFoundHandler())
+	shadow.Close()
+
+	rtr := newMirrorTestRouter(t, primary.URL, shadow.URL)
+
+	w := httptest.NewRecorder()
+	rtr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+
+	if w.Code != http.StatusOK || w.Body.String() != "primary" || w.Header().Get("X-Primary") != "true" {
+		t.Errorf("expected primary response, got %d %v: %s", w.Code, w.Header(), w.Body.String())
+	}
+
+	if stats := waitForMirrorStats(t, rtr); stats.Mismatched != 1 || stats.Statuses["200/502"] != 1 {
+		t.Errorf("expected mismatching statuses 200/502, got %+v", stats)
+	}
+}
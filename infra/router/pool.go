@@ -0,0 +1,129 @@
+package router
+
+import (
+	"net/url"
+	"sync"
+)
+
+// Reasons for which a server address is excluded from the load balancer.
+const (
+	excludedUnhealthy = "unhealthy"
+	excludedEjected   = "ejected"
+)
+
+// serverPool adds server addresses to and removes them from the load
+// balancer on behalf of the health checker and the circuit breaker. An
+// address is in the load balancer as long as neither has excluded it.
+type serverPool struct {
+	sync.Mutex
+	lb       loadBalancer
+	weights  map[string]int
+	excluded map[string]map[string]bool
+}
+
+// newServerPool creates a server pool. As the load balancer is created
+// with the handlers using the pool, it must be set before use.
+func newServerPool() *serverPool {
+	return &serverPool{
+		weights:  make(map[string]int),
+		excluded: make(map[string]map[string]bool),
+	}
+}
+
+// add adds a server address with the provided weight to the load balancer.
+func (p *serverPool) add(u *url.URL, weight int) error {
+	p.Lock()
+	defer p.Unlock()
+
+	p.weights[u.Host] = weight
+
+	return p.lb.UpsertServer(u, weight)
+}
+
+// remove removes a server address from the load balancer, whether it is
+// excluded or not.
+func (p *serverPool) remove(u *url.URL) error {
+	p.Lock()
+	defer p.Unlock()
+
+	if _, found := p.weights[u.Host]; !found {
+		return nil
+	}
+
+	_, excluded := p.excluded[u.Host]
+
+	delete(p.weights, u.Host)
+	delete(p.excluded, u.Host)
+
+	if excluded {
+		// already removed
+		return nil
+	}
+
+	return p.lb.RemoveServer(u)
+}
+
+// exclude removes the server address from the load balancer for the
+// provided reason.
+func (p *serverPool) exclude(u *url.URL, reason string) error {
+	p.Lock()
+	defer p.Unlock()
+
+	if _, found := p.weights[u.Host]; !found {
+		// removed from the pool
+		return nil
+	}
+
+	reasons, found := p.excluded[u.Host]
+	if !found {
+		reasons = make(map[string]bool)
+		p.excluded[u.Host] = reasons
+	}
+
+	reasons[reason] = true
+
+	if len(reasons) > 1 {
+		// already removed
+		return nil
+	}
+
+	return p.lb.RemoveServer(u)
+}
+
+// include adds the server address to the load balancer again, unless it
+// is still excluded for another reason.
+func (p *serverPool) include(u *url.URL, reason string) error {
+	p.Lock()
+	defer p.Unlock()
+
+	reasons := p.excluded[u.Host]
+	if !reasons[reason] {
+		return nil
+	}
+
+	delete(reasons, reason)
+
+	if len(reasons) > 0 {
+		return nil
+	}
+
+	delete(p.excluded, u.Host)
+
+	return p.lb.UpsertServer(u, p.weights[u.Host])
+}
+
+// isOnlyExcluded returns whether the server address is excluded for the
+// provided reason only.
+func (p *serverPool) isOnlyExcluded(u *url.URL, reason string) bool {
+	p.Lock()
+	defer p.Unlock()
+
+	reasons := p.excluded[u.Host]
+
+	return len(reasons) == 1 && reasons[reason]
+}
+
+// isEmpty returns whether the load balancer has no server addresses.
+func (p *serverPool) isEmpty() bool {
+	return len(p.lb.Servers()) < 1
+}
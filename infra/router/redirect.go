@@ -0,0 +1,41 @@
+package router
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// acmeChallengePath is the path prefix under which ACME HTTP-01
+// challenges are requested.
+const acmeChallengePath = "/.well-known/acme-challenge/"
+
+// NewHTTPSRedirectHandler creates the handler for a plain HTTP listener.
+// It passes ACME HTTP-01 challenges to the challenges handler (if not nil),
+// redirects requests for hosts known to the router to HTTPS using the
+// provided status code (301 or 308), and returns 404 for unknown hosts.
+// The HTTPS port is added to the redirect location if it is not empty.
+func NewHTTPSRedirectHandler(r *Router, status int, httpsPort string, challenges http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if challenges != nil && strings.HasPrefix(req.URL.Path, acmeChallengePath) {
+			challenges.ServeHTTP(w, req)
+			return
+		}
+
+		if !r.KnownHost(req.Host) {
+			http.NotFound(w, req)
+			return
+		}
+
+		host := req.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+
+		if httpsPort != "" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+
+		http.Redirect(w, req, "https://"+host+req.URL.RequestURI(), status)
+	})
+}
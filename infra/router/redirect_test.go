@@ -0,0 +1,91 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/off-sync/platform-proxy/domain/sites"
+)
+
+func TestHTTPSRedirectHandler(t *testing.T) {
+	rtr := newTestRouter(t, []*sites.Backend{newTestBackend(t, "www", "http://127.0.0.1:8080")}, []*sites.Frontend{
+		sites.NewFrontend("www", "example.com"),
+		sites.NewFrontend("www", "*.example.org"),
+	})
+
+	challenges := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("challenge"))
+	})
+
+	tests := []struct {
+		name      string
+		status    int
+		httpsPort string
+		target    string
+		expected  int
+		location  string
+	}{
+		{"exact", http.StatusMovedPermanently, "", "http://example.com/path?q=1", http.StatusMovedPermanently, "https://example.com/path?q=1"},
+		{"wildcard", http.StatusPermanentRedirect, "", "http://www.example.org/", http.StatusPermanentRedirect, "https://www.example.org/"},
+		{"http-port-removed", http.StatusMovedPermanently, "", "http://example.com:8080/", http.StatusMovedPermanently, "https://example.com/"},
+		{"https-port-added", http.StatusMovedPermanently, "8443", "http://example.com:8080/", http.StatusMovedPermanently, "https://example.com:8443/"},
+		{"unknown-host", http.StatusMovedPermanently, "", "http://example.net/", http.StatusNotFound, ""},
+		{"unknown-subdomain", http.StatusMovedPermanently, "", "http://www.example.com/", http.StatusNotFound, ""},
+		{"acme-challenge", http.StatusMovedPermanently, "", "http://example.net/.well-known/acme-challenge/token", http.StatusOK, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			NewHTTPSRedirectHandler(rtr, test.status, test.httpsPort, challenges).
+				ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.target, nil))
+
+			if w.Code != test.expected {
+				t.Errorf("expected status %d, got %d", test.expected, w.Code)
+			}
+
+			if location := w.Header().Get("Location"); location != test.location {
+				t.Errorf("expected location %q, got %q", test.location, location)
+			}
+		})
+	}
+}
+
+func TestHSTSHeader(t *testing.T) {
+	srv := httptest.NewServer(newProtoServer())
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		policy   *sites.HSTS
+		expected string
+	}{
+		{"none", nil, ""},
+		{"max-age", &sites.HSTS{MaxAge: 365 * 24 * time.Hour}, "max-age=31536000"},
+		{"subdomains", &sites.HSTS{MaxAge: time.Hour, IncludeSubDomains: true}, "max-age=3600; includeSubDomains"},
+		{"preload", &sites.HSTS{MaxAge: 2 * 365 * 24 * time.Hour, IncludeSubDomains: true, Preload: true}, "max-age=63072000; includeSubDomains; preload"},
+		{"disable", &sites.HSTS{}, "max-age=0"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			frontend := sites.NewFrontend("www", "example.com")
+			frontend.HSTS = test.policy
+
+			rtr := newTestRouter(t, []*sites.Backend{newTestBackend(t, "www", srv.URL)}, []*sites.Frontend{frontend})
+
+			w := httptest.NewRecorder()
+			rtr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "https://example.com/", nil))
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", w.Code)
+			}
+
+			if value := w.Header().Get("Strict-Transport-Security"); value != test.expected {
+				t.Errorf("expected HSTS header %q, got %q", test.expected, value)
+			}
+		})
+	}
+}
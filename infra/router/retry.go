@@ -0,0 +1,262 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"github.com/off-sync/platform-proxy/app/interfaces"
+	"github.com/off-sync/platform-proxy/domain/sites"
+)
+
+// maxRetryBalance caps the number of retries which can be saved up by
+// the retry budget, and is also its initial balance.
+const maxRetryBalance = 10
+
+// retrier retries idempotent requests against the next server of the load
+// balancer on connection errors and on configured status codes. Retries
+// skip the server addresses of earlier attempts while others are available,
+// and ignore sticky session cookies.
+type retrier struct {
+	settings *sites.Retry
+	next     http.Handler
+	budget   *retryBudget
+	log      interfaces.Logger
+}
+
+// attempt records the server address of the current attempt of a request,
+// whether the transport failed to reach it, and the addresses of earlier
+// attempts.
+type attempt struct {
+	host    string
+	connErr bool
+	tried   map[string]bool
+}
+
+// triedHosts returns the server addresses of earlier attempts of the
+// request, if it is retried.
+func triedHosts(req *http.Request) map[string]bool {
+	if a, ok := req.Context().Value(attemptKey).(*attempt); ok {
+		return a.tried
+	}
+
+	return nil
+}
+
+func newRetrier(backend *sites.Backend, next http.Handler, log interfaces.Logger) *retrier {
+	settings := backend.Retry.WithDefaults()
+
+	return &retrier{
+		settings: settings,
+		next:     next,
+		budget:   newRetryBudget(settings.Budget),
+		log:      log.WithField("backend", backend.Name),
+	}
+}
+
+func (h *retrier) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if !sites.IsIdempotent(req.Method) || isUpgradeRequest(req) {
+		h.next.ServeHTTP(w, req)
+		return
+	}
+
+	body, ok := h.bufferBody(req)
+	if !ok {
+		h.next.ServeHTTP(w, req)
+		return
+	}
+
+	h.budget.deposit()
+
+	a := &attempt{tried: make(map[string]bool)}
+	ctx := context.WithValue(req.Context(), attemptKey, a)
+
+	for n := 1; ; n++ {
+		a.host = ""
+		a.connErr = false
+
+		r := req.WithContext(ctx)
+		if body != nil {
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+
+		last := n >= h.settings.Attempts
+
+		rw := &retryWriter{
+			ResponseWriter: w,
+			header:         make(http.Header),
+			mayRetry: func(status int) bool {
+				return !last &&
+					(a.connErr || h.settings.RetriesStatus(status)) &&
+					h.budget.withdraw()
+			},
+		}
+
+		h.next.ServeHTTP(rw, r)
+
+		if a.host != "" {
+			a.tried[a.host] = true
+		}
+
+		if !rw.wroteHeader {
+			rw.WriteHeader(http.StatusOK)
+		}
+
+		if !rw.retry {
+			return
+		}
+
+		h.log.
+			WithField("method", req.Method).
+			WithField("path", req.URL.Path).
+			WithField("attempt", n).
+			WithField("server", a.host).
+			WithField("status", rw.status).
+			WithField("conn_err", a.connErr).
+			Info("retrying request")
+	}
+}
+
+// bufferBody reads the request body so it can be sent more than once. It
+// returns false if the body is too large or of unknown size.
+func (h *retrier) bufferBody(req *http.Request) ([]byte, bool) {
+	if req.Body == nil || req.ContentLength == 0 {
+		return nil, true
+	}
+
+	if req.ContentLength < 0 || req.ContentLength > h.settings.MaxBodyBytes {
+		return nil, false
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, false
+	}
+
+	return body, true
+}
+
+// retryWriter passes the response to the client, unless it decides to
+// retry the request based on the status code, in which case the response
+// is discarded.
+type retryWriter struct {
+	http.ResponseWriter
+	header      http.Header
+	mayRetry    func(status int) bool
+	wroteHeader bool
+	status      int
+	retry       bool
+}
+
+func (w *retryWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *retryWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+
+	w.wroteHeader = true
+	w.status = status
+
+	if w.mayRetry(status) {
+		w.retry = true
+		return
+	}
+
+	copyHeader(w.ResponseWriter.Header(), w.header)
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *retryWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if w.retry {
+		return len(p), nil
+	}
+
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *retryWriter) Flush() {
+	if w.retry {
+		return
+	}
+
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *retryWriter) CloseNotify() <-chan bool {
+	if cn, ok := w.ResponseWriter.(http.CloseNotifier); ok {
+		return cn.CloseNotify()
+	}
+
+	return make(chan bool)
+}
+
+// connErrTransport records the server address and transport errors in the
+// attempt of the request, so the address can be skipped by the next attempt
+// and transport errors can be distinguished from error responses of the
+// server.
+type connErrTransport struct {
+	http.RoundTripper
+}
+
+func (t *connErrTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	a, _ := req.Context().Value(attemptKey).(*attempt)
+	if a != nil {
+		a.host = req.URL.Host
+	}
+
+	resp, err := t.RoundTripper.RoundTrip(req)
+	if err != nil && a != nil {
+		a.connErr = true
+	}
+
+	return resp, err
+}
+
+// retryBudget limits retries to a fraction of all requests. Each request
+// deposits the fraction, each retry withdraws one.
+type retryBudget struct {
+	sync.Mutex
+	ratio   float64
+	balance float64
+}
+
+func newRetryBudget(ratio float64) *retryBudget {
+	return &retryBudget{
+		ratio:   ratio,
+		balance: maxRetryBalance,
+	}
+}
+
+func (b *retryBudget) deposit() {
+	b.Lock()
+	defer b.Unlock()
+
+	b.balance += b.ratio
+	if b.balance > maxRetryBalance {
+		b.balance = maxRetryBalance
+	}
+}
+
+func (b *retryBudget) withdraw() bool {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.balance < 1 {
+		return false
+	}
+
+	b.balance--
+
+	return true
+}
@@ -0,0 +1,155 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/off-sync/platform-proxy/domain/sites"
+)
+
+// retryServers holds servers which record the requests they receive,
+// identified by their X-Request header.
+type retryServers struct {
+	sync.Mutex
+	urls    []string
+	failing []int32
+	hits    map[string]map[int]int
+}
+
+func newRetryServers(t *testing.T, n int) *retryServers {
+	s := &retryServers{
+		failing: make([]int32, n),
+		hits:    make(map[string]map[int]int),
+	}
+
+	for i := 0; i < n; i++ {
+		i := i
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s.Lock()
+			id := r.Header.Get("X-Request")
+			if s.hits[id] == nil {
+				s.hits[id] = make(map[int]int)
+			}
+			s.hits[id][i]++
+			s.Unlock()
+
+			w.Header().Set("X-Server", fmt.Sprint(i))
+
+			if atomic.LoadInt32(&s.failing[i]) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}))
+		t.Cleanup(srv.Close)
+
+		s.urls = append(s.urls, srv.URL)
+	}
+
+	return s
+}
+
+func newRetryTestRouter(t *testing.T, servers *retryServers, algorithm sites.Algorithm, sticky bool) *Router {
+	backend := newTestBackend(t, "retry", servers.urls...)
+	backend.Algorithm = algorithm
+	backend.Retry = &sites.Retry{
+		Attempts: len(servers.urls),
+		Statuses: []int{http.StatusServiceUnavailable},
+		Budget:   1,
+	}
+
+	if sticky {
+		backend.StickySession = &sites.StickySession{}
+	}
+
+	return newTestRouter(t, []*sites.Backend{backend}, []*sites.Frontend{
+		sites.NewFrontend("retry", "example.com"),
+	})
+}
+
+func retryRequest(id string, cookie *http.Cookie) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	req.Header.Set("X-Request", id)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+
+	return req
+}
+
+func TestRetriesSkipTriedServers(t *testing.T) {
+	algorithms := []sites.Algorithm{
+		sites.RoundRobin,
+		sites.LeastConnections,
+		sites.PowerOfTwoChoices,
+		sites.ConsistentHash,
+	}
+
+	for _, algorithm := range algorithms {
+		t.Run(algorithm.String(), func(t *testing.T) {
+			servers := newRetryServers(t, 3)
+			servers.failing[0] = 1
+			servers.failing[1] = 1
+
+			rtr := newRetryTestRouter(t, servers, algorithm, false)
+
+			// requests can need two retries: stay within the budget
+			for n := 0; n < 5; n++ {
+				id := fmt.Sprint(n)
+
+				w := httptest.NewRecorder()
+				rtr.ServeHTTP(w, retryRequest(id, nil))
+
+				if w.Code != http.StatusOK {
+					t.Errorf("request %s: expected status 200, got %d", id, w.Code)
+				}
+
+				for server, hits := range servers.hits[id] {
+					if hits > 1 {
+						t.Errorf("request %s: server %d tried %d times", id, server, hits)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRetriesIgnoreStickyCookie(t *testing.T) {
+	servers := newRetryServers(t, 2)
+	rtr := newRetryTestRouter(t, servers, sites.RoundRobin, true)
+
+	// find the cookie pinning requests to server 0
+	var cookie *http.Cookie
+	for n := 0; n < 10 && cookie == nil; n++ {
+		w := httptest.NewRecorder()
+		rtr.ServeHTTP(w, retryRequest("pin", nil))
+
+		if w.Header().Get("X-Server") == "0" {
+			cookie = w.Result().Cookies()[0]
+		}
+	}
+
+	if cookie == nil {
+		t.Fatal("no cookie for server 0")
+	}
+
+	atomic.StoreInt32(&servers.failing[0], 1)
+
+	w := httptest.NewRecorder()
+	rtr.ServeHTTP(w, retryRequest("sticky", cookie))
+
+	if w.Code != http.StatusOK || w.Header().Get("X-Server") != "1" {
+		t.Fatalf("expected status 200 from server 1, got %d from %s", w.Code, w.Header().Get("X-Server"))
+	}
+
+	if hits := servers.hits["sticky"]; hits[0] != 1 || hits[1] != 1 {
+		t.Errorf("expected one attempt per server, got %v", hits)
+	}
+
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Value == cookie.Value {
+		t.Errorf("expected a new cookie for server 1, got %v", cookies)
+	}
+}
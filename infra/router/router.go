@@ -0,0 +1,429 @@
+package router
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/off-sync/platform-proxy/app/interfaces"
+	"github.com/off-sync/platform-proxy/domain/sites"
+)
+
+// Router implements the ConfigUpdater interface. It routes requests to
+// the backends based on the configured frontends. Updates replace the
+// complete configuration at once: requests in progress are completed using
+// the configuration with which they were started.
+type Router struct {
+	sync.RWMutex
+	log            interfaces.Logger
+	defaultHandler http.Handler
+	handler        http.Handler
+	frontendHosts  []*frontendHost
+	backends       []*backendHandler
+	mirrors        []*mirror
+	backendOptions
+}
+
+// backendOptions holds the router options used by the backend handlers.
+type backendOptions struct {
+	stickySecret    []byte
+	resolveInterval time.Duration
+	clientCerts     *clientCertIssuer
+}
+
+// Option configures the router.
+type Option func(*Router)
+
+// StickySecret sets the secret used to sign sticky session cookies. All
+// proxy instances behind the same load balancer must use the same secret.
+// By default a random secret is used, which invalidates cookies when the
+// proxy is restarted.
+func StickySecret(secret []byte) Option {
+	return func(r *Router) {
+		r.stickySecret = secret
+	}
+}
+
+// ResolveInterval sets the interval on which the host names of the
+// backend servers are resolved again, so changes in their addresses are
+// picked up without a configuration update. By default host names are only
+// resolved when the configuration is updated.
+func ResolveInterval(interval time.Duration) Option {
+	return func(r *Router) {
+		r.resolveInterval = interval
+	}
+}
+
+// LocalCA sets the generator issuing the client certificate sent to https
+// servers of backends which authenticate the proxy using a certificate of
+// the local CA. The name is used as common name and DNS name.
+func LocalCA(gen interfaces.CertGen, name string) Option {
+	return func(r *Router) {
+		r.clientCerts = &clientCertIssuer{
+			gen:  gen,
+			name: name,
+		}
+	}
+}
+
+// New creates a new router. Requests that do not match any frontend
+// are passed to the default handler.
+func New(log interfaces.Logger, defaultHandler http.Handler, options ...Option) *Router {
+	r := &Router{
+		log:            log,
+		defaultHandler: defaultHandler,
+		handler:        defaultHandler,
+	}
+
+	for _, option := range options {
+		option(r)
+	}
+
+	if len(r.stickySecret) < 1 {
+		r.stickySecret = make([]byte, 32)
+		if _, err := rand.Read(r.stickySecret); err != nil {
+			log.WithError(err).Fatal("generating sticky session secret")
+		}
+	}
+
+	return r
+}
+
+// ServeHTTP routes a request using the current configuration.
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	removeClientCertHeaders(req.Header)
+
+	r.RLock()
+	handler := r.handler
+	r.RUnlock()
+
+	handler.ServeHTTP(w, req)
+}
+
+// Update replaces the configuration of the router with the provided
+// backends and frontends. Requests in progress, including upgraded
+// connections, are completed by the backends of the previous configuration,
+// so changing the weights of split frontends does not drop connections.
+// It returns ErrUnknownBackend if a frontend is included for which the backend is unknown.
+// It returns ErrDuplicateDomain if multiple frontends use the same domain
+// and path rules.
+func (r *Router) Update(backends []*sites.Backend, frontends []*sites.Frontend) error {
+	update, err := r.Prepare(backends, frontends)
+	if err != nil {
+		return err
+	}
+
+	update.Apply()
+
+	return nil
+}
+
+// routerUpdate is a configuration prepared by the router.
+type routerUpdate struct {
+	router        *Router
+	handler       http.Handler
+	frontendHosts []*frontendHost
+	backends      []*backendHandler
+	mirrors       []*mirror
+}
+
+// Prepare creates the handlers for the provided backends and frontends
+// without changing the configuration of the router. It returns the same
+// errors as Update.
+func (r *Router) Prepare(backends []*sites.Backend, frontends []*sites.Frontend) (interfaces.ConfigUpdate, error) {
+	if err := validate(backends, frontends); err != nil {
+		return nil, err
+	}
+
+	for _, pair := range overlappingFrontends(frontends) {
+		r.log.
+			WithField("frontend", pair[0].Domain).
+			WithField("overlapping", pair[1].Domain).
+			Warn("frontend domains overlap with equal priority and path rules: matching the first one")
+	}
+
+	u := &routerUpdate{router: r}
+	if err := r.prepare(u, backends, frontends); err != nil {
+		// close the handlers created before the error
+		u.Discard()
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// prepare creates the handlers of the update. The backend handlers are
+// added to the update as they are created, so they can be discarded if a
+// later step fails.
+func (r *Router) prepare(u *routerUpdate, backends []*sites.Backend, frontends []*sites.Frontend) error {
+	backendHandlers := make(map[string]http.Handler)
+	for _, backend := range backends {
+		handler, err := newBackendHandler(backend, &r.backendOptions, r.log)
+		if err != nil {
+			return fmt.Errorf("creating handler for backend %s: %s", backend.Name, err)
+		}
+
+		backendHandlers[backend.Name] = handler
+		u.backends = append(u.backends, handler)
+	}
+
+	m := mux.NewRouter()
+
+	// rules are applied before the backend is selected, so a rewritten
+	// request can be routed to the backend of another frontend
+	rm := mux.NewRouter()
+	hasRules := false
+
+	var frontendHosts []*frontendHost
+	var mirrors []*mirror
+	for _, frontend := range sortFrontends(frontends) {
+		if frontend.IsLayer4() {
+			// handled by the TCP proxy
+			continue
+		}
+
+		handler, found := newFrontendHandler(frontend, backendHandlers)
+		if !found {
+			// all requests for this frontend should be handled by rules
+			handler = http.NotFoundHandler()
+		}
+
+		if frontend.Mirror != nil {
+			m := newMirror(frontend, handler, backendHandlers[frontend.Mirror.BackendName], r.log)
+			mirrors = append(mirrors, m)
+			handler = m
+		}
+
+		matchHost, err := frontend.HostMatcher()
+		if err != nil {
+			return err
+		}
+
+		var clientAuth *clientAuthPolicy
+		if frontend.ClientAuth != nil {
+			clientAuth, err = newClientAuthPolicy(frontend, matchHost, r.log)
+			if err != nil {
+				return fmt.Errorf("client authentication for frontend %s: %s", frontend.Domain, err)
+			}
+		}
+
+		if err := addRoute(m, frontend, handler, clientAuth); err != nil {
+			return fmt.Errorf("adding route for frontend %s: %s", frontend.Domain, err)
+		}
+
+		if err := addRulesRoute(rm, frontend, m, clientAuth); err != nil {
+			return fmt.Errorf("adding rules for frontend %s: %s", frontend.Domain, err)
+		}
+
+		hasRules = hasRules || len(frontend.Rules) > 0
+
+		frontendHosts = append(frontendHosts, &frontendHost{
+			matchHost:  matchHost,
+			clientAuth: clientAuth,
+		})
+	}
+
+	m.NotFoundHandler = r.defaultHandler
+	rm.NotFoundHandler = m
+
+	var handler http.Handler = m
+	if hasRules {
+		handler = rm
+	}
+
+	u.handler = handler
+	u.frontendHosts = frontendHosts
+	u.mirrors = mirrors
+
+	return nil
+}
+
+// Apply starts the prepared backends and replaces the configuration of
+// the router.
+func (u *routerUpdate) Apply() {
+	for _, h := range u.backends {
+		h.start()
+	}
+
+	r := u.router
+
+	r.Lock()
+	r.handler = u.handler
+	r.frontendHosts = u.frontendHosts
+	previous := r.backends
+	r.backends = u.backends
+	r.mirrors = u.mirrors
+	r.Unlock()
+
+	for _, h := range previous {
+		h.close()
+	}
+}
+
+// Discard closes the prepared backends, which have not been started.
+func (u *routerUpdate) Discard() {
+	for _, h := range u.backends {
+		h.close()
+	}
+}
+
+// overlappingFrontends returns the pairs of frontends with wildcard or
+// regular expression domains which can match the same host, and have the
+// same priority and path rules. Which of them handles such a host follows
+// from the order of sortFrontends, the first of each pair. Frontends with
+// invalid regular expressions are skipped, as they fail to load.
+func overlappingFrontends(frontends []*sites.Frontend) [][2]*sites.Frontend {
+	var candidates []*sites.Frontend
+	for _, frontend := range sortFrontends(frontends) {
+		if !frontend.IsLayer4() && frontend.HostKind() != sites.ExactHost {
+			candidates = append(candidates, frontend)
+		}
+	}
+
+	var pairs [][2]*sites.Frontend
+	for i, a := range candidates {
+		for _, b := range candidates[i+1:] {
+			if a.Priority != b.Priority || a.PathPrefix != b.PathPrefix || a.PathRegex != b.PathRegex {
+				continue
+			}
+
+			if overlaps, err := a.Overlaps(b); err == nil && overlaps {
+				pairs = append(pairs, [2]*sites.Frontend{a, b})
+			}
+		}
+	}
+
+	return pairs
+}
+
+func validate(backends []*sites.Backend, frontends []*sites.Frontend) error {
+	backendNames := make(map[string]bool)
+	for _, backend := range backends {
+		backendNames[backend.Name] = true
+	}
+
+	routeKeys := make(map[string]bool)
+	for _, frontend := range frontends {
+		if frontend.IsLayer4() {
+			continue
+		}
+
+		for _, name := range frontend.BackendNames() {
+			if !backendNames[name] {
+				return interfaces.ErrUnknownBackend
+			}
+		}
+
+		if frontend.Mirror != nil && !backendNames[frontend.Mirror.BackendName] {
+			return interfaces.ErrUnknownBackend
+		}
+
+		key := frontend.RouteKey()
+		if routeKeys[key] {
+			return interfaces.ErrDuplicateDomain
+		}
+
+		routeKeys[key] = true
+	}
+
+	return nil
+}
+
+// ServerHealth returns the health state of all server addresses of all
+// backends, in the order of the backends.
+func (r *Router) ServerHealth() []*ServerHealth {
+	r.RLock()
+	defer r.RUnlock()
+
+	var health []*ServerHealth
+	for _, h := range r.backends {
+		health = append(health, h.healthChecker.health()...)
+	}
+
+	return health
+}
+
+// CircuitStates returns the circuit breaker state of all server addresses
+// of backends with a circuit breaker.
+func (r *Router) CircuitStates() []*CircuitState {
+	r.RLock()
+	defer r.RUnlock()
+
+	var states []*CircuitState
+	for _, h := range r.backends {
+		states = append(states, h.circuitStates()...)
+	}
+
+	return states
+}
+
+// MirrorStats returns the statistics of all mirrored frontends since the
+// last configuration update.
+func (r *Router) MirrorStats() []*MirrorStats {
+	r.RLock()
+	defer r.RUnlock()
+
+	var stats []*MirrorStats
+	for _, m := range r.mirrors {
+		stats = append(stats, m.statistics())
+	}
+
+	return stats
+}
+
+// KnownHost returns whether the host matches the domain of any frontend.
+func (r *Router) KnownHost(host string) bool {
+	r.RLock()
+	defer r.RUnlock()
+
+	for _, h := range r.frontendHosts {
+		if h.matchHost(host) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ClientCAs returns the CA certificates accepted from clients connecting
+// using the server name, which are those of all frontends for the server
+// name authenticating clients. A certificate is required only if all these
+// frontends require one. It returns nil if none authenticate clients.
+func (r *Router) ClientCAs(serverName string) (*x509.CertPool, bool) {
+	r.RLock()
+	defer r.RUnlock()
+
+	var pool *x509.CertPool
+	required := true
+
+	for _, h := range r.frontendHosts {
+		if !h.matchHost(serverName) {
+			continue
+		}
+
+		if h.clientAuth == nil {
+			required = false
+			continue
+		}
+
+		if pool == nil {
+			pool = x509.NewCertPool()
+		}
+
+		for _, ca := range h.clientAuth.cas {
+			pool.AddCert(ca)
+		}
+
+		required = required && h.clientAuth.required
+	}
+
+	if pool == nil {
+		return nil, false
+	}
+
+	return pool, required
+}
@@ -0,0 +1,122 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/off-sync/platform-proxy/app/interfaces"
+	"github.com/off-sync/platform-proxy/domain/sites"
+)
+
+type nopLogger struct{}
+
+func (l nopLogger) WithField(key string, value interface{}) interfaces.Logger { return l }
+func (l nopLogger) WithError(err error) interfaces.Logger                     { return l }
+func (l nopLogger) Debug(msg string)                                          {}
+func (l nopLogger) Info(msg string)                                           {}
+func (l nopLogger) Warn(msg string)                                           {}
+func (l nopLogger) Error(msg string)                                          {}
+func (l nopLogger) Fatal(msg string)                                          { panic(msg) }
+
+// newTestRouter creates a router for the configuration. Requests not
+// matching any frontend are answered with 404 Not Found.
+func newTestRouter(t *testing.T, backends []*sites.Backend, frontends []*sites.Frontend, options ...Option) *Router {
+	r := New(nopLogger{}, http.NotFoundHandler(), options...)
+
+	if err := r.Update(backends, frontends); err != nil {
+		t.Fatal(err)
+	}
+
+	// closes the backend handlers
+	t.Cleanup(func() { r.Update(nil, nil) })
+
+	return r
+}
+
+func newTestBackend(t *testing.T, name string, servers ...string) *sites.Backend {
+	backend, err := sites.NewBackend(name, servers...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return backend
+}
+
+func TestPrepareKeepsConfigurationUntilApplied(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	backends := []*sites.Backend{newTestBackend(t, "www", srv.URL)}
+	rtr := newTestRouter(t, backends, []*sites.Frontend{sites.NewFrontend("www", "www.example.com")})
+
+	status := func(host string) int {
+		w := httptest.NewRecorder()
+		rtr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://"+host+"/", nil))
+
+		return w.Code
+	}
+
+	update, err := rtr.Prepare(backends, []*sites.Frontend{sites.NewFrontend("www", "api.example.com")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status("www.example.com") != http.StatusOK || status("api.example.com") != http.StatusNotFound {
+		t.Fatal("expected prepared configuration not to be used")
+	}
+
+	update.Discard()
+
+	if status("www.example.com") != http.StatusOK {
+		t.Fatal("expected discarded configuration not to be used")
+	}
+
+	update, err = rtr.Prepare(backends, []*sites.Frontend{sites.NewFrontend("www", "api.example.com")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	update.Apply()
+
+	if status("www.example.com") != http.StatusNotFound || status("api.example.com") != http.StatusOK {
+		t.Fatal("expected applied configuration to be used")
+	}
+}
+
+func TestFailedPrepareClosesBackendHandlers(t *testing.T) {
+	rtr := New(nopLogger{}, http.NotFoundHandler())
+
+	backends := []*sites.Backend{newTestBackend(t, "www", "http://127.0.0.1:1")}
+
+	// the client authentication fails after the backend handler is created
+	frontend := sites.NewFrontend("www", "www.example.com")
+	frontend.ClientAuth = &sites.ClientAuth{CAFile: filepath.Join(t.TempDir(), "missing.pem")}
+
+	if _, err := rtr.Prepare(backends, []*sites.Frontend{frontend}); err == nil {
+		t.Fatal("expected error")
+	}
+
+	// Prepare discards the update holding the handlers created before the error
+	u := &routerUpdate{router: rtr}
+	if err := rtr.prepare(u, backends, []*sites.Frontend{frontend}); err == nil {
+		t.Fatal("expected error")
+	}
+
+	if len(u.backends) != 1 {
+		t.Fatalf("expected 1 backend handler, got %d", len(u.backends))
+	}
+
+	u.Discard()
+
+	for _, h := range u.backends {
+		h.healthChecker.Lock()
+		closed := h.healthChecker.closed
+		h.healthChecker.Unlock()
+
+		if !closed {
+			t.Error("expected backend handler to be closed")
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package router
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/off-sync/platform-proxy/domain/sites"
+)
+
+// sortFrontends returns the frontends in the order in which they must be
+// matched: exact domains first, followed by wildcards (longest first) and
+// regular expressions. Frontends of the same kind are ordered by priority
+// (highest first), followed by path specificity. Frontends which are
+// still equal, such as overlapping regular expressions with the same
+// priority and path rules, keep the order in which they are provided.
+func sortFrontends(frontends []*sites.Frontend) []*sites.Frontend {
+	sorted := make([]*sites.Frontend, len(frontends))
+	copy(sorted, frontends)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+
+		if a.HostKind() != b.HostKind() {
+			return a.HostKind() < b.HostKind()
+		}
+
+		if a.HostKind() == sites.WildcardHost && len(a.Domain) != len(b.Domain) {
+			return len(a.Domain) > len(b.Domain)
+		}
+
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+
+		// regular expressions are more specific than prefixes
+		if (a.PathRegex != "") != (b.PathRegex != "") {
+			return a.PathRegex != ""
+		}
+
+		// longer prefixes are more specific than shorter ones
+		return len(a.PathPrefix) > len(b.PathPrefix)
+	})
+
+	return sorted
+}
+
+// addRoute adds a route for the frontend to the router, forwarding
+// matching requests to the backend handler. Clients are authenticated if
+// the client authentication policy is not nil.
+func addRoute(m *mux.Router, frontend *sites.Frontend, handler http.Handler, clientAuth *clientAuthPolicy) error {
+	route, err := newRoute(m, frontend)
+	if err != nil {
+		return err
+	}
+
+	if frontend.PathPrefix != "" && frontend.StripPrefix {
+		handler = stripPrefix(frontend.PathPrefix, handler)
+	}
+
+	handler = allowUpgrades(frontend.Upgrade, handler)
+
+	if clientAuth != nil {
+		handler = requireClientCert(clientAuth, handler)
+	}
+
+	if frontend.HSTS != nil {
+		handler = hsts(frontend.HSTS, handler)
+	}
+
+	route.Handler(handler)
+
+	return nil
+}
+
+// addRulesRoute adds a route for the frontend to the rules router, which
+// applies the rules of the frontend before passing matching requests to
+// the next handler. Clients are authenticated before the rules are applied,
+// so a rewrite cannot pass a request to a frontend without authentication.
+func addRulesRoute(m *mux.Router, frontend *sites.Frontend, next http.Handler, clientAuth *clientAuthPolicy) error {
+	route, err := newRoute(m, frontend)
+	if err != nil {
+		return err
+	}
+
+	if len(frontend.Rules) < 1 {
+		route.Handler(next)
+		return nil
+	}
+
+	rules, err := compileRules(frontend.Rules)
+	if err != nil {
+		return err
+	}
+
+	handler := applyRules(rules, next)
+
+	if clientAuth != nil {
+		handler = requireClientCert(clientAuth, handler)
+	}
+
+	if frontend.HSTS != nil {
+		handler = hsts(frontend.HSTS, handler)
+	}
+
+	route.Handler(handler)
+
+	return nil
+}
+
+// newRoute creates a route matching the domain and path rules of the frontend.
+func newRoute(m *mux.Router, frontend *sites.Frontend) (*mux.Route, error) {
+	matchHost, err := frontend.HostMatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	route := m.MatcherFunc(func(r *http.Request, rm *mux.RouteMatch) bool {
+		return matchHost(r.Host)
+	})
+
+	if frontend.PathPrefix != "" {
+		route = route.MatcherFunc(func(r *http.Request, rm *mux.RouteMatch) bool {
+			return hasPathPrefix(r.URL.Path, frontend.PathPrefix)
+		})
+	}
+
+	if frontend.PathRegex != "" {
+		re, err := regexp.Compile(frontend.PathRegex)
+		if err != nil {
+			return nil, err
+		}
+
+		route = route.MatcherFunc(func(r *http.Request, rm *mux.RouteMatch) bool {
+			return re.MatchString(r.URL.Path)
+		})
+	}
+
+	return route, nil
+}
+
+// hasPathPrefix returns whether the path starts with the prefix on a
+// segment boundary: '/api' matches '/api' and '/api/users', but not
+// '/apifoo'. A prefix ending with a slash matches all paths below it.
+func hasPathPrefix(path, prefix string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+
+	return len(path) == len(prefix) ||
+		strings.HasSuffix(prefix, "/") ||
+		path[len(prefix)] == '/'
+}
+
+// stripPrefix removes the prefix from the request path, ensuring the
+// resulting path starts with a slash.
+func stripPrefix(prefix string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, prefix)
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+
+		r2 := new(http.Request)
+		*r2 = *r
+		r2.URL = new(url.URL)
+		*r2.URL = *r.URL
+		r2.URL.Path = path
+		r2.URL.RawPath = ""
+		r2.RequestURI = r2.URL.RequestURI()
+
+		handler.ServeHTTP(w, r2)
+	})
+}
@@ -0,0 +1,166 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/off-sync/platform-proxy/app/interfaces"
+	"github.com/off-sync/platform-proxy/domain/sites"
+)
+
+func TestHasPathPrefix(t *testing.T) {
+	tests := []struct {
+		path, prefix string
+		expected     bool
+	}{
+		{"/api", "/api", true},
+		{"/api/", "/api", true},
+		{"/api/users", "/api", true},
+		{"/apifoo", "/api", false},
+		{"/ap", "/api", false},
+		{"/api/users", "/api/", true},
+		{"/api", "/api/", false},
+		{"/anything", "/", true},
+	}
+
+	for _, test := range tests {
+		if actual := hasPathPrefix(test.path, test.prefix); actual != test.expected {
+			t.Errorf("hasPathPrefix(%q, %q): expected %v, got %v", test.path, test.prefix, test.expected, actual)
+		}
+	}
+}
+
+func TestPathPrefixMatchesWholeSegments(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+	}))
+	defer srv.Close()
+
+	frontend := sites.NewFrontend("api", "example.com")
+	frontend.PathPrefix = "/api"
+	frontend.StripPrefix = true
+
+	rtr := newTestRouter(t, []*sites.Backend{newTestBackend(t, "api", srv.URL)}, []*sites.Frontend{frontend})
+
+	tests := []struct {
+		path     string
+		status   int
+		expected string
+	}{
+		{"/api/users", http.StatusOK, "/users"},
+		{"/api", http.StatusOK, "/"},
+		{"/apifoo", http.StatusNotFound, ""},
+	}
+
+	for _, test := range tests {
+		paths = nil
+
+		w := httptest.NewRecorder()
+		rtr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://example.com"+test.path, nil))
+
+		if w.Code != test.status {
+			t.Errorf("%s: expected status %d, got %d", test.path, test.status, w.Code)
+		}
+
+		if test.expected != "" && (len(paths) != 1 || paths[0] != test.expected) {
+			t.Errorf("%s: expected backend path %s, got %v", test.path, test.expected, paths)
+		}
+	}
+}
+
+func TestOverlappingRegexHostsUseDeclarationOrder(t *testing.T) {
+	var hits []string
+	newServer := func(name string) *httptest.Server {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits = append(hits, name)
+		}))
+		t.Cleanup(srv.Close)
+
+		return srv
+	}
+
+	first := newServer("first")
+	second := newServer("second")
+
+	backends := []*sites.Backend{
+		newTestBackend(t, "first", first.URL),
+		newTestBackend(t, "second", second.URL),
+	}
+
+	frontends := []*sites.Frontend{
+		sites.NewFrontend("first", `~^a\.`),
+		sites.NewFrontend("second", `~\.example\.com$`),
+	}
+
+	rtr := newTestRouter(t, backends, frontends)
+
+	tests := []struct {
+		host, expected string
+	}{
+		{"a.example.com", "first"},
+		{"b.example.com", "second"},
+	}
+
+	for _, test := range tests {
+		hits = nil
+
+		w := httptest.NewRecorder()
+		rtr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://"+test.host+"/", nil))
+
+		if len(hits) != 1 || hits[0] != test.expected {
+			t.Errorf("%s: expected backend %s, got %v", test.host, test.expected, hits)
+		}
+	}
+}
+
+// warnLogger records the messages logged as warnings.
+type warnLogger struct {
+	nopLogger
+	warnings *[]string
+}
+
+func (l warnLogger) WithField(key string, value interface{}) interfaces.Logger { return l }
+func (l warnLogger) Warn(msg string)                                           { *l.warnings = append(*l.warnings, msg) }
+
+func TestOverlappingDomainsAreLogged(t *testing.T) {
+	backends := []*sites.Backend{newTestBackend(t, "www", "http://127.0.0.1:1")}
+
+	tests := []struct {
+		frontends []*sites.Frontend
+		expected  int
+	}{
+		{[]*sites.Frontend{
+			sites.NewFrontend("www", "*.customer.example.com"),
+			sites.NewFrontend("www", `~^shop\.customer\.example\.com$`),
+		}, 1},
+		{[]*sites.Frontend{
+			sites.NewFrontend("www", "*.customer.example.com"),
+			sites.NewFrontend("www", `~^shop\.example\.com$`),
+		}, 0},
+		{[]*sites.Frontend{
+			sites.NewFrontend("www", "*.customer.example.com"),
+			{BackendName: "www", Domain: `~^shop\.customer\.example\.com$`, Priority: 1},
+		}, 0},
+		{[]*sites.Frontend{
+			sites.NewFrontend("www", "*.customer.example.com"),
+			{BackendName: "www", Domain: `~^shop\.customer\.example\.com$`, PathPrefix: "/api"},
+		}, 0},
+	}
+
+	for i, test := range tests {
+		var warnings []string
+		rtr := New(warnLogger{warnings: &warnings}, http.NotFoundHandler())
+
+		if err := rtr.Update(backends, test.frontends); err != nil {
+			t.Fatal(err)
+		}
+
+		rtr.Update(nil, nil)
+
+		if len(warnings) != test.expected {
+			t.Errorf("%d: expected %d warnings, got %v", i, test.expected, warnings)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package router
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/off-sync/platform-proxy/domain/sites"
+)
+
+type compiledRule struct {
+	*sites.Rule
+	re *regexp.Regexp
+}
+
+func compileRules(rules []*sites.Rule) ([]*compiledRule, error) {
+	compiled := make([]*compiledRule, len(rules))
+	for i, rule := range rules {
+		compiled[i] = &compiledRule{Rule: rule}
+
+		if rule.PathRegex == "" {
+			continue
+		}
+
+		re, err := regexp.Compile(rule.PathRegex)
+		if err != nil {
+			return nil, err
+		}
+
+		compiled[i].re = re
+	}
+
+	return compiled, nil
+}
+
+// match returns whether the rule applies to the path and, if so,
+// its target with all references to capture groups expanded.
+func (r *compiledRule) match(path string) (bool, string) {
+	if r.re == nil {
+		return true, r.Target
+	}
+
+	m := r.re.FindStringSubmatchIndex(path)
+	if m == nil {
+		return false, ""
+	}
+
+	return true, string(r.re.ExpandString(nil, r.Target, path, m))
+}
+
+// applyRules applies the rules to each request in order. Redirects and
+// static responses end the processing of the request. Rewrites change
+// the path of the request, after which it is passed to the next handler.
+func applyRules(rules []*compiledRule, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, rule := range rules {
+			matches, target := rule.match(r.URL.Path)
+			if !matches {
+				continue
+			}
+
+			switch rule.Action {
+			case sites.RedirectAction:
+				redirect(w, r, rule, target)
+				return
+
+			case sites.StaticAction:
+				respond(w, rule)
+				return
+
+			case sites.RewriteAction:
+				r = rewrite(r, target)
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func redirect(w http.ResponseWriter, r *http.Request, rule *compiledRule, location string) {
+	status := rule.Status
+	if status == 0 {
+		status = http.StatusMovedPermanently
+	}
+
+	if !strings.Contains(location, "?") && r.URL.RawQuery != "" {
+		location += "?" + r.URL.RawQuery
+	}
+
+	http.Redirect(w, r, location, status)
+}
+
+func respond(w http.ResponseWriter, rule *compiledRule) {
+	status := rule.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	if rule.ContentType != "" {
+		w.Header().Set("Content-Type", rule.ContentType)
+	}
+
+	w.WriteHeader(status)
+	w.Write([]byte(rule.Body))
+}
+
+// rewrite returns a copy of the request using the target as its path,
+// and optionally its query string.
+func rewrite(r *http.Request, target string) *http.Request {
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.URL = new(url.URL)
+	*r2.URL = *r.URL
+
+	if i := strings.Index(target, "?"); i >= 0 {
+		r2.URL.RawQuery = target[i+1:]
+		target = target[:i]
+	}
+
+	r2.URL.Path = target
+	r2.URL.RawPath = ""
+	r2.RequestURI = r2.URL.RequestURI()
+
+	return r2
+}
@@ -0,0 +1,102 @@
+package router
+
+import (
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/off-sync/platform-proxy/domain/sites"
+)
+
+// splitHandler distributes requests over the backends of a frontend in
+// proportion to their weights. Clients can select a backend by name using
+// the split override.
+type splitHandler struct {
+	override *sites.SplitOverride
+	choices  []*splitChoice
+	byName   map[string]http.Handler
+	total    int
+	rnd      *lockedRand
+}
+
+type splitChoice struct {
+	handler http.Handler
+	weight  int
+}
+
+// newFrontendHandler returns the handler forwarding requests of the
+// frontend to its backend or backends. It returns false if the frontend
+// has no backend, in which case all requests are handled by its rules.
+func newFrontendHandler(frontend *sites.Frontend, backendHandlers map[string]http.Handler) (http.Handler, bool) {
+	if len(frontend.Splits) < 1 {
+		handler, found := backendHandlers[frontend.BackendName]
+		return handler, found
+	}
+
+	h := &splitHandler{
+		override: frontend.SplitOverride,
+		byName:   make(map[string]http.Handler),
+		rnd:      &lockedRand{r: rand.New(rand.NewSource(time.Now().UnixNano()))},
+	}
+
+	for _, split := range frontend.Splits {
+		handler := backendHandlers[split.BackendName]
+
+		h.byName[split.BackendName] = handler
+
+		if split.Weight > 0 {
+			h.choices = append(h.choices, &splitChoice{
+				handler: handler,
+				weight:  split.Weight,
+			})
+			h.total += split.Weight
+		}
+	}
+
+	if h.total < 1 {
+		// only reachable using the override: use the first backend
+		// for all other requests
+		h.choices = append(h.choices, &splitChoice{
+			handler: backendHandlers[frontend.Splits[0].BackendName],
+			weight:  1,
+		})
+		h.total = 1
+	}
+
+	return h, true
+}
+
+func (h *splitHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if handler, found := h.byName[h.overrideValue(req)]; found {
+		handler.ServeHTTP(w, req)
+		return
+	}
+
+	n := h.rnd.intn(h.total)
+	for _, c := range h.choices {
+		if n < c.weight {
+			c.handler.ServeHTTP(w, req)
+			return
+		}
+
+		n -= c.weight
+	}
+}
+
+// overrideValue returns the name of the backend selected by the client, or
+// an empty string if there is none.
+func (h *splitHandler) overrideValue(req *http.Request) string {
+	if h.override == nil {
+		return ""
+	}
+
+	if h.override.Header != "" {
+		return req.Header.Get(h.override.Header)
+	}
+
+	if c, err := req.Cookie(h.override.Cookie); err == nil {
+		return c.Value
+	}
+
+	return ""
+}
@@ -0,0 +1,79 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/off-sync/platform-proxy/domain/sites"
+)
+
+func newSplitTestConfig(t *testing.T, servers map[string]string, weights map[string]int) ([]*sites.Backend, []*sites.Frontend) {
+	frontend := sites.NewFrontend("a", "example.com")
+
+	var backends []*sites.Backend
+	for _, name := range []string{"a", "b"} {
+		backends = append(backends, newTestBackend(t, name, servers[name]))
+		frontend.Splits = append(frontend.Splits, &sites.BackendSplit{
+			BackendName: name,
+			Weight:      weights[name],
+		})
+	}
+
+	return backends, []*sites.Frontend{frontend}
+}
+
+func TestSplitWeightsChangeWithRequestInFlight(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	a := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(started)
+			<-release
+		}
+
+		w.Write([]byte("a"))
+	}))
+	defer a.Close()
+
+	b := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("b"))
+	}))
+	defer b.Close()
+
+	servers := map[string]string{"a": a.URL, "b": b.URL}
+
+	backends, frontends := newSplitTestConfig(t, servers, map[string]int{"a": 100})
+	rtr := newTestRouter(t, backends, frontends)
+
+	inFlight := make(chan *httptest.ResponseRecorder)
+	go func() {
+		w := httptest.NewRecorder()
+		rtr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://example.com/slow", nil))
+		inFlight <- w
+	}()
+
+	<-started
+
+	// move all traffic to b while the request to a is in progress
+	backends, frontends = newSplitTestConfig(t, servers, map[string]int{"b": 100})
+	if err := rtr.Update(backends, frontends); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 10; i++ {
+		w := httptest.NewRecorder()
+		rtr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+
+		if w.Code != http.StatusOK || w.Body.String() != "b" {
+			t.Fatalf("expected response of b after the update, got %d %q", w.Code, w.Body.String())
+		}
+	}
+
+	close(release)
+
+	if w := <-inFlight; w.Code != http.StatusOK || w.Body.String() != "a" {
+		t.Errorf("expected the request in flight to complete on a, got %d %q", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,176 @@
+package router
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/off-sync/platform-proxy/domain/sites"
+)
+
+const (
+	stickyIDBytes  = 8
+	stickySigBytes = 16
+)
+
+// stickySessions routes requests with a valid affinity cookie to the
+// server address in the cookie, if the load balancer still has it. Other
+// requests are load balanced, and receive a cookie for the selected server.
+//
+// The cookie value is opaque: it holds an identifier derived from the
+// server address and an expiry time, signed using the secret.
+type stickySessions struct {
+	settings *sites.StickySession
+	secret   []byte
+	lb       loadBalancer
+	next     http.Handler
+
+	sync.Mutex
+	ids map[string]string
+}
+
+type stickyKey struct{}
+
+// newStickySessions creates sticky sessions. As the load balancer is
+// created with the handler returned by wrap, it must be set before use.
+func newStickySessions(settings *sites.StickySession, secret []byte) *stickySessions {
+	return &stickySessions{
+		settings: settings,
+		secret:   secret,
+		ids:      make(map[string]string),
+	}
+}
+
+// wrap returns the handler passed to the load balancer, which sets the
+// cookie for the selected server address. Requests routed using a valid
+// cookie are passed to next directly.
+func (s *stickySessions) wrap(next http.Handler) http.Handler {
+	s.next = next
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if current, _ := req.Context().Value(stickyKey{}).(string); current != s.id(req.URL.Host) {
+			http.SetCookie(w, s.cookie(req.URL.Host))
+		}
+
+		next.ServeHTTP(w, req)
+	})
+}
+
+func (s *stickySessions) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	id := s.cookieID(req)
+	if id == "" || len(triedHosts(req)) > 0 {
+		// a retry selects another server, which sets a new cookie
+		s.lb.ServeHTTP(w, req)
+		return
+	}
+
+	ctx := context.WithValue(req.Context(), stickyKey{}, id)
+
+	for _, u := range s.lb.Servers() {
+		if s.id(u.Host) != id {
+			continue
+		}
+
+		r2 := req.WithContext(ctx)
+		r2.URL = new(url.URL)
+		*r2.URL = *u
+
+		s.next.ServeHTTP(w, r2)
+
+		return
+	}
+
+	// the server has been removed: select another one
+	s.lb.ServeHTTP(w, req.WithContext(ctx))
+}
+
+// cookieID returns the server identifier in the cookie of the request, or
+// an empty string if the cookie is missing, expired or invalid.
+func (s *stickySessions) cookieID(req *http.Request) string {
+	c, err := req.Cookie(s.settings.Name())
+	if err != nil {
+		return ""
+	}
+
+	parts := strings.Split(c.Value, ".")
+	if len(parts) != 2 {
+		return ""
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || len(payload) != stickyIDBytes+8 {
+		return ""
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(sig, s.sign(payload)) {
+		return ""
+	}
+
+	expires := int64(binary.BigEndian.Uint64(payload[stickyIDBytes:]))
+	if expires > 0 && time.Now().Unix() > expires {
+		return ""
+	}
+
+	return string(payload[:stickyIDBytes])
+}
+
+// cookie creates a cookie for the server address.
+func (s *stickySessions) cookie(host string) *http.Cookie {
+	c := &http.Cookie{
+		Name:     s.settings.Name(),
+		Path:     "/",
+		Secure:   s.settings.Secure,
+		HttpOnly: s.settings.HTTPOnly,
+	}
+
+	var expires int64
+	if s.settings.TTL > 0 {
+		c.Expires = time.Now().Add(s.settings.TTL)
+		c.MaxAge = int(s.settings.TTL / time.Second)
+		expires = c.Expires.Unix()
+	}
+
+	payload := make([]byte, stickyIDBytes+8)
+	copy(payload, s.id(host))
+	binary.BigEndian.PutUint64(payload[stickyIDBytes:], uint64(expires))
+
+	c.Value = base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(s.sign(payload))
+
+	return c
+}
+
+// id returns the identifier of the server address, which does not reveal
+// the address.
+func (s *stickySessions) id(host string) string {
+	s.Lock()
+	defer s.Unlock()
+
+	if id, found := s.ids[host]; found {
+		return id
+	}
+
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte("server:" + host))
+	id := string(mac.Sum(nil)[:stickyIDBytes])
+
+	s.ids[host] = id
+
+	return id
+}
+
+func (s *stickySessions) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte("cookie:"))
+	mac.Write(payload)
+
+	return mac.Sum(nil)[:stickySigBytes]
+}
@@ -0,0 +1,172 @@
+package router
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/off-sync/platform-proxy/app/interfaces"
+	"github.com/off-sync/platform-proxy/domain/sites"
+)
+
+// serverDialer connects to the server addresses selected by the load
+// balancer. The certificates of TLS servers are verified using the host
+// name of the server, rather than the IP address used by the load balancer.
+type serverDialer struct {
+	dialer           *net.Dialer
+	tlsConfig        *tls.Config
+	handshakeTimeout time.Duration
+	serverNames      *serverNames
+}
+
+// dial connects to the address.
+func (d *serverDialer) dial(network, addr string) (net.Conn, error) {
+	return d.dialer.Dial(network, addr)
+}
+
+// dialTLS connects to the address and performs the TLS handshake, offering
+// the protocols using ALPN.
+func (d *serverDialer) dialTLS(network, addr string, nextProtos []string) (net.Conn, error) {
+	cfg := d.tlsConfig.Clone()
+	cfg.NextProtos = nextProtos
+
+	if cfg.ServerName == "" {
+		cfg.ServerName = d.serverName(addr)
+	}
+
+	conn, err := d.dialer.Dial(network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConn := tls.Client(conn, cfg)
+
+	if d.handshakeTimeout > 0 {
+		conn.SetDeadline(time.Now().Add(d.handshakeTimeout))
+	}
+
+	if err := tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	conn.SetDeadline(time.Time{})
+
+	return tlsConn, nil
+}
+
+// serverName returns the host name of the server with the address.
+func (d *serverDialer) serverName(addr string) string {
+	if name, ok := d.serverNames.get(addr); ok {
+		return name
+	}
+
+	host, _, _ := net.SplitHostPort(addr)
+
+	return host
+}
+
+// newBackendTLSConfig creates the TLS configuration used to connect to
+// https servers. The client certificate issuer is only required if the
+// backend uses a client certificate issued by the local CA.
+func newBackendTLSConfig(settings *sites.BackendTLS, clientCerts *clientCertIssuer) (*tls.Config, error) {
+	cfg := &tls.Config{}
+	if settings == nil {
+		return cfg, nil
+	}
+
+	cfg.ServerName = settings.ServerName
+	cfg.InsecureSkipVerify = settings.InsecureSkipVerify
+
+	if settings.CAFile != "" {
+		caPEM, err := ioutil.ReadFile(settings.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA file: %s", err)
+		}
+
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", settings.CAFile)
+		}
+	}
+
+	switch {
+	case settings.LocalCAClientCert:
+		if clientCerts == nil {
+			return nil, fmt.Errorf("no local CA to issue the client certificate")
+		}
+
+		cfg.GetClientCertificate = clientCerts.getClientCertificate
+
+	case settings.CertFile != "":
+		cert, err := tls.LoadX509KeyPair(settings.CertFile, settings.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %s", err)
+		}
+
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// clientCertIssuer provides the client certificate of the proxy, issued by
+// the local CA. A new certificate is issued when half of the validity of
+// the current one has passed.
+type clientCertIssuer struct {
+	gen  interfaces.CertGen
+	name string
+
+	sync.Mutex
+	cert    *tls.Certificate
+	renewAt time.Time
+}
+
+func (i *clientCertIssuer) getClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	i.Lock()
+	defer i.Unlock()
+
+	if i.cert != nil && time.Now().Before(i.renewAt) {
+		return i.cert, nil
+	}
+
+	cert, renewAt, err := i.issue()
+	if err != nil {
+		if i.cert != nil {
+			// the current certificate is still valid
+			return i.cert, nil
+		}
+
+		return nil, err
+	}
+
+	i.cert = cert
+	i.renewAt = renewAt
+
+	return cert, nil
+}
+
+func (i *clientCertIssuer) issue() (*tls.Certificate, time.Time, error) {
+	c, err := i.gen.GenCert([]string{i.name})
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("issuing client certificate: %s", err)
+	}
+
+	cert, err := tls.X509KeyPair(c.Certificate, c.PrivateKey)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	renewAt := leaf.NotBefore.Add(leaf.NotAfter.Sub(leaf.NotBefore) / 2)
+
+	return &cert, renewAt, nil
+}
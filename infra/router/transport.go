@@ -0,0 +1,195 @@
+package router
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/off-sync/platform-proxy/domain/sites"
+	"golang.org/x/net/http2"
+)
+
+const (
+	schemeHTTP  = "http"
+	schemeHTTPS = "https"
+	schemeH2C   = "h2c"
+	schemeGRPC  = "grpc"
+)
+
+// backendScheme returns the URL scheme shared by all servers of a backend.
+func backendScheme(backend *sites.Backend) (string, error) {
+	scheme := schemeHTTP
+	for i, server := range backend.Servers {
+		if i == 0 {
+			scheme = server.URL.Scheme
+			continue
+		}
+
+		if server.URL.Scheme != scheme {
+			return "", fmt.Errorf("servers use different schemes: %s and %s", scheme, server.URL.Scheme)
+		}
+	}
+
+	return scheme, nil
+}
+
+// newTransport creates the transport used to forward requests to servers
+// with the provided URL scheme:
+//
+//	http:  HTTP/1.1
+//	https: HTTP/2 if negotiated using ALPN, HTTP/1.1 otherwise
+//	h2c:   HTTP/2 over plain TCP (prior knowledge)
+//	grpc:  gRPC over HTTP/2 over plain TCP
+//
+// HTTP/2 connections are shared by all requests to a server, so the idle
+// connection settings do not apply to them. h2c and gRPC connections are
+// checked using HTTP/2 pings on the keep-alive interval when they are idle.
+func newTransport(scheme string, settings *sites.Transport, dialer *serverDialer) (http.RoundTripper, error) {
+	switch scheme {
+	case schemeHTTP, schemeHTTPS:
+		t := &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           dialer.dialer.DialContext,
+			ResponseHeaderTimeout: settings.ResponseHeaderTimeout,
+			IdleConnTimeout:       settings.IdleConnTimeout,
+			MaxIdleConnsPerHost:   settings.MaxIdleConnsPerHost,
+			ExpectContinueTimeout: 1 * time.Second,
+		}
+
+		if scheme == schemeHTTP {
+			return t, nil
+		}
+
+		nextProtos := []string{"h2", "http/1.1"}
+		if settings.DisableHTTP2 {
+			nextProtos = []string{"http/1.1"}
+		}
+
+		// the server name of the address is only known to the dialer
+		t.DialTLS = func(network, addr string) (net.Conn, error) {
+			return dialer.dialTLS(network, addr, nextProtos)
+		}
+
+		if !settings.DisableHTTP2 {
+			if err := http2.ConfigureTransport(t); err != nil {
+				return nil, err
+			}
+		}
+
+		return t, nil
+
+	case schemeH2C, schemeGRPC:
+		var t http.RoundTripper = &http2.Transport{
+			AllowHTTP: true,
+			DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+				return dialer.dial(network, addr)
+			},
+			IdleConnTimeout: settings.IdleConnTimeout,
+			ReadIdleTimeout: settings.KeepAlive,
+		}
+
+		if settings.ResponseHeaderTimeout > 0 {
+			t = &headerTimeoutTransport{
+				RoundTripper: t,
+				timeout:      settings.ResponseHeaderTimeout,
+			}
+		}
+
+		return t, nil
+	}
+
+	return nil, fmt.Errorf("unsupported scheme: %s", scheme)
+}
+
+// validateTransport returns an error for transport settings which do not
+// apply to servers with the provided URL scheme, instead of ignoring them.
+func validateTransport(scheme string, settings *sites.Transport) error {
+	if settings == nil || (scheme != schemeH2C && scheme != schemeGRPC) {
+		return nil
+	}
+
+	switch {
+	case settings.TLSHandshakeTimeout > 0:
+		return fmt.Errorf("TLS handshake timeout requires https servers")
+	case settings.MaxIdleConnsPerHost > 0:
+		return fmt.Errorf("maximum idle connections do not apply to %s servers, which share one connection", scheme)
+	case settings.DisableHTTP2:
+		return fmt.Errorf("HTTP/2 cannot be disabled for %s servers", scheme)
+	}
+
+	return nil
+}
+
+// headerTimeoutTransport limits the time to wait for the response headers,
+// for transports without such a setting.
+type headerTimeoutTransport struct {
+	http.RoundTripper
+	timeout time.Duration
+}
+
+func (t *headerTimeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(req.Context())
+	timer := time.AfterFunc(t.timeout, cancel)
+
+	resp, err := t.RoundTripper.RoundTrip(req.WithContext(ctx))
+	if !timer.Stop() {
+		// the request was canceled before the headers arrived
+		if err == nil {
+			resp.Body.Close()
+		}
+
+		cancel()
+
+		return nil, fmt.Errorf("timeout awaiting response headers")
+	}
+
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// the context must remain valid while the body is read
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+
+	return resp, nil
+}
+
+// cancelBody cancels the context of the request when the response body is
+// closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+
+	return err
+}
+
+// newDialer creates the dialer used to connect to servers.
+func newDialer(settings *sites.Transport) *net.Dialer {
+	return &net.Dialer{
+		Timeout:   settings.DialTimeout,
+		KeepAlive: settings.KeepAlive,
+	}
+}
+
+// forwardURL returns the URL to which requests for a server are forwarded.
+func forwardURL(server *url.URL) *url.URL {
+	u := &url.URL{}
+	*u = *server
+
+	if u.Scheme == schemeH2C || u.Scheme == schemeGRPC {
+		// the transport speaks HTTP/2, but the request URL is plain HTTP
+		u.Scheme = schemeHTTP
+	}
+
+	return u
+}
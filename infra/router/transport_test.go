@@ -0,0 +1,176 @@
+package router
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/off-sync/platform-proxy/domain/sites"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// newProtoServer returns a server answering with the protocol of its requests.
+func newProtoServer() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	})
+}
+
+// proxiedProto sends a request through a router forwarding to the backend
+// and returns the protocol received by the server.
+func proxiedProto(t *testing.T, backend *sites.Backend) string {
+	rtr := newTestRouter(t, []*sites.Backend{backend}, []*sites.Frontend{
+		sites.NewFrontend(backend.Name, "example.com"),
+	})
+
+	w := httptest.NewRecorder()
+	rtr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	return w.Body.String()
+}
+
+func TestBackendProtocols(t *testing.T) {
+	h1 := httptest.NewServer(newProtoServer())
+	defer h1.Close()
+
+	h2 := httptest.NewUnstartedServer(newProtoServer())
+	h2.EnableHTTP2 = true
+	h2.StartTLS()
+	defer h2.Close()
+
+	h2cSrv := httptest.NewServer(h2c.NewHandler(newProtoServer(), &http2.Server{}))
+	defer h2cSrv.Close()
+
+	tests := []struct {
+		name         string
+		server       string
+		disableHTTP2 bool
+		expected     string
+	}{
+		{"http", h1.URL, false, "HTTP/1.1"},
+		{"https", h2.URL, false, "HTTP/2.0"},
+		{"https-http2-disabled", h2.URL, true, "HTTP/1.1"},
+		{"h2c", strings.Replace(h2cSrv.URL, "http://", "h2c://", 1), false, "HTTP/2.0"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			backend := newTestBackend(t, test.name, test.server)
+			if strings.HasPrefix(test.server, "https://") {
+				backend.TLS = &sites.BackendTLS{InsecureSkipVerify: true}
+			}
+			backend.Transport = &sites.Transport{DisableHTTP2: test.disableHTTP2}
+
+			if actual := proxiedProto(t, backend); actual != test.expected {
+				t.Errorf("expected backend protocol %s, got %s", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestUpdateClosesIdleConnectionsOfReplacedBackends(t *testing.T) {
+	closed := make(chan struct{}, 1)
+
+	srv := httptest.NewUnstartedServer(newProtoServer())
+	srv.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateClosed {
+			closed <- struct{}{}
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	backends := []*sites.Backend{newTestBackend(t, "www", srv.URL)}
+	frontends := []*sites.Frontend{sites.NewFrontend("www", "example.com")}
+
+	rtr := newTestRouter(t, backends, frontends)
+
+	w := httptest.NewRecorder()
+	rtr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	// the connection of the request is kept alive until the backend
+	// handler is replaced
+	if err := rtr.Update(backends, frontends); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("expected idle connection to be closed")
+	}
+}
+
+func TestH2CResponseHeaderTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+
+	srv := httptest.NewServer(h2c.NewHandler(slow, &http2.Server{}))
+	defer srv.Close()
+
+	backend := newTestBackend(t, "www", strings.Replace(srv.URL, "http://", "h2c://", 1))
+	backend.Transport = &sites.Transport{ResponseHeaderTimeout: 50 * time.Millisecond}
+
+	rtr := newTestRouter(t, []*sites.Backend{backend}, []*sites.Frontend{
+		sites.NewFrontend("www", "example.com"),
+	})
+
+	done := make(chan int, 1)
+	go func() {
+		w := httptest.NewRecorder()
+		rtr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+		done <- w.Code
+	}()
+
+	select {
+	case code := <-done:
+		if code != http.StatusBadGateway {
+			t.Errorf("expected status 502, got %d", code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected response header timeout")
+	}
+}
+
+func TestUnsupportedH2CTransportSettingsAreRejected(t *testing.T) {
+	tests := []struct {
+		name      string
+		transport *sites.Transport
+	}{
+		{"tls-handshake-timeout", &sites.Transport{TLSHandshakeTimeout: time.Second}},
+		{"max-idle-conns", &sites.Transport{MaxIdleConnsPerHost: 4}},
+		{"disable-http2", &sites.Transport{DisableHTTP2: true}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			backend := newTestBackend(t, "www", "h2c://127.0.0.1:8080")
+			backend.Transport = test.transport
+
+			rtr := New(nopLogger{}, http.NotFoundHandler())
+
+			if err := rtr.Update([]*sites.Backend{backend}, nil); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
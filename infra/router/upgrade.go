@@ -0,0 +1,263 @@
+package router
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/off-sync/platform-proxy/app/interfaces"
+	"github.com/off-sync/platform-proxy/domain/sites"
+)
+
+type contextKey int
+
+const (
+	upgradeIdleTimeoutKey contextKey = iota
+	attemptKey
+)
+
+// isUpgradeRequest checks whether the request asks to upgrade the
+// connection to another protocol.
+func isUpgradeRequest(r *http.Request) bool {
+	if r.Header.Get("Upgrade") == "" {
+		return false
+	}
+
+	for _, c := range r.Header["Connection"] {
+		for _, token := range strings.Split(c, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// allowUpgrades rejects upgrade requests if the policy is nil. Otherwise
+// it passes the idle timeout of the policy to the upgrade forwarder.
+func allowUpgrades(policy *sites.Upgrade, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isUpgradeRequest(r) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		if policy == nil {
+			http.Error(w, "upgrade not allowed", http.StatusForbidden)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), upgradeIdleTimeoutKey, policy.Timeout())
+
+		handler.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// upgradeForwarder tunnels upgraded connections to the server selected by
+// the load balancer. As the tunnel uses a single connection to the server,
+// all traffic of an upgraded connection reaches the same server. Other
+// requests are passed to the next handler.
+type upgradeForwarder struct {
+	next   http.Handler
+	dialer *serverDialer
+	log    interfaces.Logger
+}
+
+// newUpgradeForwarder creates a new upgrade forwarder, which connects to
+// servers using the dialer.
+func newUpgradeForwarder(next http.Handler, dialer *serverDialer, log interfaces.Logger) *upgradeForwarder {
+	return &upgradeForwarder{
+		next:   next,
+		dialer: dialer,
+		log:    log,
+	}
+}
+
+func (f *upgradeForwarder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if !isUpgradeRequest(req) {
+		f.next.ServeHTTP(w, req)
+		return
+	}
+
+	idleTimeout, ok := req.Context().Value(upgradeIdleTimeoutKey).(time.Duration)
+	if !ok {
+		idleTimeout = sites.DefaultUpgradeIdleTimeout
+	}
+
+	log := f.log.
+		WithField("server", req.URL.Host).
+		WithField("upgrade", req.Header.Get("Upgrade"))
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		// HTTP/2 connections cannot be upgraded
+		http.Error(w, "upgrade not supported", http.StatusBadRequest)
+		return
+	}
+
+	serverConn, err := f.dial(req.URL)
+	if err != nil {
+		log.WithError(err).Warn("connecting to server")
+		http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+		return
+	}
+	defer serverConn.Close()
+
+	outReq, err := newUpgradeRequest(req)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if err := outReq.Write(serverConn); err != nil {
+		log.WithError(err).Warn("writing upgrade request")
+		http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+		return
+	}
+
+	serverReader := bufio.NewReader(serverConn)
+
+	resp, err := http.ReadResponse(serverReader, outReq)
+	if err != nil {
+		log.WithError(err).Warn("reading upgrade response")
+		http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		// the server declined the upgrade: pass on its response
+		copyHeader(w.Header(), resp.Header)
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+		return
+	}
+
+	clientConn, clientBuf, err := hj.Hijack()
+	if err != nil {
+		log.WithError(err).Warn("hijacking client connection")
+		return
+	}
+	defer clientConn.Close()
+
+	if err := resp.Write(clientConn); err != nil {
+		log.WithError(err).Warn("writing upgrade response")
+		return
+	}
+
+	log.Debug("connection upgraded")
+
+	t := &tunnel{
+		client:      clientConn,
+		server:      serverConn,
+		idleTimeout: idleTimeout,
+	}
+
+	t.run(clientBuf.Reader, serverReader)
+
+	log.Debug("upgraded connection closed")
+}
+
+// dial connects to the server in the URL set by the load balancer.
+func (f *upgradeForwarder) dial(u *url.URL) (net.Conn, error) {
+	if u.Scheme != schemeHTTPS {
+		return f.dialer.dial("tcp", u.Host)
+	}
+
+	return f.dialer.dialTLS("tcp", u.Host, []string{"http/1.1"})
+}
+
+// newUpgradeRequest creates the request sent to the server. Unlike other
+// requests it keeps the Connection and Upgrade headers.
+func newUpgradeRequest(req *http.Request) (*http.Request, error) {
+	u, err := url.ParseRequestURI(req.RequestURI)
+	if err != nil {
+		return nil, err
+	}
+
+	u.Scheme = req.URL.Scheme
+	u.Host = req.URL.Host
+
+	outReq := &http.Request{
+		Method:     req.Method,
+		URL:        u,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     copyHeader(make(http.Header), req.Header),
+		Host:       req.Host,
+	}
+
+	outReq.Header.Set("Connection", "Upgrade")
+
+	if ip, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		outReq.Header.Add("X-Forwarded-For", ip)
+	}
+
+	return outReq, nil
+}
+
+// tunnel copies data in both directions between an upgraded client
+// connection and the server connection. Both connections are closed when
+// either side closes its connection, or when there is no traffic in either
+// direction for the idle timeout.
+type tunnel struct {
+	client      net.Conn
+	server      net.Conn
+	idleTimeout time.Duration
+}
+
+// run starts the tunnel using readers which may hold data that has already
+// been read from the connections. It returns when the tunnel is closed.
+func (t *tunnel) run(clientReader, serverReader io.Reader) {
+	t.extend()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go t.copy(t.server, clientReader, &wg)
+	go t.copy(t.client, serverReader, &wg)
+
+	wg.Wait()
+}
+
+func (t *tunnel) copy(dst net.Conn, src io.Reader, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	// unblock the other direction
+	defer t.client.Close()
+	defer t.server.Close()
+
+	buf := make([]byte, 32*1024)
+
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			t.extend()
+
+			if _, werr := dst.Write(buf[:n]); werr != nil {
+				return
+			}
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// extend moves the deadline of both connections to the idle timeout
+// from now.
+func (t *tunnel) extend() {
+	deadline := time.Now().Add(t.idleTimeout)
+
+	t.client.SetDeadline(deadline)
+	t.server.SetDeadline(deadline)
+}
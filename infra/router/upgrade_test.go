@@ -0,0 +1,165 @@
+package router
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/off-sync/platform-proxy/domain/sites"
+)
+
+// newEchoUpgradeServer returns a server accepting WebSocket upgrades, after
+// which it echoes all data it receives.
+func newEchoUpgradeServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isUpgradeRequest(r) {
+			http.Error(w, "upgrade required", http.StatusUpgradeRequired)
+			return
+		}
+
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+
+		fmt.Fprint(conn, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+
+		io.Copy(conn, buf)
+	}))
+}
+
+// upgrade connects to the proxy and asks to upgrade the connection. It
+// returns the connection and the status of the upgrade response.
+func upgrade(t *testing.T, proxy *httptest.Server) (net.Conn, *bufio.Reader, int) {
+	conn, err := net.Dial("tcp", proxy.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	fmt.Fprint(conn, "GET /ws HTTP/1.1\r\nHost: example.com\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\n")
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("reading upgrade response: %s", err)
+	}
+
+	return conn, reader, resp.StatusCode
+}
+
+// echo sends a message over an upgraded connection and checks whether it
+// is echoed back.
+func echo(t *testing.T, conn net.Conn, reader *bufio.Reader, msg string) {
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	if _, err := fmt.Fprintln(conn, msg); err != nil {
+		t.Fatalf("sending %s: %s", msg, err)
+	}
+
+	line, err := reader.ReadString('\n')
+	if err != nil || strings.TrimSpace(line) != msg {
+		t.Fatalf("expected %s to be echoed, got %q (%v)", msg, line, err)
+	}
+}
+
+func newUpgradeTestConfig(t *testing.T, srv *httptest.Server, upgrade *sites.Upgrade) ([]*sites.Backend, []*sites.Frontend) {
+	frontend := sites.NewFrontend("ws", "example.com")
+	frontend.Upgrade = upgrade
+
+	return []*sites.Backend{newTestBackend(t, "ws", srv.URL)}, []*sites.Frontend{frontend}
+}
+
+func TestUpgradeAllowed(t *testing.T) {
+	srv := newEchoUpgradeServer(t)
+	defer srv.Close()
+
+	backends, frontends := newUpgradeTestConfig(t, srv, &sites.Upgrade{})
+	proxy := httptest.NewServer(newTestRouter(t, backends, frontends))
+	defer proxy.Close()
+
+	conn, reader, status := upgrade(t, proxy)
+	if status != http.StatusSwitchingProtocols {
+		t.Fatalf("expected status 101, got %d", status)
+	}
+
+	echo(t, conn, reader, "hello")
+	echo(t, conn, reader, "again")
+}
+
+func TestUpgradeNotAllowed(t *testing.T) {
+	srv := newEchoUpgradeServer(t)
+	defer srv.Close()
+
+	backends, frontends := newUpgradeTestConfig(t, srv, nil)
+	proxy := httptest.NewServer(newTestRouter(t, backends, frontends))
+	defer proxy.Close()
+
+	if _, _, status := upgrade(t, proxy); status != http.StatusForbidden {
+		t.Fatalf("expected status 403, got %d", status)
+	}
+}
+
+func TestUpgradeIdleTimeout(t *testing.T) {
+	srv := newEchoUpgradeServer(t)
+	defer srv.Close()
+
+	backends, frontends := newUpgradeTestConfig(t, srv, &sites.Upgrade{IdleTimeout: 200 * time.Millisecond})
+	proxy := httptest.NewServer(newTestRouter(t, backends, frontends))
+	defer proxy.Close()
+
+	conn, reader, status := upgrade(t, proxy)
+	if status != http.StatusSwitchingProtocols {
+		t.Fatalf("expected status 101, got %d", status)
+	}
+
+	// traffic extends the idle timeout
+	for i := 0; i < 3; i++ {
+		echo(t, conn, reader, "hello")
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	start := time.Now()
+	if _, err := reader.ReadByte(); err != io.EOF {
+		t.Fatalf("expected the connection to be closed, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected the connection to be closed after 200ms, took %s", elapsed)
+	}
+}
+
+func TestUpgradedConnectionSurvivesUpdate(t *testing.T) {
+	srv := newEchoUpgradeServer(t)
+	defer srv.Close()
+
+	backends, frontends := newUpgradeTestConfig(t, srv, &sites.Upgrade{})
+	rtr := newTestRouter(t, backends, frontends)
+	proxy := httptest.NewServer(rtr)
+	defer proxy.Close()
+
+	conn, reader, status := upgrade(t, proxy)
+	if status != http.StatusSwitchingProtocols {
+		t.Fatalf("expected status 101, got %d", status)
+	}
+
+	echo(t, conn, reader, "before")
+
+	// replaces and closes the backend handler of the connection
+	backends, frontends = newUpgradeTestConfig(t, srv, &sites.Upgrade{})
+	if err := rtr.Update(backends, frontends); err != nil {
+		t.Fatal(err)
+	}
+
+	echo(t, conn, reader, "after")
+}
@@ -0,0 +1,108 @@
+package tcpproxy
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/off-sync/platform-proxy/domain/sites"
+	"github.com/off-sync/platform-proxy/infra/proxyproto"
+)
+
+var errPeeked = errors.New("peeked at ClientHello")
+
+// peekServerName reads the TLS ClientHello from the reader and returns the
+// server name (SNI) it contains, together with all bytes read, which must
+// be replayed to whoever handles the connection next.
+func peekServerName(r io.Reader) (string, []byte, error) {
+	var peeked bytes.Buffer
+	var hello *tls.ClientHelloInfo
+
+	err := tls.Server(readOnlyConn{r: io.TeeReader(r, &peeked)}, &tls.Config{
+		GetConfigForClient: func(chi *tls.ClientHelloInfo) (*tls.Config, error) {
+			hello = chi
+			return nil, errPeeked
+		},
+	}).Handshake()
+
+	if hello == nil {
+		return "", peeked.Bytes(), err
+	}
+
+	return hello.ServerName, peeked.Bytes(), nil
+}
+
+// readOnlyConn allows a TLS handshake to be started on data from a reader.
+// Writes are discarded.
+type readOnlyConn struct {
+	r io.Reader
+}
+
+func (c readOnlyConn) Read(p []byte) (int, error)         { return c.r.Read(p) }
+func (c readOnlyConn) Write(p []byte) (int, error)        { return len(p), nil }
+func (c readOnlyConn) Close() error                       { return nil }
+func (c readOnlyConn) LocalAddr() net.Addr                { return nil }
+func (c readOnlyConn) RemoteAddr() net.Addr               { return nil }
+func (c readOnlyConn) SetDeadline(t time.Time) error      { return nil }
+func (c readOnlyConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c readOnlyConn) SetWriteDeadline(t time.Time) error { return nil }
+
+// prefixConn replays bytes that have already been read from the connection
+// before reading from the connection itself.
+type prefixConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func newPrefixConn(conn net.Conn, prefix []byte) *prefixConn {
+	return &prefixConn{
+		Conn: conn,
+		r:    io.MultiReader(bytes.NewReader(prefix), conn),
+	}
+}
+
+func (c *prefixConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// splice connects the client to the address using the dialer and copies
+// data in both directions until either side closes its connection. The
+// PROXY protocol header, if any, passes the addresses of the client
+// connection.
+func splice(client net.Conn, dialer *net.Dialer, addr string, proxyProtocol sites.ProxyProtocol) error {
+	defer client.Close()
+
+	server, err := dialer.Dial("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer server.Close()
+
+	if err := proxyproto.WriteHeader(server, proxyProtocol, client.RemoteAddr(), client.LocalAddr()); err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	pipe := func(dst, src net.Conn) {
+		defer wg.Done()
+
+		io.Copy(dst, src)
+
+		// unblock the other direction
+		dst.Close()
+		src.Close()
+	}
+
+	go pipe(server, client)
+	go pipe(client, server)
+
+	wg.Wait()
+
+	return nil
+}
@@ -0,0 +1,208 @@
+package tcpproxy
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/off-sync/platform-proxy/app/interfaces"
+)
+
+// helloTimeout limits the time a client can take to send its ClientHello.
+const helloTimeout = 10 * time.Second
+
+// sniListener accepts connections from the wrapped listener, and splices
+// those for passthrough frontends to their backend. Other connections are
+// returned by Accept, ready for TLS termination.
+type sniListener struct {
+	net.Listener
+	proxy *Proxy
+	conns chan net.Conn
+	errs  chan error
+	done  chan struct{}
+	once  sync.Once
+}
+
+// Listen wraps the listener of the TLS server. Connections for passthrough
+// frontends are forwarded to their backends. All other connections are
+// returned by the listener with their ClientHello intact.
+func (p *Proxy) Listen(ln net.Listener) net.Listener {
+	l := &sniListener{
+		Listener: ln,
+		proxy:    p,
+		conns:    make(chan net.Conn),
+		errs:     make(chan error),
+		done:     make(chan struct{}),
+	}
+
+	go l.acceptLoop()
+
+	return l
+}
+
+func (l *sniListener) acceptLoop() {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			select {
+			case l.errs <- err:
+			case <-l.done:
+				return
+			}
+
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				continue
+			}
+
+			return
+		}
+
+		// the ClientHello is read in the background, so that slow
+		// clients do not block other connections
+		go l.handle(conn)
+	}
+}
+
+func (l *sniListener) handle(conn net.Conn) {
+	conn.SetReadDeadline(time.Now().Add(helloTimeout))
+	serverName, peeked, err := peekServerName(conn)
+	conn.SetReadDeadline(time.Time{})
+
+	c := newPrefixConn(conn, peeked)
+
+	if err == nil {
+		if r := l.proxy.passthroughRoute(serverName); r != nil {
+			l.proxy.forward(c, r, serverName)
+			return
+		}
+	}
+
+	// not a passthrough connection: TLS is terminated by the server,
+	// which also reports invalid handshakes
+	select {
+	case l.conns <- c:
+	case <-l.done:
+		conn.Close()
+	}
+}
+
+// Accept returns the next connection which is not forwarded.
+func (l *sniListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case err := <-l.errs:
+		return nil, err
+	case <-l.done:
+		return nil, fmt.Errorf("listener closed")
+	}
+}
+
+// Close closes the wrapped listener.
+func (l *sniListener) Close() error {
+	l.once.Do(func() { close(l.done) })
+
+	return l.Listener.Close()
+}
+
+// tcpListener forwards all connections to a port to a backend.
+type tcpListener struct {
+	sync.RWMutex
+	ln    net.Listener
+	route *route
+	log   interfaces.Logger
+}
+
+// listenTCP opens the port. Connections are accepted once the listener
+// is started.
+func listenTCP(port int, r *route, wrap func(net.Listener) net.Listener, log interfaces.Logger) (*tcpListener, error) {
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return nil, err
+	}
+
+	if wrap != nil {
+		ln = wrap(ln)
+	}
+
+	return &tcpListener{
+		ln:    ln,
+		route: r,
+		log:   log.WithField("port", port),
+	}, nil
+}
+
+// start accepts connections and forwards them to the route.
+func (l *tcpListener) start() {
+	l.RLock()
+	r := l.route
+	l.RUnlock()
+
+	l.log.
+		WithField("backend", r.backend.name).
+		Info("listening for TCP connections")
+
+	go l.acceptLoop()
+}
+
+func (l *tcpListener) acceptLoop() {
+	for {
+		conn, err := l.ln.Accept()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+
+			l.log.WithError(err).Info("stopped listening for TCP connections")
+
+			return
+		}
+
+		l.RLock()
+		r := l.route
+		l.RUnlock()
+
+		go forward(conn, r, l.log)
+	}
+}
+
+func (l *tcpListener) setRoute(r *route) {
+	l.Lock()
+	l.route = r
+	l.Unlock()
+}
+
+func (l *tcpListener) close() {
+	l.ln.Close()
+}
+
+// forward forwards a passthrough connection to the backend.
+func (p *Proxy) forward(conn net.Conn, r *route, serverName string) {
+	forward(conn, r, p.log.WithField("server_name", serverName))
+}
+
+// forward splices the connection to the next server of the backend of the
+// route, sending a PROXY protocol header first if required.
+func forward(conn net.Conn, r *route, log interfaces.Logger) {
+	be := r.backend
+
+	addr, err := be.nextAddr()
+	if err != nil {
+		log.WithError(err).Warn("selecting server")
+		conn.Close()
+		return
+	}
+
+	log = log.
+		WithField("backend", be.name).
+		WithField("addr", addr).
+		WithField("client", conn.RemoteAddr())
+
+	log.Debug("forwarding connection")
+
+	if err := splice(conn, be.dialer, addr, r.proxyProtocol); err != nil {
+		log.WithError(err).Warn("forwarding connection")
+	}
+}
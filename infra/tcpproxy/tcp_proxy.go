@@ -0,0 +1,386 @@
+package tcpproxy
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/off-sync/platform-proxy/app/interfaces"
+	"github.com/off-sync/platform-proxy/domain/sites"
+	"github.com/off-sync/platform-proxy/infra/resolver"
+)
+
+// Proxy implements the ConfigUpdater interface for layer 4 frontends. It
+// forwards TLS connections of passthrough frontends, selected using the
+// server name in the ClientHello, and connections to the ports of raw TCP
+// frontends to their backends. Other frontends are ignored.
+type Proxy struct {
+	sync.RWMutex
+	log             interfaces.Logger
+	wrapListener    func(net.Listener) net.Listener
+	resolveInterval time.Duration
+	passthroughs    []*passthrough
+	tcpListeners    map[int]*tcpListener
+	backends        []*backend
+}
+
+// Option configures the TCP proxy.
+type Option func(*Proxy)
+
+// WrapTCPListeners wraps the listeners of raw TCP frontends, for example
+// to accept PROXY protocol headers.
+func WrapTCPListeners(wrap func(net.Listener) net.Listener) Option {
+	return func(p *Proxy) {
+		p.wrapListener = wrap
+	}
+}
+
+// passthrough routes TLS connections matching the host to a backend.
+type passthrough struct {
+	matchHost func(host string) bool
+	route     *route
+}
+
+// route defines where connections are forwarded to.
+type route struct {
+	backend       *backend
+	proxyProtocol sites.ProxyProtocol
+}
+
+// backend selects the server addresses of a backend in round robin order.
+type backend struct {
+	name     string
+	next     uint32
+	dialer   *net.Dialer
+	resolver *resolver.Resolver
+
+	sync.RWMutex
+	addrs []string
+}
+
+// ResolveInterval sets the interval on which the host names of the
+// backend servers are resolved again. By default host names are only
+// resolved when the configuration is updated.
+func ResolveInterval(interval time.Duration) Option {
+	return func(p *Proxy) {
+		p.resolveInterval = interval
+	}
+}
+
+// New creates a new TCP proxy.
+func New(log interfaces.Logger, options ...Option) *Proxy {
+	p := &Proxy{
+		log:          log,
+		tcpListeners: make(map[int]*tcpListener),
+	}
+
+	for _, option := range options {
+		option(p)
+	}
+
+	return p
+}
+
+// Update replaces the layer 4 frontends of the proxy. Listeners are
+// started for new raw TCP frontends, and closed for removed ones.
+// It returns ErrUnknownBackend if a frontend is included for which the backend is unknown.
+// It returns ErrDuplicateDomain if multiple passthrough frontends use the same domain.
+// It returns ErrDuplicatePort if multiple raw TCP frontends use the same port.
+// It returns ErrInvalidFrontend for passthrough frontends with path rules or a
+// regular expression as domain.
+func (p *Proxy) Update(backends []*sites.Backend, frontends []*sites.Frontend) error {
+	update, err := p.Prepare(backends, frontends)
+	if err != nil {
+		return err
+	}
+
+	update.Apply()
+
+	return nil
+}
+
+// proxyUpdate is a configuration prepared by the proxy.
+type proxyUpdate struct {
+	proxy        *Proxy
+	passthroughs []*passthrough
+	backends     []*backend
+	ports        map[int]*route
+	listeners    map[int]*tcpListener
+}
+
+// Prepare resolves the backends of the provided layer 4 frontends and opens
+// the ports of new raw TCP frontends, without accepting connections or
+// changing the configuration of the proxy. It returns the same errors as
+// Update, or an error if a port cannot be opened.
+func (p *Proxy) Prepare(backends []*sites.Backend, frontends []*sites.Frontend) (interfaces.ConfigUpdate, error) {
+	if err := validate(backends, frontends); err != nil {
+		return nil, err
+	}
+
+	resolved := make(map[string]*backend)
+	var used []*backend
+	for _, b := range backends {
+		if !isUsed(b.Name, frontends) {
+			continue
+		}
+
+		be := newBackend(b, p.resolveInterval, p.log)
+		resolved[b.Name] = be
+		used = append(used, be)
+	}
+
+	// exact domains take precedence over wildcards
+	var exact, wildcard []*passthrough
+	ports := make(map[int]*route)
+
+	for _, frontend := range frontends {
+		r := &route{
+			backend:       resolved[frontend.BackendName],
+			proxyProtocol: frontend.ProxyProtocol,
+		}
+
+		switch {
+		case frontend.TCPPort > 0:
+			ports[frontend.TCPPort] = r
+
+		case frontend.Passthrough:
+			matchHost, err := frontend.HostMatcher()
+			if err != nil {
+				return nil, err
+			}
+
+			pt := &passthrough{
+				matchHost: matchHost,
+				route:     r,
+			}
+
+			if frontend.HostKind() == sites.WildcardHost {
+				wildcard = append(wildcard, pt)
+			} else {
+				exact = append(exact, pt)
+			}
+		}
+	}
+
+	u := &proxyUpdate{
+		proxy:        p,
+		passthroughs: append(exact, wildcard...),
+		backends:     used,
+		ports:        ports,
+		listeners:    make(map[int]*tcpListener),
+	}
+
+	p.RLock()
+	defer p.RUnlock()
+
+	for port, r := range ports {
+		if _, found := p.tcpListeners[port]; found {
+			continue
+		}
+
+		l, err := listenTCP(port, r, p.wrapListener, p.log)
+		if err != nil {
+			u.Discard()
+			return nil, fmt.Errorf("listening on port %d: %s", port, err)
+		}
+
+		u.listeners[port] = l
+	}
+
+	return u, nil
+}
+
+// Apply starts resolving the prepared backends, replaces the configuration
+// of the proxy and starts accepting connections on the new ports.
+// Listeners are closed for removed raw TCP frontends.
+func (u *proxyUpdate) Apply() {
+	for _, be := range u.backends {
+		be.resolver.Start()
+	}
+
+	p := u.proxy
+
+	p.Lock()
+	defer p.Unlock()
+
+	p.passthroughs = u.passthroughs
+
+	// connections in progress keep their server address
+	for _, be := range p.backends {
+		be.resolver.Close()
+	}
+
+	p.backends = u.backends
+
+	for port, l := range p.tcpListeners {
+		if _, found := u.ports[port]; !found {
+			l.close()
+			delete(p.tcpListeners, port)
+		}
+	}
+
+	for port, r := range u.ports {
+		if l, found := p.tcpListeners[port]; found {
+			l.setRoute(r)
+			continue
+		}
+
+		l := u.listeners[port]
+		l.start()
+
+		p.tcpListeners[port] = l
+	}
+}
+
+// Discard closes the ports opened for the prepared configuration and stops
+// the resolvers of its backends, which have not been started.
+func (u *proxyUpdate) Discard() {
+	for _, l := range u.listeners {
+		l.close()
+	}
+
+	for _, be := range u.backends {
+		be.resolver.Close()
+	}
+}
+
+// passthroughRoute returns the route of the passthrough frontend
+// matching the server name, or nil if there is none.
+func (p *Proxy) passthroughRoute(serverName string) *route {
+	if serverName == "" {
+		return nil
+	}
+
+	p.RLock()
+	defer p.RUnlock()
+
+	for _, pt := range p.passthroughs {
+		if pt.matchHost(serverName) {
+			return pt.route
+		}
+	}
+
+	return nil
+}
+
+func validate(backends []*sites.Backend, frontends []*sites.Frontend) error {
+	backendNames := make(map[string]bool)
+	for _, b := range backends {
+		backendNames[b.Name] = true
+	}
+
+	domains := make(map[string]bool)
+	ports := make(map[int]bool)
+
+	for _, frontend := range frontends {
+		if !frontend.IsLayer4() {
+			continue
+		}
+
+		if len(frontend.Splits) > 0 || frontend.Mirror != nil || frontend.ClientAuth != nil {
+			return interfaces.ErrInvalidFrontend
+		}
+
+		if !backendNames[frontend.BackendName] {
+			return interfaces.ErrUnknownBackend
+		}
+
+		if frontend.TCPPort > 0 {
+			if ports[frontend.TCPPort] {
+				return interfaces.ErrDuplicatePort
+			}
+
+			ports[frontend.TCPPort] = true
+
+			continue
+		}
+
+		if frontend.PathPrefix != "" || frontend.PathRegex != "" || len(frontend.Rules) > 0 {
+			return interfaces.ErrInvalidFrontend
+		}
+
+		if frontend.HostKind() == sites.RegexHost {
+			return interfaces.ErrInvalidFrontend
+		}
+
+		if domains[frontend.Domain] {
+			return interfaces.ErrDuplicateDomain
+		}
+
+		domains[frontend.Domain] = true
+	}
+
+	return nil
+}
+
+func isUsed(backendName string, frontends []*sites.Frontend) bool {
+	for _, frontend := range frontends {
+		if frontend.IsLayer4() && frontend.BackendName == backendName {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newBackend resolves the addresses of all servers of the backend. The
+// scheme of the server URLs is ignored, as are the transport settings other
+// than the dial timeout and keep-alive. The resolver must be started to
+// resolve them again on the interval.
+func newBackend(b *sites.Backend, resolveInterval time.Duration, log interfaces.Logger) *backend {
+	settings := b.Transport.WithDefaults()
+
+	be := &backend{
+		name: b.Name,
+		dialer: &net.Dialer{
+			Timeout:   settings.DialTimeout,
+			KeepAlive: settings.KeepAlive,
+		},
+	}
+
+	be.resolver = resolver.New(b, resolveInterval, be.updateAddrs, log)
+	be.resolver.Resolve()
+
+	return be
+}
+
+// updateAddrs adds the resolved server addresses, and removes the
+// addresses which no longer resolve.
+func (b *backend) updateAddrs(added, removed []*resolver.Addr) {
+	b.Lock()
+	defer b.Unlock()
+
+	gone := make(map[string]bool)
+	for _, addr := range removed {
+		gone[addr.Host] = true
+	}
+
+	var addrs []string
+	for _, addr := range b.addrs {
+		if !gone[addr] {
+			addrs = append(addrs, addr)
+		}
+	}
+
+	for _, addr := range added {
+		addrs = append(addrs, addr.Host)
+	}
+
+	b.addrs = addrs
+}
+
+// nextAddr returns the address of the next server.
+func (b *backend) nextAddr() (string, error) {
+	b.RLock()
+	defer b.RUnlock()
+
+	if len(b.addrs) < 1 {
+		return "", fmt.Errorf("backend %s has no servers", b.name)
+	}
+
+	n := atomic.AddUint32(&b.next, 1)
+
+	return b.addrs[int(n-1)%len(b.addrs)], nil
+}
@@ -0,0 +1,220 @@
+package tcpproxy
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/off-sync/platform-proxy/app/interfaces"
+	"github.com/off-sync/platform-proxy/domain/sites"
+	"github.com/off-sync/platform-proxy/infra/proxyproto"
+)
+
+type nopLogger struct{}
+
+func (l nopLogger) WithField(key string, value interface{}) interfaces.Logger { return l }
+func (l nopLogger) WithError(err error) interfaces.Logger                     { return l }
+func (l nopLogger) Debug(msg string)                                          {}
+func (l nopLogger) Info(msg string)                                           {}
+func (l nopLogger) Warn(msg string)                                           {}
+func (l nopLogger) Error(msg string)                                          {}
+func (l nopLogger) Fatal(msg string)                                          { panic(msg) }
+
+// newTestProxy creates a proxy forwarding TLS connections for the domain
+// to the backend server, and returns the listener wrapped by the proxy.
+func newTestProxy(t *testing.T, domain, server string, proxyProtocol sites.ProxyProtocol) net.Listener {
+	backend, err := sites.NewBackend("secure", server)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frontend := sites.NewFrontend("secure", domain)
+	frontend.Passthrough = true
+	frontend.ProxyProtocol = proxyProtocol
+
+	p := New(nopLogger{})
+	if err := p.Update([]*sites.Backend{backend}, []*sites.Frontend{frontend}); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { p.Update(nil, nil) })
+
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ln := p.Listen(tcp)
+	t.Cleanup(func() { ln.Close() })
+
+	return ln
+}
+
+// get requests the server name through the listener using TLS.
+func get(ln net.Listener, serverName string) (string, error) {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return net.Dial("tcp", ln.Addr().String())
+			},
+			TLSClientConfig: &tls.Config{
+				ServerName:         serverName,
+				InsecureSkipVerify: true,
+			},
+		},
+		Timeout: 5 * time.Second,
+	}
+
+	resp, err := client.Get("https://" + serverName + "/")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+
+	return string(body), err
+}
+
+func TestPassthroughRoutesOnServerName(t *testing.T) {
+	var requests int32
+
+	backend := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		fmt.Fprintf(w, "backend %s", r.TLS.ServerName)
+	}))
+	defer backend.Close()
+
+	tests := []struct {
+		name       string
+		domain     string
+		serverName string
+	}{
+		{"exact", "secure.example.com", "secure.example.com"},
+		{"wildcard", "*.example.com", "secure.example.com"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ln := newTestProxy(t, test.domain, backend.URL, sites.ProxyProtocolNone)
+
+			// TLS is terminated by the backend
+			body, err := get(ln, test.serverName)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if expected := "backend " + test.serverName; body != expected {
+				t.Errorf("expected %q, got %q", expected, body)
+			}
+		})
+	}
+
+	if n := atomic.LoadInt32(&requests); n != int32(len(tests)) {
+		t.Errorf("expected %d requests, got %d", len(tests), n)
+	}
+}
+
+func TestUnknownServerNamesAreNotForwarded(t *testing.T) {
+	var requests int32
+
+	backend := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+	}))
+	defer backend.Close()
+
+	ln := newTestProxy(t, "secure.example.com", backend.URL, sites.ProxyProtocolNone)
+
+	// the server terminating TLS rejects unknown server names
+	var handshakes int32
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			atomic.AddInt32(&handshakes, 1)
+
+			go tls.Server(conn, &tls.Config{
+				GetCertificate: func(chi *tls.ClientHelloInfo) (*tls.Certificate, error) {
+					return nil, fmt.Errorf("unknown host: %s", chi.ServerName)
+				},
+			}).Handshake()
+		}
+	}()
+
+	for _, serverName := range []string{"other.example.com", "www.secure.example.com"} {
+		if _, err := get(ln, serverName); err == nil {
+			t.Errorf("expected %s to be rejected", serverName)
+		}
+	}
+
+	if n := atomic.LoadInt32(&handshakes); n != 2 {
+		t.Errorf("expected 2 connections to be terminated, got %d", n)
+	}
+
+	if n := atomic.LoadInt32(&requests); n != 0 {
+		t.Errorf("expected no requests to be forwarded, got %d", n)
+	}
+}
+
+func TestPassthroughSendsProxyProtocolHeader(t *testing.T) {
+	for _, version := range []sites.ProxyProtocol{sites.ProxyProtocolV1, sites.ProxyProtocolV2} {
+		t.Run(fmt.Sprintf("v%d", version), func(t *testing.T) {
+			trusted, err := proxyproto.ParseCIDRs("127.0.0.0/8")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tcp, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			backend := proxyproto.NewListener(tcp, trusted)
+			defer backend.Close()
+
+			ln := newTestProxy(t, "secure.example.com", "tcp://"+tcp.Addr().String(), version)
+
+			client, err := net.Dial("tcp", ln.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+
+			// only the ClientHello is sent: the backend does not answer
+			go tls.Client(client, &tls.Config{ServerName: "secure.example.com"}).Handshake()
+
+			conn, err := backend.Accept()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			if conn.RemoteAddr().String() != client.LocalAddr().String() {
+				t.Errorf("expected client address %s, got %s", client.LocalAddr(), conn.RemoteAddr())
+			}
+
+			// the ClientHello follows the header unchanged
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+			record, err := bufio.NewReader(conn).Peek(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if record[0] != 0x16 {
+				t.Errorf("expected a TLS handshake record, got %q", record)
+			}
+		})
+	}
+}